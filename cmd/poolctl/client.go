@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	k8sCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientGoScheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrlClient "sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlConfig "sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	capacityGroupV1 "github.com/Netflix/titus-controllers-api/api/capacitygroup/v1"
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+)
+
+// SnapshotFile is the content of a saved snapshot file (YAML or JSON). Objects are served by a fake client, so
+// all commands work the same way as with a live cluster.
+type SnapshotFile struct {
+	ResourcePools  []poolV1.ResourcePoolConfig       `json:"resourcePools,omitempty"`
+	MachineTypes   []machineTypeV1.MachineTypeConfig `json:"machineTypes,omitempty"`
+	CapacityGroups []capacityGroupV1.CapacityGroup   `json:"capacityGroups,omitempty"`
+	Nodes          []k8sCore.Node                    `json:"nodes,omitempty"`
+	Pods           []k8sCore.Pod                     `json:"pods,omitempty"`
+}
+
+func newScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		clientGoScheme.AddToScheme,
+		poolV1.AddToScheme,
+		machineTypeV1.AddToScheme,
+		capacityGroupV1.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			return nil, err
+		}
+	}
+	return scheme, nil
+}
+
+// newClient creates a client reading from the snapshot file if set, or from the cluster otherwise. If the kubeconfig
+// path is not set, the standard controller-runtime lookup is used (KUBECONFIG, in-cluster, ~/.kube/config).
+func newClient(options *cliOptions) (ctrlClient.Client, error) {
+	scheme, err := newScheme()
+	if err != nil {
+		return nil, err
+	}
+	if options.snapshotFile != "" {
+		snapshotFile, err := LoadSnapshotFile(options.snapshotFile)
+		if err != nil {
+			return nil, err
+		}
+		return NewSnapshotFileClient(scheme, snapshotFile), nil
+	}
+
+	var restConfig *rest.Config
+	if options.kubeconfig != "" {
+		restConfig, err = clientcmd.BuildConfigFromFlags("", options.kubeconfig)
+	} else {
+		restConfig, err = ctrlConfig.GetConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot load cluster configuration: %w", err)
+	}
+	return ctrlClient.New(restConfig, ctrlClient.Options{Scheme: scheme})
+}
+
+// NewSnapshotFileClient returns a fake client serving objects from the snapshot file.
+func NewSnapshotFileClient(scheme *runtime.Scheme, snapshotFile *SnapshotFile) ctrlClient.Client {
+	objects := []ctrlClient.Object{}
+	for i := range snapshotFile.ResourcePools {
+		objects = append(objects, &snapshotFile.ResourcePools[i])
+	}
+	for i := range snapshotFile.MachineTypes {
+		objects = append(objects, &snapshotFile.MachineTypes[i])
+	}
+	for i := range snapshotFile.CapacityGroups {
+		objects = append(objects, &snapshotFile.CapacityGroups[i])
+	}
+	for i := range snapshotFile.Nodes {
+		objects = append(objects, &snapshotFile.Nodes[i])
+	}
+	for i := range snapshotFile.Pods {
+		objects = append(objects, &snapshotFile.Pods[i])
+	}
+	for _, object := range objects {
+		object.SetResourceVersion("")
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func LoadSnapshotFile(path string) (*SnapshotFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot file %s: %w", path, err)
+	}
+	snapshotFile := SnapshotFile{}
+	if err := yaml.Unmarshal(data, &snapshotFile); err != nil {
+		return nil, fmt.Errorf("cannot parse snapshot file %s: %w", path, err)
+	}
+	return &snapshotFile, nil
+}
+
+// ReadSnapshotFile reads all objects used by the commands from the client.
+func ReadSnapshotFile(ctx context.Context, client ctrlClient.Client) (*SnapshotFile, error) {
+	resourcePools := poolV1.ResourcePoolConfigList{}
+	if err := client.List(ctx, &resourcePools); err != nil {
+		return nil, fmt.Errorf("cannot read resource pools: %w", err)
+	}
+	machineTypes := machineTypeV1.MachineTypeConfigList{}
+	if err := client.List(ctx, &machineTypes); err != nil {
+		return nil, fmt.Errorf("cannot read machine types: %w", err)
+	}
+	capacityGroups := capacityGroupV1.CapacityGroupList{}
+	if err := client.List(ctx, &capacityGroups); err != nil {
+		return nil, fmt.Errorf("cannot read capacity groups: %w", err)
+	}
+	nodes := k8sCore.NodeList{}
+	if err := client.List(ctx, &nodes); err != nil {
+		return nil, fmt.Errorf("cannot read nodes: %w", err)
+	}
+	pods := k8sCore.PodList{}
+	if err := client.List(ctx, &pods); err != nil {
+		return nil, fmt.Errorf("cannot read pods: %w", err)
+	}
+	return &SnapshotFile{
+		ResourcePools:  resourcePools.Items,
+		MachineTypes:   machineTypes.Items,
+		CapacityGroups: capacityGroups.Items,
+		Nodes:          nodes.Items,
+		Pods:           pods.Items,
+	}, nil
+}
+
+func SaveSnapshotFile(path string, snapshotFile *SnapshotFile) error {
+	data, err := yaml.Marshal(snapshotFile)
+	if err != nil {
+		return fmt.Errorf("cannot serialize snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("cannot write snapshot file %s: %w", path, err)
+	}
+	return nil
+}
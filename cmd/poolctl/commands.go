@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	k8sCore "k8s.io/api/core/v1"
+	ctrlClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	"github.com/Netflix/titus-resource-pool/reserved"
+	"github.com/Netflix/titus-resource-pool/resourcepool"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
+)
+
+func runPools(ctx context.Context, client ctrlClient.Client, options *cliOptions) (string, error) {
+	poolList := poolV1.ResourcePoolConfigList{}
+	if err := client.List(ctx, &poolList); err != nil {
+		return "", fmt.Errorf("cannot read resource pools: %w", err)
+	}
+	pools := []*poolV1.ResourcePoolConfig{}
+	for i := range poolList.Items {
+		pools = append(pools, &poolList.Items[i])
+	}
+	formatterOptions := options.formatterOptions()
+	if formatterOptions.Output == poolUtil.OutputTable {
+		return resourcepool.FormatResourcePoolsTable(pools, formatterOptions), nil
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].Name < pools[j].Name
+	})
+	lines := []string{}
+	for _, pool := range pools {
+		lines = append(lines, resourcepool.FormatResourcePool(pool, formatterOptions))
+	}
+	return joinLines(lines), nil
+}
+
+func runNodes(_ context.Context, client ctrlClient.Client, options *cliOptions) (string, error) {
+	snapshot, err := loadResourceSnapshot(client, options, false)
+	if err != nil {
+		return "", err
+	}
+	type nodeRecord struct {
+		Name        string
+		State       string
+		MachineType string
+		Resources   poolV1.ComputeResource
+	}
+	records := []nodeRecord{}
+	for _, name := range sortedNodeNames(snapshot.NodeSnapshot.AllByName) {
+		node := snapshot.NodeSnapshot.AllByName[name]
+		state, _ := snapshot.NodeSnapshot.NodeState(name)
+		machineType, _ := poolNode.FindNodeInstanceType(node)
+		records = append(records, nodeRecord{
+			Name:        name,
+			State:       state,
+			MachineType: machineType,
+			Resources:   poolNode.FromNodeToComputeResource(node),
+		})
+	}
+
+	formatterOptions := options.formatterOptions()
+	if formatterOptions.Output != poolUtil.OutputTable {
+		lines := []string{}
+		for _, record := range records {
+			lines = append(lines, poolUtil.ToJSONString(record))
+		}
+		return joinLines(lines), nil
+	}
+	table := poolUtil.NewTable(append([]string{"NAME", "STATE", "MACHINE_TYPE"}, poolUtil.ComputeResourceColumns...)...)
+	for _, record := range records {
+		table.AddCells(append(poolUtil.Cells(record.Name, record.State, record.MachineType),
+			poolUtil.ComputeResourceCells(record.Resources)...)...)
+	}
+	return table.Render(formatterOptions), nil
+}
+
+func runPods(_ context.Context, client ctrlClient.Client, options *cliOptions) (string, error) {
+	snapshot, err := loadResourceSnapshot(client, options, true)
+	if err != nil {
+		return "", err
+	}
+	type podRecord struct {
+		Name      string
+		State     string
+		Node      string
+		Resources poolV1.ComputeResource
+	}
+	records := []podRecord{}
+	addPods := func(state string, pods map[string]*k8sCore.Pod) {
+		for _, name := range sortedPodNames(pods) {
+			records = append(records, podRecord{
+				Name:      name,
+				State:     state,
+				Node:      pods[name].Spec.NodeName,
+				Resources: snapshot.PodSnapshot.Metadata[name].PodResources,
+			})
+		}
+	}
+	if options.podState != podStateScheduled {
+		queued := map[string]*k8sCore.Pod{}
+		for name, pod := range snapshot.PodSnapshot.QueuedYoungByName {
+			queued[name] = pod
+		}
+		for name, pod := range snapshot.PodSnapshot.QueuedOldByName {
+			queued[name] = pod
+		}
+		addPods(podStateQueued, queued)
+	}
+	if options.podState != podStateQueued {
+		addPods(podStateScheduled, snapshot.PodSnapshot.ScheduledByName)
+	}
+
+	formatterOptions := options.formatterOptions()
+	if formatterOptions.Output != poolUtil.OutputTable {
+		lines := []string{}
+		for _, record := range records {
+			lines = append(lines, poolUtil.ToJSONString(record))
+		}
+		return joinLines(lines), nil
+	}
+	table := poolUtil.NewTable(append([]string{"NAME", "STATE", "NODE"}, poolUtil.ComputeResourceColumns...)...)
+	for _, record := range records {
+		table.AddCells(append(poolUtil.Cells(record.Name, record.State, record.Node),
+			poolUtil.ComputeResourceCells(record.Resources)...)...)
+	}
+	return table.Render(formatterOptions), nil
+}
+
+func runCapacity(_ context.Context, client ctrlClient.Client, options *cliOptions) (string, error) {
+	snapshot, err := loadResourceSnapshot(client, options, true)
+	if err != nil {
+		return "", err
+	}
+	_, total, byNode := resourcepool.ComputeAllocatableCapacityFromSnapshot(snapshot, poolV1.Zero, false,
+		options.excludePreemptiblePods)
+
+	text := resourcepool.FormatAllocatableCapacity(total, byNode, options.formatterOptions())
+	if options.output == outputJSON {
+		return joinLines([]string{text}), nil
+	}
+	return text, nil
+}
+
+func runReservations(_ context.Context, client ctrlClient.Client, options *cliOptions) (string, error) {
+	snapshot, err := loadResourceSnapshot(client, options, true)
+	if err != nil {
+		return "", err
+	}
+	capacityGroups, err := reserved.NewCapacityGroupSnapshot(client)
+	if err != nil {
+		return "", err
+	}
+	usage := reserved.NewCapacityReservationUsage(snapshot, capacityGroups.FindOwnedByResourcePool(options.resourcePool),
+		reserved.GetBufferCapacityGroupName(options.resourcePool))
+	text := reserved.FormatCapacityReservationUsage(usage, options.formatterOptions())
+	if options.output == outputJSON {
+		return joinLines([]string{text}), nil
+	}
+	return text, nil
+}
+
+func runSave(ctx context.Context, client ctrlClient.Client, options *cliOptions) (string, error) {
+	snapshotFile, err := ReadSnapshotFile(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	if err := SaveSnapshotFile(options.saveTo, snapshotFile); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("saved %d resource pools, %d machine types, %d capacity groups, %d nodes and %d pods to %s\n",
+		len(snapshotFile.ResourcePools), len(snapshotFile.MachineTypes), len(snapshotFile.CapacityGroups),
+		len(snapshotFile.Nodes), len(snapshotFile.Pods), options.saveTo), nil
+}
+
+func loadResourceSnapshot(client ctrlClient.Client, options *cliOptions, withPods bool) (*resourcepool.ResourceSnapshot, error) {
+	return resourcepool.NewResourceSnapshot(client, options.resourcePool, options.nodeBootstrapThreshold,
+		options.includeKubeletBackend, withPods)
+}
+
+func sortedNodeNames(nodes map[string]*k8sCore.Node) []string {
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedPodNames(pods map[string]*k8sCore.Pod) []string {
+	names := make([]string, 0, len(pods))
+	for name := range pods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,158 @@
+// Command poolctl inspects resource pools, their nodes, pods, allocatable capacity and capacity group reservations,
+// reading data either from a cluster or from a saved snapshot file.
+//
+// Usage:
+//
+//	poolctl <command> [flags]
+//
+// Commands: pools, nodes, pods, capacity, reservations, save. Run "poolctl <command> -h" to list the flags.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	ctrlClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
+)
+
+const (
+	outputJSON  = "json"
+	outputTable = "table"
+
+	podStateAll       = "all"
+	podStateQueued    = "queued"
+	podStateScheduled = "scheduled"
+)
+
+type cliOptions struct {
+	kubeconfig             string
+	snapshotFile           string
+	resourcePool           string
+	output                 string
+	sortBy                 string
+	columns                string
+	podState               string
+	nodeBootstrapThreshold time.Duration
+	includeKubeletBackend  bool
+	excludePreemptiblePods bool
+	saveTo                 string
+}
+
+// Creates the client used by commands. Tests replace it with a factory returning a fake client.
+type clientFactory func(options *cliOptions) (ctrlClient.Client, error)
+
+type command struct {
+	description string
+	needsPool   bool
+	needsSaveTo bool
+	execute     func(ctx context.Context, client ctrlClient.Client, options *cliOptions) (string, error)
+}
+
+var commands = map[string]command{
+	"pools":        {description: "list resource pools", execute: runPools},
+	"nodes":        {description: "list resource pool nodes with their states", needsPool: true, execute: runNodes},
+	"pods":         {description: "list queued and scheduled resource pool pods", needsPool: true, execute: runPods},
+	"capacity":     {description: "show allocatable capacity of active nodes", needsPool: true, execute: runCapacity},
+	"reservations": {description: "show capacity group reservation usage", needsPool: true, execute: runReservations},
+	"save":         {description: "save all objects to a snapshot file", needsSaveTo: true, execute: runSave},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr, newClient))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer, newClient clientFactory) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+	name := args[0]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command: %s\n", name)
+		printUsage(stderr)
+		return 2
+	}
+
+	options := &cliOptions{}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&options.kubeconfig, "kubeconfig", "", "path to the kubeconfig file")
+	flags.StringVar(&options.snapshotFile, "snapshot-file", "", "read objects from the snapshot file instead of the cluster")
+	flags.StringVar(&options.resourcePool, "pool", "", "resource pool name")
+	flags.StringVar(&options.output, "output", outputTable, "output format (json or table)")
+	flags.StringVar(&options.sortBy, "sort", "", "table column to sort by")
+	flags.StringVar(&options.columns, "columns", "", "comma separated list of table columns to show")
+	flags.StringVar(&options.podState, "state", podStateAll, "pods to list (all, queued or scheduled)")
+	flags.DurationVar(&options.nodeBootstrapThreshold, "bootstrap-threshold", 10*time.Minute,
+		"time after which a node not ready yet is no longer regarded as bootstrapping")
+	flags.BoolVar(&options.includeKubeletBackend, "include-kubelet", false, "include kubelet backend nodes")
+	flags.BoolVar(&options.excludePreemptiblePods, "exclude-preemptible", false,
+		"regard capacity used by preemptible pods as allocatable")
+	flags.StringVar(&options.saveTo, "to", "", "snapshot file to write (save command)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if err := validateOptions(cmd, options); err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return 2
+	}
+
+	client, err := newClient(options)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	text, err := cmd.execute(context.Background(), client, options)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	fmt.Fprint(stdout, text)
+	return 0
+}
+
+func validateOptions(cmd command, options *cliOptions) error {
+	if options.output != outputJSON && options.output != outputTable {
+		return fmt.Errorf("invalid output format: %s", options.output)
+	}
+	if options.podState != podStateAll && options.podState != podStateQueued && options.podState != podStateScheduled {
+		return fmt.Errorf("invalid pod state: %s", options.podState)
+	}
+	if cmd.needsPool && options.resourcePool == "" {
+		return fmt.Errorf("resource pool name not set (-pool)")
+	}
+	if cmd.needsSaveTo && options.saveTo == "" {
+		return fmt.Errorf("snapshot file to write not set (-to)")
+	}
+	return nil
+}
+
+func (options *cliOptions) formatterOptions() poolUtil.FormatterOptions {
+	formatterOptions := poolUtil.FormatterOptions{
+		Level:  poolUtil.FormatEssentials,
+		SortBy: options.sortBy,
+	}
+	if options.output == outputTable {
+		formatterOptions.Output = poolUtil.OutputTable
+	}
+	if options.columns != "" {
+		formatterOptions.Columns = strings.Split(options.columns, ",")
+	}
+	return formatterOptions
+}
+
+func printUsage(writer io.Writer) {
+	fmt.Fprintln(writer, "Usage: poolctl <command> [flags]")
+	fmt.Fprintln(writer, "Commands:")
+	for _, name := range []string{"pools", "nodes", "pods", "capacity", "reservations", "save"} {
+		fmt.Fprintf(writer, "  %-14s%s\n", name, commands[name].description)
+	}
+}
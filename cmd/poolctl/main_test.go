@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	k8sCore "k8s.io/api/core/v1"
+	ctrlClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	capacityGroupV1 "github.com/Netflix/titus-controllers-api/api/capacitygroup/v1"
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+	"github.com/Netflix/titus-resource-pool/reserved"
+	"github.com/Netflix/titus-resource-pool/resourcepool"
+)
+
+const testPool = "testPool"
+
+func newTestSnapshotFile() *SnapshotFile {
+	pool := resourcepool.NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 4)
+	node := poolNode.NewNode("node1", testPool, machine.R5Metal())
+	shape := pool.Spec.ResourceShape.ComputeResource
+	scheduled := poolPod.ButPodRunningOnNode(
+		poolPod.NewNotScheduledPodWithName("scheduledPod", testPool, shape, time.Now()), node)
+	queued := poolPod.NewNotScheduledPodWithName("queuedPod", testPool, shape, time.Now())
+	return &SnapshotFile{
+		ResourcePools:  []poolV1.ResourcePoolConfig{*pool},
+		MachineTypes:   []machineTypeV1.MachineTypeConfig{*machine.R5Metal()},
+		CapacityGroups: []capacityGroupV1.CapacityGroup{*reserved.NewCapacityGroup("cg1", testPool)},
+		Nodes:          []k8sCore.Node{*node},
+		Pods:           []k8sCore.Pod{*scheduled, *queued},
+	}
+}
+
+func runWithSnapshotFile(t *testing.T, snapshotFile *SnapshotFile, args ...string) (string, string, int) {
+	scheme, err := newScheme()
+	require.NoError(t, err)
+	client := NewSnapshotFileClient(scheme, snapshotFile)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	code := run(args, stdout, stderr, func(options *cliOptions) (ctrlClient.Client, error) {
+		return client, nil
+	})
+	return stdout.String(), stderr.String(), code
+}
+
+func TestPoolsCommand(t *testing.T) {
+	stdout, _, code := runWithSnapshotFile(t, newTestSnapshotFile(), "pools", "-columns", "NAME,RESOURCE_COUNT,CPU")
+	require.Equal(t, 0, code)
+	require.Equal(t, ""+
+		"NAME      RESOURCE_COUNT  CPU\n"+
+		"testPool  4               24 cores\n",
+		stdout)
+}
+
+func TestNodesCommand(t *testing.T) {
+	stdout, _, code := runWithSnapshotFile(t, newTestSnapshotFile(), "nodes", "-pool", testPool,
+		"-columns", "NAME,STATE,MACHINE_TYPE")
+	require.Equal(t, 0, code)
+	require.Equal(t, ""+
+		"NAME   STATE   MACHINE_TYPE\n"+
+		"node1  active  r5.metal\n",
+		stdout)
+}
+
+func TestPodsCommand(t *testing.T) {
+	stdout, _, code := runWithSnapshotFile(t, newTestSnapshotFile(), "pods", "-pool", testPool,
+		"-columns", "NAME,STATE,NODE")
+	require.Equal(t, 0, code)
+	require.Equal(t, ""+
+		"NAME          STATE      NODE\n"+
+		"queuedPod     queued\n"+
+		"scheduledPod  scheduled  node1\n",
+		stdout)
+
+	stdout, _, code = runWithSnapshotFile(t, newTestSnapshotFile(), "pods", "-pool", testPool, "-state", "queued",
+		"-output", "json")
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, "\"Name\":\"queuedPod\"")
+	require.NotContains(t, stdout, "scheduledPod")
+}
+
+func TestCapacityCommand(t *testing.T) {
+	stdout, _, code := runWithSnapshotFile(t, newTestSnapshotFile(), "capacity", "-pool", testPool,
+		"-columns", "NODE,CPU")
+	require.Equal(t, 0, code)
+	require.Equal(t, ""+
+		"NODE     CPU\n"+
+		"node1    72 cores\n"+
+		"<total>  72 cores\n",
+		stdout)
+}
+
+func TestReservationsCommand(t *testing.T) {
+	stdout, _, code := runWithSnapshotFile(t, newTestSnapshotFile(), "reservations", "-pool", testPool,
+		"-output", "json")
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, "\"Unallocated\":{\"cpu\":160")
+}
+
+func TestSaveAndLoadSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.yaml")
+	stdout, _, code := runWithSnapshotFile(t, newTestSnapshotFile(), "save", "-to", path)
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, "1 nodes and 2 pods")
+
+	stdoutBuffer := &bytes.Buffer{}
+	code = run([]string{"nodes", "-pool", testPool, "-snapshot-file", path, "-columns", "NAME"}, stdoutBuffer,
+		&bytes.Buffer{}, newClient)
+	require.Equal(t, 0, code)
+	require.Equal(t, "NAME\nnode1\n", stdoutBuffer.String())
+}
+
+func TestInvalidArguments(t *testing.T) {
+	_, stderr, code := runWithSnapshotFile(t, newTestSnapshotFile(), "nodes")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "resource pool name not set")
+
+	_, stderr, code = runWithSnapshotFile(t, newTestSnapshotFile(), "unknown")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "unknown command")
+}
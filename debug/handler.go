@@ -0,0 +1,291 @@
+package debug
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	k8sCore "k8s.io/api/core/v1"
+	ctrlClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	capacityGroupV1 "github.com/Netflix/titus-controllers-api/api/capacitygroup/v1"
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+	"github.com/Netflix/titus-resource-pool/reserved"
+	"github.com/Netflix/titus-resource-pool/resourcepool"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
+)
+
+const (
+	QueryParamPool   = "pool"
+	QueryParamState  = "state"
+	QueryParamLevel  = "level"
+	QueryParamFormat = "format"
+
+	FormatJSON = "json"
+	FormatHTML = "html"
+)
+
+var levelsByName = map[string]poolUtil.FormatDetailsLevel{
+	"compact":    poolUtil.FormatCompact,
+	"essentials": poolUtil.FormatEssentials,
+	"details":    poolUtil.FormatDetails,
+}
+
+// SnapshotProvider returns the current snapshot of the given resource pool.
+type SnapshotProvider func(ctx context.Context, resourcePool string) (*resourcepool.ResourceSnapshot, error)
+
+// CapacityGroupProvider returns capacity groups owned by the given resource pool.
+type CapacityGroupProvider func(ctx context.Context, resourcePool string) ([]*capacityGroupV1.CapacityGroup, error)
+
+type HandlerOptions struct {
+	Snapshots SnapshotProvider
+	// Optional. If not set, the reservations endpoint responds with 404.
+	CapacityGroups CapacityGroupProvider
+	// Resource pool used when the pool query parameter is not set.
+	DefaultResourcePool string
+}
+
+type handler struct {
+	options HandlerOptions
+	mux     *http.ServeMux
+}
+
+// NewHandler returns a handler serving the resource pool snapshot state with the following endpoints:
+//
+//	/snapshot      resource snapshot aggregates
+//	/nodes         resource pool nodes, filtered by the node state (see node.NodeState* values)
+//	/pods          resource pool pods, filtered by the pod state (queued, scheduled or finished)
+//	/capacity      allocatable capacity of active nodes
+//	/reservations  capacity group reservation usage
+//
+// All endpoints accept the pool, level (compact, essentials or details) and format (json or html) query parameters.
+// The handler expects paths relative to its mount point, so when mounted under a prefix, wrap it with
+// http.StripPrefix.
+func NewHandler(options HandlerOptions) http.Handler {
+	h := &handler{
+		options: options,
+		mux:     http.NewServeMux(),
+	}
+	h.mux.HandleFunc("/", h.serveIndex)
+	h.mux.HandleFunc("/snapshot", h.withSnapshot(h.serveSnapshot))
+	h.mux.HandleFunc("/nodes", h.withSnapshot(h.serveNodes))
+	h.mux.HandleFunc("/pods", h.withSnapshot(h.servePods))
+	h.mux.HandleFunc("/capacity", h.withSnapshot(h.serveCapacity))
+	h.mux.HandleFunc("/reservations", h.withSnapshot(h.serveReservations))
+	return h
+}
+
+// NewClientSnapshotProvider returns a provider loading a new snapshot with nodes and pods on each request.
+func NewClientSnapshotProvider(client ctrlClient.Client, nodeBootstrapThreshold time.Duration,
+	includeKubeletBackend bool) SnapshotProvider {
+	return func(_ context.Context, resourcePool string) (*resourcepool.ResourceSnapshot, error) {
+		return resourcepool.NewResourceSnapshot(client, resourcePool, nodeBootstrapThreshold, includeKubeletBackend, true)
+	}
+}
+
+// NewClientCapacityGroupProvider returns a provider loading capacity groups on each request.
+func NewClientCapacityGroupProvider(client ctrlClient.Client) CapacityGroupProvider {
+	return func(_ context.Context, resourcePool string) ([]*capacityGroupV1.CapacityGroup, error) {
+		snapshot, err := reserved.NewCapacityGroupSnapshot(client)
+		if err != nil {
+			return nil, err
+		}
+		return snapshot.FindOwnedByResourcePool(resourcePool), nil
+	}
+}
+
+func (h *handler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	h.mux.ServeHTTP(writer, request)
+}
+
+type requestContext struct {
+	writer   http.ResponseWriter
+	request  *http.Request
+	snapshot *resourcepool.ResourceSnapshot
+	options  poolUtil.FormatterOptions
+	state    string
+}
+
+func (c *requestContext) isHTML() bool {
+	return c.options.Output == poolUtil.OutputHTML
+}
+
+func (h *handler) withSnapshot(serve func(c *requestContext)) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		options, err := parseFormatterOptions(query.Get(QueryParamLevel), query.Get(QueryParamFormat))
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pool := query.Get(QueryParamPool)
+		if pool == "" {
+			pool = h.options.DefaultResourcePool
+		}
+		if pool == "" {
+			http.Error(writer, "resource pool not set", http.StatusBadRequest)
+			return
+		}
+		snapshot, err := h.options.Snapshots(request.Context(), pool)
+		if err != nil {
+			http.Error(writer, fmt.Sprintf("cannot load resource pool %s snapshot: %v", pool, err),
+				http.StatusInternalServerError)
+			return
+		}
+		serve(&requestContext{
+			writer:   writer,
+			request:  request,
+			snapshot: snapshot,
+			options:  options,
+			state:    query.Get(QueryParamState),
+		})
+	}
+}
+
+func parseFormatterOptions(level string, format string) (poolUtil.FormatterOptions, error) {
+	options := poolUtil.FormatterOptions{Level: poolUtil.FormatEssentials}
+	if level != "" {
+		value, ok := levelsByName[level]
+		if !ok {
+			return options, fmt.Errorf("invalid level: %s", level)
+		}
+		options.Level = value
+	}
+	switch format {
+	case "", FormatJSON:
+		options.Output = poolUtil.OutputJSON
+	case FormatHTML:
+		options.Output = poolUtil.OutputHTML
+	default:
+		return options, fmt.Errorf("invalid format: %s", format)
+	}
+	return options, nil
+}
+
+func (h *handler) serveIndex(writer http.ResponseWriter, request *http.Request) {
+	if request.URL.Path != "/" {
+		http.NotFound(writer, request)
+		return
+	}
+	links := []string{}
+	for _, path := range []string{"snapshot", "nodes", "pods", "capacity", "reservations"} {
+		links = append(links, fmt.Sprintf("<li><a href=\"%s?format=html\">%s</a></li>", path, path))
+	}
+	writeHTML(writer, "Resource pool", "<ul>\n"+strings.Join(links, "\n")+"\n</ul>\n")
+}
+
+func (h *handler) serveSnapshot(c *requestContext) {
+	text := c.snapshot.FormatResourceSnapshot(c.options)
+	if c.isHTML() {
+		writeHTML(c.writer, "Resource pool "+c.snapshot.ResourcePoolName, text)
+		return
+	}
+	writeJSON(c.writer, text)
+}
+
+func (h *handler) serveNodes(c *requestContext) {
+	nodeSnapshot := c.snapshot.NodeSnapshot
+	nodes := []*k8sCore.Node{}
+	for name, node := range nodeSnapshot.AllByName {
+		if state, _ := nodeSnapshot.NodeState(name); c.state == "" || c.state == state {
+			nodes = append(nodes, node)
+		}
+	}
+	if c.isHTML() {
+		writeHTML(c.writer, "Nodes of resource pool "+c.snapshot.ResourcePoolName,
+			poolNode.FormatNodesTable(nodes, c.snapshot.NodeBootstrapThreshold, c.options))
+		return
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	items := []string{}
+	for _, node := range nodes {
+		items = append(items, poolNode.FormatNode(node, c.snapshot.NodeBootstrapThreshold, c.options))
+	}
+	writeJSON(c.writer, "["+strings.Join(items, ",")+"]")
+}
+
+func (h *handler) servePods(c *requestContext) {
+	podSnapshot := c.snapshot.PodSnapshot
+	var collections []map[string]*k8sCore.Pod
+	switch c.state {
+	case "":
+		collections = []map[string]*k8sCore.Pod{podSnapshot.AllByName}
+	case resourcepool.PodStateQueued:
+		collections = []map[string]*k8sCore.Pod{podSnapshot.QueuedYoungByName, podSnapshot.QueuedOldByName}
+	case resourcepool.PodStateScheduled:
+		collections = []map[string]*k8sCore.Pod{podSnapshot.ScheduledByName}
+	case resourcepool.PodStateFinished:
+		collections = []map[string]*k8sCore.Pod{podSnapshot.FinishedByName}
+	default:
+		http.Error(c.writer, fmt.Sprintf("invalid pod state: %s", c.state), http.StatusBadRequest)
+		return
+	}
+	pods := []*k8sCore.Pod{}
+	for _, collection := range collections {
+		for _, pod := range collection {
+			pods = append(pods, pod)
+		}
+	}
+	if c.isHTML() {
+		writeHTML(c.writer, "Pods of resource pool "+c.snapshot.ResourcePoolName, poolPod.FormatPodsTable(pods, c.options))
+		return
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	items := []string{}
+	for _, pod := range pods {
+		items = append(items, poolPod.FormatPod(pod, c.options))
+	}
+	writeJSON(c.writer, "["+strings.Join(items, ",")+"]")
+}
+
+func (h *handler) serveCapacity(c *requestContext) {
+	_, total, byNode := resourcepool.ComputeAllocatableCapacityFromSnapshot(c.snapshot, poolV1.Zero, false, false)
+	text := resourcepool.FormatAllocatableCapacity(total, byNode, c.options)
+	if c.isHTML() {
+		writeHTML(c.writer, "Allocatable capacity of resource pool "+c.snapshot.ResourcePoolName, text)
+		return
+	}
+	writeJSON(c.writer, text)
+}
+
+func (h *handler) serveReservations(c *requestContext) {
+	if h.options.CapacityGroups == nil {
+		http.NotFound(c.writer, c.request)
+		return
+	}
+	pool := c.snapshot.ResourcePoolName
+	capacityGroups, err := h.options.CapacityGroups(c.request.Context(), pool)
+	if err != nil {
+		http.Error(c.writer, fmt.Sprintf("cannot load capacity groups: %v", err), http.StatusInternalServerError)
+		return
+	}
+	usage := reserved.NewCapacityReservationUsage(c.snapshot, capacityGroups, reserved.GetBufferCapacityGroupName(pool))
+	text := reserved.FormatCapacityReservationUsage(usage, c.options)
+	if c.isHTML() {
+		writeHTML(c.writer, "Capacity group usage of resource pool "+pool, text)
+		return
+	}
+	writeJSON(c.writer, text)
+}
+
+func writeJSON(writer http.ResponseWriter, text string) {
+	writer.Header().Set("Content-Type", "application/json")
+	_, _ = writer.Write([]byte(text))
+}
+
+func writeHTML(writer http.ResponseWriter, title string, body string) {
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	escapedTitle := html.EscapeString(title)
+	_, _ = fmt.Fprintf(writer, "<!DOCTYPE html>\n<html>\n<head><title>%s</title></head>\n<body>\n<h1>%s</h1>\n%s</body>\n</html>\n",
+		escapedTitle, escapedTitle, body)
+}
@@ -0,0 +1,102 @@
+package debug
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	k8sCore "k8s.io/api/core/v1"
+
+	capacityGroupV1 "github.com/Netflix/titus-controllers-api/api/capacitygroup/v1"
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+	"github.com/Netflix/titus-resource-pool/reserved"
+	"github.com/Netflix/titus-resource-pool/resourcepool"
+)
+
+const testPool = "testPool"
+
+func newTestHandler() http.Handler {
+	pool := resourcepool.NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 4)
+	node := poolNode.NewNode("node1", testPool, machine.R5Metal())
+	shape := pool.Spec.ResourceShape.ComputeResource
+	scheduled := poolPod.ButPodRunningOnNode(
+		poolPod.NewNotScheduledPodWithName("scheduledPod", testPool, shape, time.Now()), node)
+	queued := poolPod.NewNotScheduledPodWithName("queuedPod", testPool, shape, time.Now())
+	snapshot := resourcepool.NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{node}, []*k8sCore.Pod{scheduled, queued}, 0, 0, true)
+	return NewHandler(HandlerOptions{
+		Snapshots: func(_ context.Context, resourcePool string) (*resourcepool.ResourceSnapshot, error) {
+			return snapshot, nil
+		},
+		CapacityGroups: func(_ context.Context, resourcePool string) ([]*capacityGroupV1.CapacityGroup, error) {
+			return []*capacityGroupV1.CapacityGroup{reserved.NewCapacityGroup("cg1", testPool)}, nil
+		},
+		DefaultResourcePool: testPool,
+	})
+}
+
+func get(handler http.Handler, url string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+	return recorder
+}
+
+func TestSnapshotEndpoint(t *testing.T) {
+	response := get(newTestHandler(), "/snapshot?level=compact")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "application/json", response.Header().Get("Content-Type"))
+	require.Contains(t, response.Body.String(), "\"ActiveNodeCount\":1")
+
+	response = get(newTestHandler(), "/snapshot?format=html")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Contains(t, response.Body.String(), "<tr><td>active</td><td>1</td><td>96 cores</td>")
+}
+
+func TestNodesEndpoint(t *testing.T) {
+	response := get(newTestHandler(), "/nodes?state=active&level=compact")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "[{\"Name\":\"node1\",\"Up\":true,\"OnWayOut\":false}]", response.Body.String())
+
+	response = get(newTestHandler(), "/nodes?state=removable")
+	require.Equal(t, "[]", response.Body.String())
+}
+
+func TestPodsEndpoint(t *testing.T) {
+	response := get(newTestHandler(), "/pods?state=queued&level=compact")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "[{\"Name\":\"queuedPod\",\"State\":\"notScheduled\",\"Node\":\"\"}]", response.Body.String())
+
+	response = get(newTestHandler(), "/pods?format=html")
+	require.Contains(t, response.Body.String(), "<td>queuedPod</td>")
+	require.Contains(t, response.Body.String(), "<td>scheduledPod</td>")
+
+	response = get(newTestHandler(), "/pods?state=unknown")
+	require.Equal(t, http.StatusBadRequest, response.Code)
+}
+
+func TestCapacityEndpoint(t *testing.T) {
+	response := get(newTestHandler(), "/capacity?format=html")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Contains(t, response.Body.String(), "<tr><td>node1</td><td>72 cores</td>")
+}
+
+func TestReservationsEndpoint(t *testing.T) {
+	response := get(newTestHandler(), "/reservations")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Contains(t, response.Body.String(), "\"Unallocated\":{\"cpu\":160")
+}
+
+func TestInvalidRequests(t *testing.T) {
+	require.Equal(t, http.StatusBadRequest, get(newTestHandler(), "/snapshot?level=everything").Code)
+	require.Equal(t, http.StatusBadRequest, get(newTestHandler(), "/snapshot?format=xml").Code)
+	require.Equal(t, http.StatusNotFound, get(newTestHandler(), "/unknown").Code)
+
+	handler := NewHandler(HandlerOptions{})
+	require.Equal(t, http.StatusBadRequest, get(handler, "/snapshot").Code)
+}
@@ -0,0 +1,212 @@
+package forecast
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+)
+
+const (
+	DimensionCPU         = "cpu"
+	DimensionGPU         = "gpu"
+	DimensionMemoryMB    = "memoryMB"
+	DimensionDiskMB      = "diskMB"
+	DimensionNetworkMBPS = "networkMBPS"
+)
+
+var Dimensions = []string{DimensionCPU, DimensionGPU, DimensionMemoryMB, DimensionDiskMB, DimensionNetworkMBPS}
+
+type Options struct {
+	Model ModelType
+	// Samples are averaged in intervals of this length before a model is applied. It is also the projection step.
+	Step time.Duration
+	// How far to project.
+	Horizon time.Duration
+	// Seasonal period of the Holt-Winters model.
+	Season      time.Duration
+	HoltWinters HoltWintersParameters
+}
+
+func NewDefaultOptions() Options {
+	return Options{
+		Model:   ModelHoltWinters,
+		Step:    5 * time.Minute,
+		Horizon: 6 * time.Hour,
+		Season:  24 * time.Hour,
+		HoltWinters: HoltWintersParameters{
+			Alpha: 0.5,
+			Beta:  0.1,
+			Gamma: 0.3,
+		},
+	}
+}
+
+// SeriesProjection holds the latest observed value of a series, and its projected values at Step intervals.
+type SeriesProjection struct {
+	Current   float64
+	Projected []float64
+}
+
+// DimensionForecast holds projections of all sample series for a single resource dimension.
+type DimensionForecast struct {
+	Dimension          string
+	ActiveCapacity     SeriesProjection
+	ScheduledResources SeriesProjection
+	QueuedResources    SeriesProjection
+	ReservedAllocated  SeriesProjection
+	Demand             SeriesProjection
+	// Resource pool capacity at its maximum size (ScalingRules.MaxSize resource shapes).
+	MaxCapacity float64
+	// Set if the demand already exceeds, or is projected to reach MaxCapacity within the forecast horizon.
+	Exhausted        bool
+	TimeToExhaustion time.Duration
+}
+
+type Forecast struct {
+	ResourcePool string
+	// Time of the latest sample. Projected values are at From + Step, From + 2*Step, and so on.
+	From    time.Time
+	Step    time.Duration
+	Horizon time.Duration
+	// The model applied, which may be different from the requested one if the history is too short.
+	Model      ModelType
+	Dimensions []DimensionForecast
+	// The earliest exhaustion of all dimensions.
+	Exhausted          bool
+	ExhaustedDimension string
+	TimeToExhaustion   time.Duration
+}
+
+// NewForecast projects the resource pool sample series, and computes the time until the projected demand
+// (scheduled and queued pod resources) reaches the resource pool maximum size. Samples may be provided in any order.
+func NewForecast(resourcePool *poolV1.ResourcePoolConfig, samples []Sample, options Options) (*Forecast, error) {
+	if len(samples) == 0 {
+		return nil, errors.New("no samples to forecast from")
+	}
+	samples = append([]Sample{}, samples...)
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Timestamp.Before(samples[j].Timestamp)
+	})
+	if options.Step <= 0 || options.Horizon < options.Step {
+		return nil, fmt.Errorf("invalid step %s or horizon %s", options.Step, options.Horizon)
+	}
+	steps := int(options.Horizon / options.Step)
+	seasonLength := int(options.Season / options.Step)
+	bucketCount := int(samples[len(samples)-1].Timestamp.Sub(samples[0].Timestamp)/options.Step) + 1
+
+	model := options.Model
+	if model == ModelHoltWinters && (seasonLength < 2 || bucketCount < 2*seasonLength) {
+		model = ModelLinearTrend
+	}
+	project := func(series func(Sample) poolV1.ComputeResource, dimension int) SeriesProjection {
+		values := resample(samples, options.Step, func(sample Sample) float64 {
+			return dimensionValues(series(sample))[dimension]
+		})
+		var projected []float64
+		if model == ModelHoltWinters {
+			projected = projectHoltWinters(values, seasonLength, options.HoltWinters, steps)
+		} else {
+			projected = projectLinearTrend(values, steps)
+		}
+		for i, value := range projected {
+			if value < 0 {
+				projected[i] = 0
+			}
+		}
+		return SeriesProjection{
+			Current:   dimensionValues(series(samples[len(samples)-1]))[dimension],
+			Projected: projected,
+		}
+	}
+
+	spec := resourcePool.Spec
+	maxCapacity := dimensionValues(spec.ResourceShape.ComputeResource.Multiply(spec.ScalingRules.MaxSize))
+	forecast := Forecast{
+		ResourcePool: resourcePool.Name,
+		From:         samples[len(samples)-1].Timestamp,
+		Step:         options.Step,
+		Horizon:      time.Duration(steps) * options.Step,
+		Model:        model,
+	}
+	for i, dimension := range Dimensions {
+		dimensionForecast := DimensionForecast{
+			Dimension: dimension,
+			ActiveCapacity: project(func(sample Sample) poolV1.ComputeResource {
+				return sample.ActiveCapacity
+			}, i),
+			ScheduledResources: project(func(sample Sample) poolV1.ComputeResource {
+				return sample.ScheduledResources
+			}, i),
+			QueuedResources: project(func(sample Sample) poolV1.ComputeResource {
+				return sample.QueuedResources
+			}, i),
+			ReservedAllocated: project(func(sample Sample) poolV1.ComputeResource {
+				return sample.ReservedAllocated
+			}, i),
+			Demand:      project(Sample.Demand, i),
+			MaxCapacity: maxCapacity[i],
+		}
+		dimensionForecast.Exhausted, dimensionForecast.TimeToExhaustion = findExhaustion(dimensionForecast.Demand,
+			dimensionForecast.MaxCapacity, options.Step)
+		earlier := !forecast.Exhausted || dimensionForecast.TimeToExhaustion < forecast.TimeToExhaustion
+		if dimensionForecast.Exhausted && earlier {
+			forecast.Exhausted = true
+			forecast.ExhaustedDimension = dimension
+			forecast.TimeToExhaustion = dimensionForecast.TimeToExhaustion
+		}
+		forecast.Dimensions = append(forecast.Dimensions, dimensionForecast)
+	}
+	return &forecast, nil
+}
+
+// Dimensions with no capacity (for example GPU in a resource pool without GPUs) are never exhausted.
+func findExhaustion(demand SeriesProjection, maxCapacity float64, step time.Duration) (bool, time.Duration) {
+	if maxCapacity <= 0 {
+		return false, 0
+	}
+	if demand.Current >= maxCapacity {
+		return true, 0
+	}
+	for i, value := range demand.Projected {
+		if value >= maxCapacity {
+			return true, time.Duration(i+1) * step
+		}
+	}
+	return false, 0
+}
+
+// Averages sample values in consecutive intervals of the given length, starting from the first sample. Intervals with
+// no samples take the value of the previous interval. Samples must be ordered by time.
+func resample(samples []Sample, step time.Duration, value func(Sample) float64) []float64 {
+	start := samples[0].Timestamp
+	bucketCount := int(samples[len(samples)-1].Timestamp.Sub(start)/step) + 1
+	sums := make([]float64, bucketCount)
+	counts := make([]int, bucketCount)
+	for _, sample := range samples {
+		bucket := int(sample.Timestamp.Sub(start) / step)
+		sums[bucket] += value(sample)
+		counts[bucket]++
+	}
+	values := make([]float64, bucketCount)
+	for i := range values {
+		if counts[i] > 0 {
+			values[i] = sums[i] / float64(counts[i])
+		} else {
+			values[i] = values[i-1]
+		}
+	}
+	return values
+}
+
+func dimensionValues(resources poolV1.ComputeResource) []float64 {
+	return []float64{
+		float64(resources.CPU),
+		float64(resources.GPU),
+		float64(resources.MemoryMB),
+		float64(resources.DiskMB),
+		float64(resources.NetworkMBPS),
+	}
+}
@@ -0,0 +1,151 @@
+package forecast
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+	"github.com/Netflix/titus-resource-pool/resourcepool"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
+)
+
+const testPool = "testPool"
+
+func TestNewSample(t *testing.T) {
+	now := time.Now()
+	pool := resourcepool.NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	shape := pool.Spec.ResourceShape.ComputeResource
+	node := poolNode.NewNode("node1", testPool, machine.R5Metal())
+	scheduled := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, shape, now), node)
+	queued := poolPod.NewNotScheduledPod(testPool, shape.Multiply(2), now)
+	snapshot := resourcepool.NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{node}, []*k8sCore.Pod{scheduled, queued}, 10*time.Minute, 0, true)
+
+	sample := NewSample(snapshot, nil, now)
+	require.Equal(t, now, sample.Timestamp)
+	require.Equal(t, machine.R5Metal().Spec.ComputeResource, sample.ActiveCapacity)
+	require.Equal(t, shape, sample.ScheduledResources)
+	require.Equal(t, shape.Multiply(2), sample.QueuedResources)
+	require.Equal(t, shape.Multiply(3), sample.Demand())
+	require.Equal(t, poolV1.ComputeResource{}, sample.ReservedAllocated)
+}
+
+func TestHistoryRetention(t *testing.T) {
+	now := time.Now()
+	history := NewHistory(time.Hour)
+	history.Add(Sample{Timestamp: now.Add(-2 * time.Hour)})
+	history.Add(Sample{Timestamp: now})
+	history.Add(Sample{Timestamp: now.Add(-30 * time.Minute)})
+
+	samples := history.Samples()
+	require.Len(t, samples, 2)
+	require.Equal(t, now.Add(-30*time.Minute), samples[0].Timestamp)
+	require.Equal(t, now, samples[1].Timestamp)
+}
+
+func TestForecastLinearTrend(t *testing.T) {
+	now := time.Now()
+	// Resource shape of 24 CPUs, and maximum size of 10 shapes (240 CPUs).
+	pool := resourcepool.NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	samples := []Sample{}
+	for i := 0; i <= 12; i++ {
+		samples = append(samples, Sample{
+			Timestamp:          now.Add(time.Duration(i) * 5 * time.Minute),
+			ScheduledResources: poolV1.ComputeResource{CPU: int64(100 + 10*i)},
+		})
+	}
+
+	// History too short for the daily seasonality.
+	forecast, err := NewForecast(pool, samples, NewDefaultOptions())
+	require.NoError(t, err)
+	require.Equal(t, ModelLinearTrend, forecast.Model)
+	require.Equal(t, samples[len(samples)-1].Timestamp, forecast.From)
+	require.Len(t, forecast.Dimensions, len(Dimensions))
+
+	cpu := forecast.Dimensions[0]
+	require.Equal(t, DimensionCPU, cpu.Dimension)
+	require.Equal(t, 220.0, cpu.Demand.Current)
+	require.Len(t, cpu.Demand.Projected, 72)
+	require.InDelta(t, 230.0, cpu.Demand.Projected[0], 0.001)
+	require.Equal(t, 240.0, cpu.MaxCapacity)
+	require.True(t, cpu.Exhausted)
+	require.Equal(t, 10*time.Minute, cpu.TimeToExhaustion)
+
+	require.True(t, forecast.Exhausted)
+	require.Equal(t, DimensionCPU, forecast.ExhaustedDimension)
+	require.Equal(t, 10*time.Minute, forecast.TimeToExhaustion)
+
+	for _, dimension := range forecast.Dimensions[1:] {
+		require.False(t, dimension.Exhausted, dimension.Dimension)
+	}
+
+	require.Equal(t, "{\"ResourcePool\":\"testPool\",\"Model\":\"linearTrend\",\"Exhausted\":true,"+
+		"\"ExhaustedDimension\":\"cpu\",\"TimeToExhaustion\":\"10m0s\"}",
+		FormatForecast(forecast, poolUtil.FormatterOptions{Level: poolUtil.FormatCompact}))
+}
+
+func TestForecastHoltWinters(t *testing.T) {
+	now := time.Now()
+	pool := resourcepool.NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	options := NewDefaultOptions()
+	options.Step = time.Hour
+	options.Season = 4 * time.Hour
+	options.Horizon = 4 * time.Hour
+
+	// Daily pattern compressed to a 4 hour season, peaking at 220 CPUs.
+	pattern := []int64{100, 160, 220, 160}
+	samples := []Sample{}
+	for i := 0; i < 12; i++ {
+		samples = append(samples, Sample{
+			Timestamp:          now.Add(time.Duration(i) * time.Hour),
+			ScheduledResources: poolV1.ComputeResource{CPU: pattern[i%4]},
+		})
+	}
+	forecast, err := NewForecast(pool, samples, options)
+	require.NoError(t, err)
+	require.Equal(t, ModelHoltWinters, forecast.Model)
+	cpu := forecast.Dimensions[0]
+	for i, expected := range pattern {
+		require.InDelta(t, float64(expected), cpu.Demand.Projected[i], 0.001)
+	}
+	require.False(t, forecast.Exhausted)
+}
+
+func TestForecastInvalidInput(t *testing.T) {
+	pool := resourcepool.NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	_, err := NewForecast(pool, []Sample{}, NewDefaultOptions())
+	require.Error(t, err)
+
+	options := NewDefaultOptions()
+	options.Step = 0
+	_, err = NewForecast(pool, []Sample{{Timestamp: time.Now()}}, options)
+	require.Error(t, err)
+}
+
+func TestForecastUnorderedSamples(t *testing.T) {
+	now := time.Now()
+	pool := resourcepool.NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	samples := []Sample{}
+	for i := 12; i >= 0; i-- {
+		samples = append(samples, Sample{
+			Timestamp:          now.Add(time.Duration(i) * 5 * time.Minute),
+			ScheduledResources: poolV1.ComputeResource{CPU: int64(100 + 10*i)},
+		})
+	}
+
+	forecast, err := NewForecast(pool, samples, NewDefaultOptions())
+	require.NoError(t, err)
+	require.Equal(t, samples[0].Timestamp, forecast.From)
+	require.Equal(t, 220.0, forecast.Dimensions[0].Demand.Current)
+	require.InDelta(t, 230.0, forecast.Dimensions[0].Demand.Projected[0], 0.001)
+	// The input is not modified.
+	require.Equal(t, now.Add(time.Hour), samples[0].Timestamp)
+}
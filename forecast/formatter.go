@@ -0,0 +1,74 @@
+package forecast
+
+import (
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
+)
+
+func FormatForecast(forecast *Forecast, options poolUtil.FormatterOptions) string {
+	if options.Level == poolUtil.FormatCompact {
+		return formatForecastCompact(forecast)
+	} else if options.Level == poolUtil.FormatEssentials {
+		return formatForecastEssentials(forecast)
+	} else if options.Level == poolUtil.FormatDetails {
+		return poolUtil.ToJSONString(forecast)
+	}
+	return formatForecastCompact(forecast)
+}
+
+func formatForecastCompact(forecast *Forecast) string {
+	type Compact struct {
+		ResourcePool       string
+		Model              ModelType
+		Exhausted          bool
+		ExhaustedDimension string `json:",omitempty"`
+		TimeToExhaustion   string `json:",omitempty"`
+	}
+	value := Compact{
+		ResourcePool: forecast.ResourcePool,
+		Model:        forecast.Model,
+		Exhausted:    forecast.Exhausted,
+	}
+	if forecast.Exhausted {
+		value.ExhaustedDimension = forecast.ExhaustedDimension
+		value.TimeToExhaustion = forecast.TimeToExhaustion.String()
+	}
+	return poolUtil.ToJSONString(value)
+}
+
+func formatForecastEssentials(forecast *Forecast) string {
+	type Dimension struct {
+		Dimension        string
+		Demand           float64
+		ProjectedDemand  float64
+		MaxCapacity      float64
+		TimeToExhaustion string `json:",omitempty"`
+	}
+	type Essentials struct {
+		ResourcePool string
+		Model        ModelType
+		Horizon      string
+		Dimensions   []Dimension
+	}
+	value := Essentials{
+		ResourcePool: forecast.ResourcePool,
+		Model:        forecast.Model,
+		Horizon:      forecast.Horizon.String(),
+		Dimensions:   []Dimension{},
+	}
+	for _, dimensionForecast := range forecast.Dimensions {
+		projected := dimensionForecast.Demand.Projected
+		dimension := Dimension{
+			Dimension:   dimensionForecast.Dimension,
+			Demand:      dimensionForecast.Demand.Current,
+			MaxCapacity: dimensionForecast.MaxCapacity,
+		}
+		if len(projected) > 0 {
+			dimension.ProjectedDemand = projected[len(projected)-1]
+		}
+		if dimensionForecast.Exhausted {
+			dimension.TimeToExhaustion = dimensionForecast.TimeToExhaustion.String()
+		}
+		value.Dimensions = append(value.Dimensions, dimension)
+	}
+	return poolUtil.ToJSONString(value)
+}
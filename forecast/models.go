@@ -0,0 +1,79 @@
+package forecast
+
+type ModelType string
+
+const (
+	// Least squares linear trend over the whole history.
+	ModelLinearTrend ModelType = "linearTrend"
+	// Additive Holt-Winters (triple exponential smoothing) with a seasonal period. If the history is shorter than
+	// two periods, the linear trend model is used instead.
+	ModelHoltWinters ModelType = "holtWinters"
+)
+
+// HoltWintersParameters are smoothing factors of the level, trend and seasonal components, all in the [0, 1] range.
+type HoltWintersParameters struct {
+	Alpha float64
+	Beta  float64
+	Gamma float64
+}
+
+// Returns the linear trend projection of an evenly spaced series for the next `steps` points. A series with a single
+// value is projected as a constant.
+func projectLinearTrend(values []float64, steps int) []float64 {
+	n := float64(len(values))
+	var sumX, sumY, sumXY, sumXX float64
+	for i, value := range values {
+		x := float64(i)
+		sumX += x
+		sumY += value
+		sumXY += x * value
+		sumXX += x * x
+	}
+	slope := 0.0
+	if denominator := n*sumXX - sumX*sumX; denominator != 0 {
+		slope = (n*sumXY - sumX*sumY) / denominator
+	}
+	intercept := (sumY - slope*sumX) / n
+
+	result := make([]float64, steps)
+	last := len(values) - 1
+	for h := 1; h <= steps; h++ {
+		result[h-1] = intercept + slope*float64(last+h)
+	}
+	return result
+}
+
+// Returns the additive Holt-Winters projection of an evenly spaced series for the next `steps` points. The series must
+// contain at least two full seasons.
+func projectHoltWinters(values []float64, seasonLength int, parameters HoltWintersParameters, steps int) []float64 {
+	firstSeasonMean := mean(values[:seasonLength])
+	secondSeasonMean := mean(values[seasonLength : 2*seasonLength])
+	level := firstSeasonMean
+	trend := (secondSeasonMean - firstSeasonMean) / float64(seasonLength)
+	seasonal := make([]float64, seasonLength)
+	for i := 0; i < seasonLength; i++ {
+		seasonal[i] = values[i] - firstSeasonMean
+	}
+
+	for t, value := range values {
+		season := seasonal[t%seasonLength]
+		previousLevel := level
+		level = parameters.Alpha*(value-season) + (1-parameters.Alpha)*(level+trend)
+		trend = parameters.Beta*(level-previousLevel) + (1-parameters.Beta)*trend
+		seasonal[t%seasonLength] = parameters.Gamma*(value-level) + (1-parameters.Gamma)*season
+	}
+
+	result := make([]float64, steps)
+	for h := 1; h <= steps; h++ {
+		result[h-1] = level + float64(h)*trend + seasonal[(len(values)+h-1)%seasonLength]
+	}
+	return result
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
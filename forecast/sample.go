@@ -0,0 +1,99 @@
+package forecast
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+	"github.com/Netflix/titus-resource-pool/reserved"
+	"github.com/Netflix/titus-resource-pool/resourcepool"
+)
+
+// Sample holds resource snapshot aggregates observed at a given time.
+type Sample struct {
+	Timestamp      time.Time
+	ActiveCapacity poolV1.ComputeResource
+	// Resources requested by pods running in the resource pool.
+	ScheduledResources poolV1.ComputeResource
+	// Resources requested by pods waiting to be scheduled.
+	QueuedResources poolV1.ComputeResource
+	// Resources allocated to capacity group reservations (including the buffer).
+	ReservedAllocated poolV1.ComputeResource
+}
+
+// Resources the resource pool must provide to run all its pods.
+func (sample Sample) Demand() poolV1.ComputeResource {
+	return sample.ScheduledResources.Add(sample.QueuedResources)
+}
+
+// NewSample returns the snapshot aggregates. The capacity reservation usage is optional, and can be set to nil.
+func NewSample(snapshot *resourcepool.ResourceSnapshot, usage *reserved.CapacityReservationUsage,
+	now time.Time) Sample {
+	podSnapshot := snapshot.PodSnapshot
+	sample := Sample{
+		Timestamp:          now,
+		ActiveCapacity:     snapshot.ActiveCapacity(),
+		ScheduledResources: sumPodResources(podSnapshot.ScheduledByName, podSnapshot),
+		QueuedResources: sumPodResources(podSnapshot.QueuedYoungByName, podSnapshot).
+			Add(sumPodResources(podSnapshot.QueuedOldByName, podSnapshot)),
+	}
+	if usage != nil {
+		sample.ReservedAllocated = usage.AllReserved.Allocated
+	}
+	return sample
+}
+
+func sumPodResources(pods map[string]*k8sCore.Pod, podSnapshot *poolPod.Snapshot) poolV1.ComputeResource {
+	sum := poolV1.ComputeResource{}
+	for name := range pods {
+		if metadata, ok := podSnapshot.Metadata[name]; ok {
+			sum = sum.Add(metadata.PodResources)
+		}
+	}
+	return sum
+}
+
+// History keeps samples of a single resource pool observed within the retention period. It is safe to use it
+// concurrently.
+type History struct {
+	lock      sync.Mutex
+	retention time.Duration
+	samples   []Sample
+}
+
+// Creates an empty history. If retention is zero, samples are never removed.
+func NewHistory(retention time.Duration) *History {
+	return &History{retention: retention}
+}
+
+// Add records a new sample, and removes samples older than the retention period counting from the latest sample.
+// Samples may be added out of order.
+func (history *History) Add(sample Sample) {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+
+	history.samples = append(history.samples, sample)
+	sort.SliceStable(history.samples, func(i, j int) bool {
+		return history.samples[i].Timestamp.Before(history.samples[j].Timestamp)
+	})
+	if history.retention <= 0 {
+		return
+	}
+	deadline := history.samples[len(history.samples)-1].Timestamp.Add(-history.retention)
+	first := 0
+	for first < len(history.samples) && history.samples[first].Timestamp.Before(deadline) {
+		first++
+	}
+	history.samples = history.samples[first:]
+}
+
+// Samples returns a copy of the recorded samples ordered by time.
+func (history *History) Samples() []Sample {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+	return append([]Sample{}, history.samples...)
+}
@@ -1,12 +1,17 @@
 package machine
 
 import (
+	"sort"
+
 	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
 	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
 	poolUtil "github.com/Netflix/titus-resource-pool/util"
 )
 
 func FormatMachineType(machineType *machineTypeV1.MachineTypeConfig, options poolUtil.FormatterOptions) string {
+	if options.IsTableOutput() {
+		return FormatMachineTypesTable([]*machineTypeV1.MachineTypeConfig{machineType}, options)
+	}
 	if options.Level != poolUtil.FormatDetails {
 		return formatMachineTypeCompact(machineType)
 	}
@@ -24,3 +29,18 @@ func formatMachineTypeCompact(machineType *machineTypeV1.MachineTypeConfig) stri
 	}
 	return poolUtil.ToJSONString(value)
 }
+
+// FormatMachineTypesTable renders machine types as a text table, sorted by name unless options.SortBy is set.
+func FormatMachineTypesTable(machineTypes []*machineTypeV1.MachineTypeConfig, options poolUtil.FormatterOptions) string {
+	sorted := make([]*machineTypeV1.MachineTypeConfig, len(machineTypes))
+	copy(sorted, machineTypes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	table := poolUtil.NewTable(append([]string{"NAME"}, poolUtil.ComputeResourceColumns...)...)
+	for _, machineType := range sorted {
+		table.AddCells(append(poolUtil.Cells(machineType.Name),
+			poolUtil.ComputeResourceCells(machineType.Spec.ComputeResource)...)...)
+	}
+	return table.Render(options)
+}
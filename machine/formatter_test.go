@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
 	. "github.com/Netflix/titus-resource-pool/util"
 )
 
@@ -19,3 +20,16 @@ func TestFormatMachineTypeCompact(t *testing.T) {
 		text,
 	)
 }
+
+func TestFormatMachineTypesTable(t *testing.T) {
+	text := FormatMachineTypesTable(
+		[]*machineTypeV1.MachineTypeConfig{R5Metal(), M5Metal()},
+		FormatterOptions{Output: OutputTable},
+	)
+	require.Equal(t, ""+
+		"NAME      CPU       GPU  MEMORY  DISK    NETWORK\n"+
+		"m5.metal  96 cores  0    384GiB  1TiB    25Gbps\n"+
+		"r5.metal  96 cores  0    768GiB  1.5TiB  25Gbps\n",
+		text,
+	)
+}
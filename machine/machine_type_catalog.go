@@ -0,0 +1,166 @@
+package machine
+
+import (
+	"fmt"
+	"sort"
+
+	machineV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+)
+
+// MachineTypeProblem describes a single machine type configuration issue.
+type MachineTypeProblem struct {
+	MachineType string
+	Message     string
+}
+
+// Catalog indexes machine types by both the object name and the spec name. Nodes refer to machine types by their
+// instance type label, which usually equals both of them, but there is nothing enforcing it.
+type Catalog struct {
+	machineTypes []*machineV1.MachineTypeConfig
+	byObjectName map[string]*machineV1.MachineTypeConfig
+	bySpecName   map[string]*machineV1.MachineTypeConfig
+}
+
+func NewCatalog(machineTypes []*machineV1.MachineTypeConfig) *Catalog {
+	catalog := &Catalog{
+		machineTypes: []*machineV1.MachineTypeConfig{},
+		byObjectName: map[string]*machineV1.MachineTypeConfig{},
+		bySpecName:   map[string]*machineV1.MachineTypeConfig{},
+	}
+	for _, machineType := range machineTypes {
+		catalog.machineTypes = append(catalog.machineTypes, machineType)
+		if _, ok := catalog.byObjectName[machineType.Name]; !ok {
+			catalog.byObjectName[machineType.Name] = machineType
+		}
+		if machineType.Spec.Name != "" {
+			if _, ok := catalog.bySpecName[machineType.Spec.Name]; !ok {
+				catalog.bySpecName[machineType.Spec.Name] = machineType
+			}
+		}
+	}
+	return catalog
+}
+
+func (c *Catalog) All() []*machineV1.MachineTypeConfig {
+	return c.machineTypes
+}
+
+// Find returns a machine type with the given object name or, if there is none, with the given spec name.
+func (c *Catalog) Find(name string) (*machineV1.MachineTypeConfig, bool) {
+	if machineType, ok := c.byObjectName[name]; ok {
+		return machineType, true
+	}
+	machineType, ok := c.bySpecName[name]
+	return machineType, ok
+}
+
+func (c *Catalog) FindByObjectName(name string) (*machineV1.MachineTypeConfig, bool) {
+	machineType, ok := c.byObjectName[name]
+	return machineType, ok
+}
+
+func (c *Catalog) FindBySpecName(name string) (*machineV1.MachineTypeConfig, bool) {
+	machineType, ok := c.bySpecName[name]
+	return machineType, ok
+}
+
+// AsMap returns a map with machine types keyed by both the object and the spec names. If the object name of one
+// machine type is the same as the spec name of another one, the object name takes precedence.
+func (c *Catalog) AsMap() map[string]*machineV1.MachineTypeConfig {
+	result := map[string]*machineV1.MachineTypeConfig{}
+	for name, machineType := range c.bySpecName {
+		result[name] = machineType
+	}
+	for name, machineType := range c.byObjectName {
+		result[name] = machineType
+	}
+	return result
+}
+
+// FindUnknown returns names not resolvable by Find.
+func (c *Catalog) FindUnknown(names []string) []string {
+	unknown := []string{}
+	for _, name := range names {
+		if _, ok := c.Find(name); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// Validate checks all machine types, and returns the list of found problems sorted by the machine type name.
+// Duplicate object or spec names are reported as well.
+func (c *Catalog) Validate() []MachineTypeProblem {
+	problems := []MachineTypeProblem{}
+	objectNames := map[string]int{}
+	specNames := map[string]int{}
+	for _, machineType := range c.machineTypes {
+		problems = append(problems, ValidateMachineType(machineType)...)
+		objectNames[machineType.Name]++
+		if machineType.Spec.Name != "" {
+			specNames[machineType.Spec.Name]++
+		}
+	}
+	for name, count := range objectNames {
+		if count > 1 {
+			problems = append(problems, MachineTypeProblem{
+				MachineType: name,
+				Message:     fmt.Sprintf("object name used by %d machine types", count),
+			})
+		}
+	}
+	for name, count := range specNames {
+		if count > 1 {
+			problems = append(problems, MachineTypeProblem{
+				MachineType: name,
+				Message:     fmt.Sprintf("spec name used by %d machine types", count),
+			})
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].MachineType != problems[j].MachineType {
+			return problems[i].MachineType < problems[j].MachineType
+		}
+		return problems[i].Message < problems[j].Message
+	})
+	return problems
+}
+
+// ValidateMachineType checks that a machine type has a name, non-zero CPU, memory, disk and network resources, and
+// a sensible GPU count.
+func ValidateMachineType(machineType *machineV1.MachineTypeConfig) []MachineTypeProblem {
+	problems := []MachineTypeProblem{}
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, MachineTypeProblem{
+			MachineType: machineType.Name,
+			Message:     fmt.Sprintf(format, args...),
+		})
+	}
+	spec := machineType.Spec
+	if machineType.Name == "" {
+		add("object name not set")
+	}
+	if spec.Name == "" {
+		add("spec name not set")
+	} else if spec.Name != machineType.Name {
+		add("spec name %s different from object name", spec.Name)
+	}
+	if spec.CPU <= 0 {
+		add("CPU must be greater than zero")
+	}
+	if spec.MemoryMB <= 0 {
+		add("memory must be greater than zero")
+	}
+	if spec.DiskMB <= 0 {
+		add("disk must be greater than zero")
+	}
+	if spec.NetworkMBPS <= 0 {
+		add("network must be greater than zero")
+	}
+	if spec.GPU < 0 {
+		add("GPU count must not be negative")
+	} else if spec.GPU > spec.CPU {
+		add("GPU count %d is greater than CPU count %d", spec.GPU, spec.CPU)
+	}
+	return problems
+}
@@ -0,0 +1,48 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	machineV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+)
+
+func TestCatalogLookup(t *testing.T) {
+	renamed := R5Metal()
+	renamed.Name = "test.r5"
+	catalog := NewCatalog([]*machineV1.MachineTypeConfig{renamed, M5Metal()})
+
+	found, ok := catalog.Find("test.r5")
+	require.True(t, ok)
+	require.Equal(t, renamed, found)
+	found, ok = catalog.Find("r5.metal")
+	require.True(t, ok)
+	require.Equal(t, renamed, found)
+	_, ok = catalog.FindByObjectName("r5.metal")
+	require.False(t, ok)
+	_, ok = catalog.Find("c5.metal")
+	require.False(t, ok)
+
+	asMap := catalog.AsMap()
+	require.Len(t, asMap, 3)
+	require.Equal(t, renamed, asMap["r5.metal"])
+	require.Equal(t, []string{"c5.metal"}, catalog.FindUnknown([]string{"r5.metal", "c5.metal", "m5.metal"}))
+}
+
+func TestCatalogValidation(t *testing.T) {
+	require.Empty(t, NewCatalog([]*machineV1.MachineTypeConfig{R5Metal(), M5Metal()}).Validate())
+
+	noMemory := M5Metal()
+	noMemory.Spec.MemoryMB = 0
+	tooManyGPUs := R5Metal()
+	tooManyGPUs.Spec.GPU = tooManyGPUs.Spec.CPU + 1
+	problems := NewCatalog([]*machineV1.MachineTypeConfig{noMemory, tooManyGPUs, R5Metal()}).Validate()
+
+	require.Len(t, problems, 4)
+	require.Equal(t, MachineTypeProblem{MachineType: "m5.metal", Message: "memory must be greater than zero"}, problems[0])
+	require.Equal(t, "r5.metal", problems[1].MachineType)
+	require.Contains(t, problems[1].Message, "GPU count")
+	require.Equal(t, "object name used by 2 machine types", problems[2].Message)
+	require.Equal(t, "spec name used by 2 machine types", problems[3].Message)
+}
@@ -0,0 +1,414 @@
+package node
+
+import (
+	"fmt"
+	"time"
+
+	k8sCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	commonNode "stash.corp.netflix.com/tn/titus-kube-common/node"
+)
+
+// NodeMatcher describes node properties. All non-empty fields must match for a node to be matched. A matcher with
+// no fields set matches nothing.
+type NodeMatcher struct {
+	// Taint key. If empty and TaintEffects is set, a taint with any key matches.
+	TaintKey string `json:"taintKey,omitempty"`
+	// Accepted taint effects. If empty, a taint with any effect matches.
+	TaintEffects []k8sCore.TaintEffect `json:"taintEffects,omitempty"`
+	// Label that must be present on a node.
+	Label string `json:"label,omitempty"`
+	// Optional label value. If empty, only the label presence is checked.
+	LabelValue string `json:"labelValue,omitempty"`
+	// Node condition type, like Ready or MemoryPressure.
+	Condition k8sCore.NodeConditionType `json:"condition,omitempty"`
+	// Accepted condition statuses. If empty, ConditionTrue is assumed. A node without the condition is regarded
+	// as having it in ConditionUnknown status.
+	ConditionStatuses []k8sCore.ConditionStatus `json:"conditionStatuses,omitempty"`
+	// Matches if allocatable amount of any of the listed resources is below the given threshold.
+	AllocatableBelow k8sCore.ResourceList `json:"allocatableBelow,omitempty"`
+}
+
+// NodeStateRules maps node properties to node states. A node is in a given state if any of its matchers matches.
+type NodeStateRules struct {
+	// Nodes that are explicitly marked as initializing.
+	Bootstrapping []NodeMatcher `json:"bootstrapping,omitempty"`
+	// Nodes in a bad state. Young nodes in this state are regarded as bootstrapping.
+	Broken []NodeMatcher `json:"broken,omitempty"`
+	// Nodes that are decommissioned, and no longer run any workloads.
+	Decommissioned []NodeMatcher `json:"decommissioned,omitempty"`
+	// Nodes that are decommissioned, but are still schedulable for most workloads.
+	PhasedOut []NodeMatcher `json:"phasedOut,omitempty"`
+	// Nodes selected for removal by the cluster scaler.
+	ScalingDown []NodeMatcher `json:"scalingDown,omitempty"`
+	// Nodes to be removed for other reasons than decommissioning or scaling down (for example evacuated nodes).
+	ToRemove []NodeMatcher `json:"toRemove,omitempty"`
+	// Nodes that can be terminated.
+	Removable []NodeMatcher `json:"removable,omitempty"`
+	// Nodes with no backing instance.
+	Terminated []NodeMatcher `json:"terminated,omitempty"`
+	// Node conditions evaluation.
+	Health NodeHealthRules `json:"health,omitempty"`
+}
+
+// NodeHealthRules defines which node conditions make a node unhealthy.
+type NodeHealthRules struct {
+	// How long the Ready condition may be False or Unknown before a node is regarded as unhealthy. Nodes that
+	// have not reported the Ready condition yet are not regarded as unhealthy.
+	NotReadyGracePeriod metaV1.Duration `json:"notReadyGracePeriod,omitempty"`
+	// Conditions that make a node unhealthy when in True status (for example MemoryPressure).
+	UnhealthyConditions []k8sCore.NodeConditionType `json:"unhealthyConditions,omitempty"`
+}
+
+// StateClassifier resolves node states using configurable rules.
+type StateClassifier struct {
+	rules                 NodeStateRules
+	instanceStateProvider InstanceStateProvider
+}
+
+// DefaultNodeStateRules returns rules matching the built-in node state evaluation.
+func DefaultNodeStateRules() NodeStateRules {
+	return NodeStateRules{
+		Bootstrapping: []NodeMatcher{
+			{TaintKey: commonNode.TaintKeyInit},
+		},
+		Broken: []NodeMatcher{
+			{TaintEffects: []k8sCore.TaintEffect{k8sCore.TaintEffectNoExecute}},
+			// It happens that there are node objects registered with no resources
+			{AllocatableBelow: k8sCore.ResourceList{k8sCore.ResourceCPU: resource.MustParse("1m")}},
+		},
+		Decommissioned: []NodeMatcher{
+			{
+				TaintKey:     commonNode.TaintKeyNodeDecommissioning,
+				TaintEffects: []k8sCore.TaintEffect{k8sCore.TaintEffectNoExecute},
+			},
+		},
+		PhasedOut: []NodeMatcher{
+			{
+				TaintKey: commonNode.TaintKeyNodeDecommissioning,
+				TaintEffects: []k8sCore.TaintEffect{
+					k8sCore.TaintEffectPreferNoSchedule,
+					k8sCore.TaintEffectNoSchedule,
+				},
+			},
+		},
+		ScalingDown: []NodeMatcher{
+			{TaintKey: commonNode.TaintKeyNodeScalingDown},
+		},
+		ToRemove: []NodeMatcher{
+			{
+				TaintKey:     commonNode.TaintKeyNodeEvacuate,
+				TaintEffects: []k8sCore.TaintEffect{k8sCore.TaintEffectNoExecute},
+			},
+		},
+		Removable: []NodeMatcher{
+			{Label: commonNode.LabelKeyRemovable},
+		},
+		Health: NodeHealthRules{
+			NotReadyGracePeriod: metaV1.Duration{Duration: 5 * time.Minute},
+			UnhealthyConditions: []k8sCore.NodeConditionType{
+				k8sCore.NodeMemoryPressure,
+				k8sCore.NodeDiskPressure,
+				k8sCore.NodePIDPressure,
+				k8sCore.NodeNetworkUnavailable,
+			},
+		},
+	}
+}
+
+var defaultStateClassifier = NewStateClassifier(DefaultNodeStateRules())
+
+// Creates a classifier which uses the given rules, and the node lifecycle based instance state provider.
+func NewStateClassifier(rules NodeStateRules) *StateClassifier {
+	return &StateClassifier{
+		rules:                 rules,
+		instanceStateProvider: defaultInstanceStateProvider,
+	}
+}
+
+func NewDefaultStateClassifier() *StateClassifier {
+	return defaultStateClassifier
+}
+
+// Creates a classifier from rules in YAML (or JSON) format. Field names are the same as in the JSON tags of
+// NodeStateRules. If the health section is missing, the health rules of DefaultNodeStateRules are used.
+func NewStateClassifierFromYAML(data []byte) (*StateClassifier, error) {
+	rules := NodeStateRules{}
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, fmt.Errorf("cannot parse node state rules: %w", err)
+	}
+	sections := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("cannot parse node state rules: %w", err)
+	}
+	if _, ok := sections["health"]; !ok {
+		rules.Health = DefaultNodeStateRules().Health
+	}
+	return NewStateClassifier(rules), nil
+}
+
+// Returns a copy of this classifier that uses the given provider for finding terminated nodes.
+func (c *StateClassifier) WithInstanceStateProvider(provider InstanceStateProvider) *StateClassifier {
+	return &StateClassifier{
+		rules:                 c.rules,
+		instanceStateProvider: provider,
+	}
+}
+
+func (c *StateClassifier) Rules() NodeStateRules {
+	return c.rules
+}
+
+// Resolve node state.
+func (c *StateClassifier) UniqueNodeState(node *k8sCore.Node, now time.Time, ageThreshold time.Duration) string {
+	if c.IsNodeTerminatedAt(node, now) {
+		return NodeStateTerminated
+	}
+	if c.IsNodeBootstrapping(node, now, ageThreshold) {
+		return NodeStateBootstrapping
+	}
+	if c.IsNodeAvailableForScheduling(node, now, ageThreshold) {
+		return NodeStateActive
+	}
+	if c.IsNodeDecommissioned(node) {
+		return NodeStateDecommissioned
+	}
+	if c.IsNodePhasedOut(node) {
+		return NodeStatePhasedOut
+	}
+	if c.IsNodeScalingDown(node) {
+		return NodeStateScalingDown
+	}
+	if c.IsNodeRemovable(node) {
+		return NodeStateRemovable
+	}
+	if c.IsNodeUnhealthy(node, now) {
+		return NodeStateUnhealthy
+	}
+	return NodeStateBroken
+}
+
+// Returns true for a new node that is still bootstrapping. `ageThreshold` is a time limit for a node to be
+// regarded as new.
+func (c *StateClassifier) IsNodeBootstrapping(node *k8sCore.Node, now time.Time, ageThreshold time.Duration) bool {
+	return c.IsNodeBootstrapping2(node, func(node *k8sCore.Node) bool {
+		return node.CreationTimestamp.Add(ageThreshold).Before(now)
+	})
+}
+
+func (c *StateClassifier) IsNodeBootstrapping2(node *k8sCore.Node, pastDeadline func(*k8sCore.Node) bool) bool {
+	// Nodes explicitly marked as initializing.
+	if matchesAny(node, c.rules.Bootstrapping) {
+		return true
+	}
+
+	if pastDeadline(node) {
+		return false
+	}
+
+	// Getting here does not guarantee (at least at the time of writing this change), that the new node is
+	// fully initialized and ready to take traffic. We make here a few heuristic guesses to improve th evaluation
+	// accuracy. A young node that is not ready yet is still bootstrapping, so no grace period is applied here.
+	return c.IsNodeBroken(node) || c.IsNodeNotReady(node)
+}
+
+func (c *StateClassifier) IsNodeBroken(node *k8sCore.Node) bool {
+	return matchesAny(node, c.rules.Broken)
+}
+
+func (c *StateClassifier) IsNodeAvailableForScheduling(node *k8sCore.Node, now time.Time, ageThreshold time.Duration) bool {
+	return !c.IsNodeBootstrapping(node, now, ageThreshold) &&
+		!c.IsNodeToRemove(node) &&
+		!c.IsNodeRemovable(node) &&
+		!c.IsNodeTerminatedAt(node, now) &&
+		!c.IsNodeUnhealthy(node, now)
+}
+
+func (c *StateClassifier) IsNodeOnItsWayOut(node *k8sCore.Node) bool {
+	return c.IsNodeToRemove(node) || c.IsNodeRemovable(node) || c.IsNodeTerminated(node)
+}
+
+func (c *StateClassifier) IsNodeDecommissioned(node *k8sCore.Node) bool {
+	return matchesAny(node, c.rules.Decommissioned)
+}
+
+func (c *StateClassifier) IsNodePhasedOut(node *k8sCore.Node) bool {
+	return matchesAny(node, c.rules.PhasedOut)
+}
+
+func (c *StateClassifier) IsNodeScalingDown(node *k8sCore.Node) bool {
+	return matchesAny(node, c.rules.ScalingDown)
+}
+
+// DrainTaints returns node taints matched by the taint conditions of the decommissioned and scaling down rules.
+// Phased out nodes are still schedulable, so they are not regarded as draining. Matchers with no taint conditions
+// are ignored.
+func (c *StateClassifier) DrainTaints(node *k8sCore.Node) []*k8sCore.Taint {
+	result := []*k8sCore.Taint{}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		for _, matchers := range [][]NodeMatcher{c.rules.Decommissioned, c.rules.ScalingDown} {
+			if matchesAnyTaint(taint, matchers) {
+				result = append(result, taint)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (c *StateClassifier) IsNodeToRemove(node *k8sCore.Node) bool {
+	return c.IsNodeDecommissioned(node) || c.IsNodeScalingDown(node) || matchesAny(node, c.rules.ToRemove)
+}
+
+func (c *StateClassifier) IsNodeRemovable(node *k8sCore.Node) bool {
+	return matchesAny(node, c.rules.Removable)
+}
+
+func (c *StateClassifier) IsNodeTerminated(node *k8sCore.Node) bool {
+	return c.IsNodeTerminatedAt(node, time.Now())
+}
+
+// Returns true if the node matches the terminated node rules, or the instance state provider reports its instance
+// as terminated.
+func (c *StateClassifier) IsNodeTerminatedAt(node *k8sCore.Node, now time.Time) bool {
+	if matchesAny(node, c.rules.Terminated) {
+		return true
+	}
+	return c.instanceStateProvider != nil && c.instanceStateProvider.IsInstanceTerminated(node, now)
+}
+
+// Returns true if the node is not ready for longer than the grace period, or has any of the unhealthy conditions.
+func (c *StateClassifier) IsNodeUnhealthy(node *k8sCore.Node, now time.Time) bool {
+	return len(c.UnhealthyReasons(node, now)) > 0
+}
+
+// Returns true if the node is not ready, or has any of the unhealthy conditions. Unlike IsNodeUnhealthy, the not
+// ready grace period is not applied.
+func (c *StateClassifier) IsNodeNotReady(node *k8sCore.Node) bool {
+	return len(c.unhealthyReasons(node, func(*k8sCore.NodeCondition) bool { return true })) > 0
+}
+
+// Returns the list of node conditions that make the node unhealthy, or an empty list for a healthy node.
+func (c *StateClassifier) UnhealthyReasons(node *k8sCore.Node, now time.Time) []string {
+	return c.unhealthyReasons(node, func(ready *k8sCore.NodeCondition) bool {
+		return !ready.LastTransitionTime.Add(c.rules.Health.NotReadyGracePeriod.Duration).After(now)
+	})
+}
+
+func (c *StateClassifier) unhealthyReasons(node *k8sCore.Node,
+	pastGracePeriod func(*k8sCore.NodeCondition) bool) []string {
+	reasons := []string{}
+	if ready := FindCondition(node, k8sCore.NodeReady); ready != nil && ready.Status != k8sCore.ConditionTrue {
+		if pastGracePeriod(ready) {
+			reasons = append(reasons, fmt.Sprintf("%s=%s", ready.Type, ready.Status))
+		}
+	}
+	for _, conditionType := range c.rules.Health.UnhealthyConditions {
+		if condition := FindCondition(node, conditionType); condition != nil && condition.Status == k8sCore.ConditionTrue {
+			reasons = append(reasons, fmt.Sprintf("%s=%s", condition.Type, condition.Status))
+		}
+	}
+	return reasons
+}
+
+func matchesAny(node *k8sCore.Node, matchers []NodeMatcher) bool {
+	for _, matcher := range matchers {
+		if matcher.Matches(node) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m NodeMatcher) IsEmpty() bool {
+	return m.TaintKey == "" && len(m.TaintEffects) == 0 && m.Label == "" && m.Condition == "" &&
+		len(m.AllocatableBelow) == 0
+}
+
+// Returns true if all properties set in the matcher are matched by the node.
+func (m NodeMatcher) Matches(node *k8sCore.Node) bool {
+	if m.IsEmpty() {
+		return false
+	}
+	if (m.TaintKey != "" || len(m.TaintEffects) > 0) && !m.matchesTaint(node) {
+		return false
+	}
+	if m.Label != "" && !m.matchesLabel(node) {
+		return false
+	}
+	if m.Condition != "" && !m.matchesCondition(node) {
+		return false
+	}
+	if len(m.AllocatableBelow) > 0 && !m.matchesAllocatable(node) {
+		return false
+	}
+	return true
+}
+
+func (m NodeMatcher) matchesTaint(node *k8sCore.Node) bool {
+	for i := range node.Spec.Taints {
+		if m.matchesTaintOf(&node.Spec.Taints[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m NodeMatcher) matchesTaintOf(taint *k8sCore.Taint) bool {
+	if m.TaintKey != "" && taint.Key != m.TaintKey {
+		return false
+	}
+	if len(m.TaintEffects) == 0 {
+		return true
+	}
+	for _, effect := range m.TaintEffects {
+		if taint.Effect == effect {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyTaint(taint *k8sCore.Taint, matchers []NodeMatcher) bool {
+	for _, matcher := range matchers {
+		if (matcher.TaintKey != "" || len(matcher.TaintEffects) > 0) && matcher.matchesTaintOf(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m NodeMatcher) matchesLabel(node *k8sCore.Node) bool {
+	value, ok := node.Labels[m.Label]
+	return ok && (m.LabelValue == "" || m.LabelValue == value)
+}
+
+func (m NodeMatcher) matchesCondition(node *k8sCore.Node) bool {
+	status := k8sCore.ConditionUnknown
+	if condition := FindCondition(node, m.Condition); condition != nil {
+		status = condition.Status
+	}
+	if len(m.ConditionStatuses) == 0 {
+		return status == k8sCore.ConditionTrue
+	}
+	for _, expected := range m.ConditionStatuses {
+		if status == expected {
+			return true
+		}
+	}
+	return false
+}
+
+func (m NodeMatcher) matchesAllocatable(node *k8sCore.Node) bool {
+	for resourceName, threshold := range m.AllocatableBelow {
+		allocatable := node.Status.Allocatable[resourceName]
+		if allocatable.Cmp(threshold) < 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/Netflix/titus-resource-pool/machine"
+	commonNode "stash.corp.netflix.com/tn/titus-kube-common/node"
+)
+
+func TestDefaultStateClassifier(t *testing.T) {
+	now := time.Now()
+	classifier := NewDefaultStateClassifier()
+
+	active := NewRandomNode()
+	require.Equal(t, NodeStateActive, classifier.UniqueNodeState(active, now, time.Minute))
+
+	initializing := ButNodeWithTaint(NewRandomNode(), &k8sCore.Taint{Key: commonNode.TaintKeyInit, Effect: k8sCore.TaintEffectNoSchedule})
+	require.Equal(t, NodeStateBootstrapping, classifier.UniqueNodeState(initializing, now, time.Minute))
+
+	noResources := NewRandomNode(func(node *k8sCore.Node) {
+		node.Status.Allocatable[k8sCore.ResourceCPU] = resource.MustParse("0")
+	})
+	require.True(t, classifier.IsNodeBroken(noResources))
+	require.Equal(t, NodeStateBootstrapping, classifier.UniqueNodeState(ButNodeCreatedTimestamp(noResources, now), now, time.Minute))
+
+	decommissioned := ButNodeDecommissioned("test", NewRandomNode())
+	require.Equal(t, NodeStateDecommissioned, classifier.UniqueNodeState(decommissioned, now, time.Minute))
+	require.True(t, classifier.IsNodeOnItsWayOut(decommissioned))
+
+	phasedOut := ButNodeWithTaint(NewRandomNode(), &k8sCore.Taint{
+		Key:    commonNode.TaintKeyNodeDecommissioning,
+		Effect: k8sCore.TaintEffectPreferNoSchedule,
+	})
+	require.True(t, classifier.IsNodePhasedOut(phasedOut))
+	require.Equal(t, NodeStateActive, classifier.UniqueNodeState(phasedOut, now, time.Minute))
+
+	scalingDown := ButNodeScalingDown("test", NewRandomNode())
+	require.True(t, classifier.IsNodeScalingDown(scalingDown))
+	require.True(t, classifier.IsNodeToRemove(scalingDown))
+
+	evacuating := ButNodeWithTaint(NewRandomNode(), &k8sCore.Taint{
+		Key:    commonNode.TaintKeyNodeEvacuate,
+		Effect: k8sCore.TaintEffectNoExecute,
+	})
+	require.True(t, classifier.IsNodeToRemove(evacuating))
+	require.Equal(t, NodeStateBroken, classifier.UniqueNodeState(evacuating, now, time.Minute))
+
+	removable := ButNodeRemovable(NewRandomNode())
+	require.Equal(t, NodeStateRemovable, classifier.UniqueNodeState(removable, now, time.Minute))
+}
+
+func TestDrainTaints(t *testing.T) {
+	classifier := NewDefaultStateClassifier()
+	initTaint := k8sCore.Taint{Key: commonNode.TaintKeyInit, Effect: k8sCore.TaintEffectNoSchedule}
+	node := ButNodeWithTaint(ButNodeWithTaint(NewRandomNode(), &initTaint),
+		NewDecommissioningTaint("test", time.Now()))
+	taints := classifier.DrainTaints(node)
+	require.Len(t, taints, 1)
+	require.Equal(t, commonNode.TaintKeyNodeDecommissioning, taints[0].Key)
+
+	require.Empty(t, classifier.DrainTaints(NewRandomNode()))
+
+	phasedOutTaint := NewDecommissioningTaint("test", time.Now())
+	phasedOutTaint.Effect = k8sCore.TaintEffectPreferNoSchedule
+	require.Empty(t, classifier.DrainTaints(ButNodeWithTaint(NewRandomNode(), phasedOutTaint)))
+}
+
+func TestStateClassifierFromYAML(t *testing.T) {
+	classifier, err := NewStateClassifierFromYAML([]byte(`
+bootstrapping:
+  - taintKey: example.com/starting
+scalingDown:
+  - taintKey: example.com/draining
+    taintEffects: [NoSchedule]
+removable:
+  - label: example.com/removable
+    labelValue: "yes"
+broken:
+  - condition: Ready
+    conditionStatuses: ["False", "Unknown"]
+  - allocatableBelow:
+      memory: 1Gi
+`))
+	require.NoError(t, err)
+	now := time.Now()
+
+	starting := ButNodeWithTaint(NewRandomNode(), &k8sCore.Taint{Key: "example.com/starting", Effect: k8sCore.TaintEffectNoSchedule})
+	require.Equal(t, NodeStateBootstrapping, classifier.UniqueNodeState(starting, now, 0))
+	// Not in the custom rules
+	require.False(t, classifier.IsNodeBootstrapping2(
+		ButNodeWithTaint(NewRandomNode(), &k8sCore.Taint{Key: commonNode.TaintKeyInit}),
+		func(*k8sCore.Node) bool { return true },
+	))
+
+	draining := ButNodeWithTaint(NewRandomNode(), &k8sCore.Taint{Key: "example.com/draining", Effect: k8sCore.TaintEffectNoSchedule})
+	require.Equal(t, NodeStateScalingDown, classifier.UniqueNodeState(draining, now, 0))
+	drainingOtherEffect := ButNodeWithTaint(NewRandomNode(), &k8sCore.Taint{Key: "example.com/draining", Effect: k8sCore.TaintEffectNoExecute})
+	require.Equal(t, NodeStateActive, classifier.UniqueNodeState(drainingOtherEffect, now, 0))
+
+	require.True(t, classifier.IsNodeRemovable(ButNodeLabel(NewRandomNode(), "example.com/removable", "yes")))
+	require.False(t, classifier.IsNodeRemovable(ButNodeLabel(NewRandomNode(), "example.com/removable", "no")))
+
+	notReady := NewRandomNode(func(node *k8sCore.Node) {
+		node.Status.Conditions = []k8sCore.NodeCondition{{Type: k8sCore.NodeReady, Status: k8sCore.ConditionFalse}}
+	})
+	require.True(t, classifier.IsNodeBroken(notReady))
+	ready := NewRandomNode(func(node *k8sCore.Node) {
+		node.Status.Conditions = []k8sCore.NodeCondition{{Type: k8sCore.NodeReady, Status: k8sCore.ConditionTrue}}
+	})
+	require.False(t, classifier.IsNodeBroken(ready))
+	// No condition is regarded as unknown
+	require.True(t, classifier.IsNodeBroken(NewRandomNode()))
+
+	smallNode := NewNode("small", ResourcePoolElastic, machine.R5Metal())
+	smallNode.Status.Allocatable[k8sCore.ResourceMemory] = resource.MustParse("512Mi")
+	smallNode.Status.Conditions = ready.Status.Conditions
+	require.True(t, classifier.IsNodeBroken(smallNode))
+
+	// Health rules are not set, so the default ones apply.
+	require.Equal(t, DefaultNodeStateRules().Health, classifier.Rules().Health)
+}
+
+func TestStateClassifierFromYAMLWithHealthRules(t *testing.T) {
+	classifier, err := NewStateClassifierFromYAML([]byte(`
+health:
+  notReadyGracePeriod: 1m
+`))
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, classifier.Rules().Health.NotReadyGracePeriod.Duration)
+	require.Empty(t, classifier.Rules().Health.UnhealthyConditions)
+}
+
+func TestStateClassifierFromInvalidYAML(t *testing.T) {
+	_, err := NewStateClassifierFromYAML([]byte("unknownState: []"))
+	require.Error(t, err)
+}
+
+func TestSnapshotWithCustomClassifier(t *testing.T) {
+	classifier := NewStateClassifier(NodeStateRules{
+		ScalingDown: []NodeMatcher{{TaintKey: "example.com/draining"}},
+	})
+	draining := ButNodeWithTaint(NewRandomNode(), &k8sCore.Taint{Key: "example.com/draining", Effect: k8sCore.TaintEffectNoSchedule})
+	decommissioned := ButNodeDecommissioned("test", NewRandomNode())
+
+	snapshot, _ := NewSnapshotOfResourcePool([]*k8sCore.Node{draining, decommissioned}, ResourcePoolElastic, nil,
+		Options{Classifier: classifier})
+	require.Contains(t, snapshot.OnWayOutByName, draining.Name)
+	require.Contains(t, snapshot.ActiveByName, decommissioned.Name)
+
+	snapshot, _ = NewSnapshotOfResourcePool([]*k8sCore.Node{draining, decommissioned}, ResourcePoolElastic, nil,
+		Options{})
+	require.Contains(t, snapshot.ActiveByName, draining.Name)
+	require.Contains(t, snapshot.OnWayOutByName, decommissioned.Name)
+}
+
+func TestNodeHealth(t *testing.T) {
+	now := time.Now()
+
+	ready := ButNodeCondition(NewRandomNode(), k8sCore.NodeReady, k8sCore.ConditionTrue, now.Add(-time.Hour))
+	require.False(t, IsNodeUnhealthy(ready, now))
+	require.False(t, IsNodeUnhealthy(NewRandomNode(), now))
+
+	recentlyNotReady := ButNodeCondition(NewRandomNode(), k8sCore.NodeReady, k8sCore.ConditionFalse, now.Add(-time.Minute))
+	require.False(t, IsNodeUnhealthy(recentlyNotReady, now))
+
+	notReady := ButNodeCondition(NewRandomNode(), k8sCore.NodeReady, k8sCore.ConditionUnknown, now.Add(-time.Hour))
+	require.True(t, IsNodeUnhealthy(notReady, now))
+	require.Equal(t, NodeStateUnhealthy, UniqueNodeState(notReady, now, time.Minute))
+	require.Equal(t, []string{"Ready=Unknown"}, NewDefaultStateClassifier().UnhealthyReasons(notReady, now))
+
+	memoryPressure := ButNodeCondition(ready.DeepCopy(), k8sCore.NodeMemoryPressure, k8sCore.ConditionTrue, now)
+	require.True(t, IsNodeUnhealthy(memoryPressure, now))
+	networkUnavailable := ButNodeCondition(ready.DeepCopy(), k8sCore.NodeNetworkUnavailable, k8sCore.ConditionTrue, now)
+	require.True(t, IsNodeUnhealthy(networkUnavailable, now))
+	noPressure := ButNodeCondition(ready.DeepCopy(), k8sCore.NodeDiskPressure, k8sCore.ConditionFalse, now)
+	require.False(t, IsNodeUnhealthy(noPressure, now))
+
+	// Young nodes with unhealthy conditions are still bootstrapping.
+	young := ButNodeCreatedTimestamp(memoryPressure.DeepCopy(), now)
+	require.True(t, NewDefaultStateClassifier().IsNodeBootstrapping(young, now, time.Minute))
+	classifier := NewStateClassifier(NodeStateRules{})
+	require.False(t, classifier.IsNodeBootstrapping(young, now, time.Minute))
+}
+
+func TestSnapshotWithUnhealthyNodes(t *testing.T) {
+	now := time.Now()
+	healthy := ButNodeCondition(NewRandomNode(), k8sCore.NodeReady, k8sCore.ConditionTrue, now.Add(-time.Hour))
+	notReady := ButNodeCondition(NewRandomNode(), k8sCore.NodeReady, k8sCore.ConditionFalse, now.Add(-time.Hour))
+	young := ButNodeCreatedTimestamp(
+		ButNodeCondition(NewRandomNode(), k8sCore.NodeReady, k8sCore.ConditionFalse, now.Add(-time.Hour)), now)
+	notReadyDecommissioned := ButNodeDecommissioned("test",
+		ButNodeCondition(NewRandomNode(), k8sCore.NodeReady, k8sCore.ConditionFalse, now.Add(-time.Hour)))
+
+	snapshot, _ := NewSnapshotOfResourcePool(
+		[]*k8sCore.Node{healthy, notReady, young, notReadyDecommissioned}, ResourcePoolElastic, nil,
+		Options{
+			PastBootstrapDeadline: func(node *k8sCore.Node, now time.Time) bool {
+				return Age(node, now) > 10*time.Minute
+			},
+		})
+	require.Len(t, snapshot.ActiveByName, 1)
+	require.Contains(t, snapshot.ActiveByName, healthy.Name)
+	require.Len(t, snapshot.UnhealthyByName, 1)
+	require.Contains(t, snapshot.UnhealthyByName, notReady.Name)
+	require.Contains(t, snapshot.BootstrappingByName, young.Name)
+	require.Contains(t, snapshot.OnWayOutByName, notReadyDecommissioned.Name)
+
+	// Node recovers
+	_, err := snapshot.Transform(notReady.Name, func(node *k8sCore.Node) {
+		ButNodeCondition(node, k8sCore.NodeReady, k8sCore.ConditionTrue, time.Now())
+	})
+	require.NoError(t, err)
+	require.Empty(t, snapshot.UnhealthyByName)
+	require.Contains(t, snapshot.ActiveByName, notReady.Name)
+}
@@ -15,6 +15,8 @@ const (
 	NodeStateScalingDown    = "scalingDown"
 	NodeStateBroken         = "broken"
 	NodeStateRemovable      = "removable"
+	NodeStateUnhealthy      = "unhealthy"
+	NodeStateTerminated     = "terminated"
 )
 
 var NodeStatesAll = []string{
@@ -25,4 +27,6 @@ var NodeStatesAll = []string{
 	NodeStateScalingDown,
 	NodeStateBroken,
 	NodeStateRemovable,
+	NodeStateUnhealthy,
+	NodeStateTerminated,
 }
@@ -1,6 +1,8 @@
 package node
 
 import (
+	"sort"
+	"strconv"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
@@ -11,10 +13,13 @@ import (
 )
 
 func FormatNode(node *v1.Node, ageThreshold time.Duration, options poolUtil.FormatterOptions) string {
+	if options.IsTableOutput() {
+		return FormatNodesTable([]*v1.Node{node}, ageThreshold, options)
+	}
 	if options.Level == poolUtil.FormatCompact {
 		return formatNodeCompact(node, ageThreshold)
 	} else if options.Level == poolUtil.FormatEssentials {
-		return formatNodeEssentials(node, ageThreshold)
+		return formatNodeEssentials(node, ageThreshold, options.ResourceRegistry)
 	} else if options.Level == poolUtil.FormatDetails {
 		return poolUtil.ToJSONString(node)
 	}
@@ -35,18 +40,42 @@ func formatNodeCompact(node *v1.Node, ageThreshold time.Duration) string {
 	return poolUtil.ToJSONString(value)
 }
 
-func formatNodeEssentials(node *v1.Node, ageThreshold time.Duration) string {
+func formatNodeEssentials(node *v1.Node, ageThreshold time.Duration, registry *poolUtil.ResourceRegistry) string {
 	type Compact struct {
-		Name               string
-		Up                 bool
-		OnWayOut           bool
-		AvailableResources poolV1.ComputeResource
+		Name                       string
+		Up                         bool
+		OnWayOut                   bool
+		AvailableResources         poolV1.ComputeResource
+		AvailableExtendedResources poolUtil.ExtendedResources `json:",omitempty"`
 	}
 	value := Compact{
-		Name:               node.Name,
-		Up:                 IsNodeAvailableForScheduling(node, time.Now(), ageThreshold),
-		OnWayOut:           IsNodeOnItsWayOut(node),
-		AvailableResources: FromNodeToComputeResource(node),
+		Name:                       node.Name,
+		Up:                         IsNodeAvailableForScheduling(node, time.Now(), ageThreshold),
+		OnWayOut:                   IsNodeOnItsWayOut(node),
+		AvailableResources:         FromNodeToComputeResource(node),
+		AvailableExtendedResources: FromNodeToExtendedResources(node, registry),
 	}
 	return poolUtil.ToJSONString(value)
 }
+
+// FormatNodesTable renders nodes as a text table, sorted by name unless options.SortBy is set.
+func FormatNodesTable(nodes []*v1.Node, ageThreshold time.Duration, options poolUtil.FormatterOptions) string {
+	sorted := make([]*v1.Node, len(nodes))
+	copy(sorted, nodes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	now := time.Now()
+	table := poolUtil.NewTable(append([]string{"NAME", "MACHINE_TYPE", "UP", "ON_WAY_OUT"},
+		poolUtil.ComputeResourceColumns...)...)
+	for _, node := range sorted {
+		machineType, _ := FindNodeInstanceType(node)
+		table.AddCells(append(poolUtil.Cells(
+			node.Name,
+			machineType,
+			strconv.FormatBool(IsNodeAvailableForScheduling(node, now, ageThreshold)),
+			strconv.FormatBool(IsNodeOnItsWayOut(node)),
+		), poolUtil.ComputeResourceCells(FromNodeToComputeResource(node))...)...)
+	}
+	return table.Render(options)
+}
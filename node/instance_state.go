@@ -0,0 +1,49 @@
+package node
+
+import (
+	"time"
+
+	k8sCore "k8s.io/api/core/v1"
+)
+
+const (
+	// Taint added by the cloud node lifecycle controller to nodes whose instances are shut down.
+	TaintKeyNodeShutdown = "node.cloudprovider.kubernetes.io/shutdown"
+
+	// Default time after which a not ready node with a node lifecycle taint is regarded as terminated.
+	DefaultTerminatedNotReadyThreshold = 30 * time.Minute
+)
+
+// InstanceStateProvider tells if a node object corresponds to an existing machine instance. Implementations may
+// for example look up the node provider ID in the cloud provider API.
+type InstanceStateProvider interface {
+	// Returns true if the instance backing the node no longer exists.
+	IsInstanceTerminated(node *k8sCore.Node, now time.Time) bool
+}
+
+// LifecycleInstanceStateProvider guesses instance state from the node object only. A node is regarded as terminated
+// if it is tainted as shut down by the cloud provider, or if it is not ready for longer than NotReadyThreshold, and
+// has the unreachable or not-ready node lifecycle taint.
+type LifecycleInstanceStateProvider struct {
+	NotReadyThreshold time.Duration
+}
+
+func NewLifecycleInstanceStateProvider(notReadyThreshold time.Duration) *LifecycleInstanceStateProvider {
+	return &LifecycleInstanceStateProvider{NotReadyThreshold: notReadyThreshold}
+}
+
+func (p *LifecycleInstanceStateProvider) IsInstanceTerminated(node *k8sCore.Node, now time.Time) bool {
+	if FindTaint(node, TaintKeyNodeShutdown) != nil {
+		return true
+	}
+	if FindTaint(node, k8sCore.TaintNodeUnreachable) == nil && FindTaint(node, k8sCore.TaintNodeNotReady) == nil {
+		return false
+	}
+	ready := FindCondition(node, k8sCore.NodeReady)
+	if ready == nil || ready.Status == k8sCore.ConditionTrue {
+		return false
+	}
+	return !ready.LastTransitionTime.Add(p.NotReadyThreshold).After(now)
+}
+
+var defaultInstanceStateProvider InstanceStateProvider = NewLifecycleInstanceStateProvider(DefaultTerminatedNotReadyThreshold)
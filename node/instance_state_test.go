@@ -0,0 +1,54 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+)
+
+func TestLifecycleInstanceStateProvider(t *testing.T) {
+	now := time.Now()
+	provider := NewLifecycleInstanceStateProvider(30 * time.Minute)
+
+	require.False(t, provider.IsInstanceTerminated(NewRandomNode(), now))
+
+	shutdown := ButNodeWithTaint(NewRandomNode(), &k8sCore.Taint{Key: TaintKeyNodeShutdown, Effect: k8sCore.TaintEffectNoSchedule})
+	require.True(t, provider.IsInstanceTerminated(shutdown, now))
+
+	unreachable := func(notReadySince time.Time) *k8sCore.Node {
+		return ButNodeCondition(
+			ButNodeWithTaint(NewRandomNode(), &k8sCore.Taint{Key: k8sCore.TaintNodeUnreachable, Effect: k8sCore.TaintEffectNoExecute}),
+			k8sCore.NodeReady, k8sCore.ConditionUnknown, notReadySince,
+		)
+	}
+	require.False(t, provider.IsInstanceTerminated(unreachable(now.Add(-time.Minute)), now))
+	require.True(t, provider.IsInstanceTerminated(unreachable(now.Add(-time.Hour)), now))
+
+	// Not ready for a long time, but with no lifecycle taint
+	notReady := ButNodeCondition(NewRandomNode(), k8sCore.NodeReady, k8sCore.ConditionFalse, now.Add(-time.Hour))
+	require.False(t, provider.IsInstanceTerminated(notReady, now))
+}
+
+func TestSnapshotWithTerminatedNodes(t *testing.T) {
+	node1 := NewRandomNode()
+	node2 := NewRandomNode(func(node *k8sCore.Node) { node.Spec.ProviderID = "aws:///us-east-1a/i-123" })
+	node3 := NewRandomNode()
+	provider := NewFakeInstanceStateProvider("aws:///us-east-1a/i-123")
+	classifier := NewDefaultStateClassifier().WithInstanceStateProvider(provider)
+
+	snapshot, _ := NewSnapshotOfResourcePool([]*k8sCore.Node{node1, node2, node3}, ResourcePoolElastic, nil,
+		Options{Classifier: classifier})
+	require.Len(t, snapshot.AllByName, 3)
+	require.Len(t, snapshot.ActiveByName, 2)
+	require.Len(t, snapshot.TerminatedByName, 1)
+	require.Contains(t, snapshot.TerminatedByName, node2.Name)
+	require.Equal(t, NodeStateTerminated, classifier.UniqueNodeState(node2, time.Now(), 0))
+
+	provider.MarkTerminated(node3)
+	snapshot.Add(node3)
+	require.Len(t, snapshot.ActiveByName, 1)
+	require.Contains(t, snapshot.TerminatedByName, node3.Name)
+}
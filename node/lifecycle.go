@@ -0,0 +1,271 @@
+package node
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const unknownMachineType = "unknown"
+
+// NodeTransition is a change of a node state observed between two consecutive snapshots.
+type NodeTransition struct {
+	NodeName     string
+	ResourcePool string
+	MachineType  string
+	From         string
+	To           string
+	At           time.Time
+}
+
+// NodeTimeline holds the state history of a single node.
+type NodeTimeline struct {
+	NodeName     string
+	ResourcePool string
+	MachineType  string
+	FirstSeen    time.Time
+	State        string
+	StateSince   time.Time
+	Transitions  []NodeTransition
+	// Time from the node creation until it became active for the first time. Set only for nodes observed in the
+	// bootstrapping state.
+	BootstrapDuration time.Duration
+	Bootstrapped      bool
+	// Time the node spent phased out before it was decommissioned. Set only for nodes observed becoming phased out.
+	PhasedOutDuration time.Duration
+	Decommissioned    bool
+	// Time from the moment a node was selected for removal (decommissioned or scaling down) until it became removable,
+	// terminated or was deleted. A drain cancelled by the node becoming active again is not recorded.
+	DrainDuration time.Duration
+	Drained       bool
+	// Internal
+	phasedOutAt    time.Time
+	drainStartedAt time.Time
+}
+
+// DurationStats aggregates observed durations.
+type DurationStats struct {
+	Count int64
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+}
+
+// LifecycleAggregate holds node lifecycle statistics of a resource pool and machine type pair.
+type LifecycleAggregate struct {
+	ResourcePool string
+	MachineType  string
+	Bootstrap    DurationStats
+	PhasedOut    DurationStats
+	Drain        DurationStats
+	// Number of transitions keyed by "<from>-><to>" state pairs.
+	Transitions map[string]int64
+}
+
+// LifecycleTracker consumes successive node snapshots, and records node state transitions. It is safe to use it
+// concurrently.
+type LifecycleTracker struct {
+	lock       sync.Mutex
+	timelines  map[string]*NodeTimeline
+	aggregates map[string]*LifecycleAggregate
+	metrics    *LifecycleMetrics
+}
+
+// Creates a new tracker. Metrics are optional, and can be set to nil.
+func NewLifecycleTracker(metrics *LifecycleMetrics) *LifecycleTracker {
+	return &LifecycleTracker{
+		timelines:  map[string]*NodeTimeline{},
+		aggregates: map[string]*LifecycleAggregate{},
+		metrics:    metrics,
+	}
+}
+
+func (s *DurationStats) Add(duration time.Duration) {
+	if s.Count == 0 || duration < s.Min {
+		s.Min = duration
+	}
+	if s.Count == 0 || duration > s.Max {
+		s.Max = duration
+	}
+	s.Count++
+	s.Total += duration
+}
+
+func (s DurationStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// Observe records state changes of nodes in the given snapshot. Nodes seen before, but not present in the snapshot
+// are regarded as deleted, and their timelines are discarded. Returns the transitions found in this snapshot.
+func (t *LifecycleTracker) Observe(snapshot *Snapshot, now time.Time) []NodeTransition {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	transitions := []NodeTransition{}
+	for nodeName, node := range snapshot.AllByName {
+		state, _ := snapshot.NodeState(nodeName)
+		timeline, ok := t.timelines[nodeName]
+		if !ok {
+			resourcePool, _ := FindNodeResourcePool(node)
+			machineType, ok := FindNodeInstanceType(node)
+			if !ok {
+				machineType = unknownMachineType
+			}
+			timeline = &NodeTimeline{
+				NodeName:     nodeName,
+				ResourcePool: resourcePool,
+				MachineType:  machineType,
+				FirstSeen:    now,
+				State:        state,
+				StateSince:   now,
+			}
+			t.timelines[nodeName] = timeline
+			continue
+		}
+		if timeline.State == state {
+			continue
+		}
+
+		transition := NodeTransition{
+			NodeName:     nodeName,
+			ResourcePool: timeline.ResourcePool,
+			MachineType:  timeline.MachineType,
+			From:         timeline.State,
+			To:           state,
+			At:           now,
+		}
+		t.recordTransition(timeline, transition, node.CreationTimestamp.Time)
+		transitions = append(transitions, transition)
+	}
+
+	for nodeName, timeline := range t.timelines {
+		if _, ok := snapshot.AllByName[nodeName]; !ok {
+			t.recordDrain(timeline, now)
+			delete(t.timelines, nodeName)
+		}
+	}
+
+	sort.Slice(transitions, func(i, j int) bool {
+		return transitions[i].NodeName < transitions[j].NodeName
+	})
+	return transitions
+}
+
+func (t *LifecycleTracker) recordTransition(timeline *NodeTimeline, transition NodeTransition, createdAt time.Time) {
+	aggregate := t.aggregateOf(timeline)
+	aggregate.Transitions[transition.From+"->"+transition.To]++
+	if t.metrics != nil {
+		t.metrics.observeTransition(transition)
+	}
+
+	if transition.From == NodeStateBootstrapping && transition.To == NodeStateActive && !timeline.Bootstrapped {
+		start := createdAt
+		if start.IsZero() {
+			start = timeline.FirstSeen
+		}
+		timeline.BootstrapDuration = transition.At.Sub(start)
+		timeline.Bootstrapped = true
+		aggregate.Bootstrap.Add(timeline.BootstrapDuration)
+		if t.metrics != nil {
+			t.metrics.observeBootstrap(timeline)
+		}
+	}
+	if transition.To == NodeStatePhasedOut {
+		timeline.phasedOutAt = transition.At
+	}
+	if transition.From == NodeStatePhasedOut && transition.To == NodeStateDecommissioned && !timeline.phasedOutAt.IsZero() {
+		timeline.PhasedOutDuration = transition.At.Sub(timeline.phasedOutAt)
+		timeline.Decommissioned = true
+		aggregate.PhasedOut.Add(timeline.PhasedOutDuration)
+		if t.metrics != nil {
+			t.metrics.observePhasedOut(timeline)
+		}
+	}
+	if isDrainingState(transition.To) && !isDrainingState(transition.From) && timeline.drainStartedAt.IsZero() {
+		timeline.drainStartedAt = transition.At
+	} else if isDrainedState(transition.To) {
+		t.recordDrain(timeline, transition.At)
+	} else if transition.To == NodeStateActive || transition.To == NodeStatePhasedOut {
+		// The drain was cancelled.
+		timeline.drainStartedAt = time.Time{}
+	}
+
+	timeline.Transitions = append(timeline.Transitions, transition)
+	timeline.State = transition.To
+	timeline.StateSince = transition.At
+}
+
+func (t *LifecycleTracker) recordDrain(timeline *NodeTimeline, now time.Time) {
+	if timeline.Drained || timeline.drainStartedAt.IsZero() {
+		return
+	}
+	timeline.DrainDuration = now.Sub(timeline.drainStartedAt)
+	timeline.Drained = true
+	t.aggregateOf(timeline).Drain.Add(timeline.DrainDuration)
+	if t.metrics != nil {
+		t.metrics.observeDrain(timeline)
+	}
+}
+
+func (t *LifecycleTracker) aggregateOf(timeline *NodeTimeline) *LifecycleAggregate {
+	key := timeline.ResourcePool + "/" + timeline.MachineType
+	aggregate, ok := t.aggregates[key]
+	if !ok {
+		aggregate = &LifecycleAggregate{
+			ResourcePool: timeline.ResourcePool,
+			MachineType:  timeline.MachineType,
+			Transitions:  map[string]int64{},
+		}
+		t.aggregates[key] = aggregate
+	}
+	return aggregate
+}
+
+// Returns a copy of the timeline of a node, or false if the node is not tracked.
+func (t *LifecycleTracker) Timeline(nodeName string) (NodeTimeline, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	timeline, ok := t.timelines[nodeName]
+	if !ok {
+		return NodeTimeline{}, false
+	}
+	result := *timeline
+	result.Transitions = append([]NodeTransition{}, timeline.Transitions...)
+	return result, true
+}
+
+// Returns statistics for all resource pool and machine type pairs, sorted by the resource pool and machine type
+// names.
+func (t *LifecycleTracker) Aggregates() []LifecycleAggregate {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	result := []LifecycleAggregate{}
+	for _, aggregate := range t.aggregates {
+		copied := *aggregate
+		copied.Transitions = map[string]int64{}
+		for key, count := range aggregate.Transitions {
+			copied.Transitions[key] = count
+		}
+		result = append(result, copied)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ResourcePool != result[j].ResourcePool {
+			return result[i].ResourcePool < result[j].ResourcePool
+		}
+		return result[i].MachineType < result[j].MachineType
+	})
+	return result
+}
+
+// Nodes in those states are being removed from the cluster.
+func isDrainingState(state string) bool {
+	return state == NodeStateDecommissioned || state == NodeStateScalingDown
+}
+
+// Nodes in those states completed draining. A drain also completes when the node is deleted.
+func isDrainedState(state string) bool {
+	return state == NodeStateRemovable || state == NodeStateTerminated
+}
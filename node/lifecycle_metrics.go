@@ -0,0 +1,80 @@
+package node
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
+)
+
+// LifecycleMetrics publishes node lifecycle durations and state transitions as Prometheus metrics.
+type LifecycleMetrics struct {
+	bootstrapDuration *prometheus.HistogramVec
+	phasedOutDuration *prometheus.HistogramVec
+	drainDuration     *prometheus.HistogramVec
+	transitions       *prometheus.CounterVec
+}
+
+var lifecycleDurationBuckets = []float64{30, 60, 120, 300, 600, 1200, 1800, 3600, 7200, 14400, 43200, 86400}
+
+// Creates lifecycle metrics registered in the given registerer. If the metrics are already registered, the existing
+// collectors are reused.
+func NewLifecycleMetrics(registerer prometheus.Registerer, metricsSubsystem string) (*LifecycleMetrics, error) {
+	labels := []string{"resourcePool", "machineType"}
+	bootstrapDuration, err := poolUtil.RegisterCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: metricsSubsystem,
+		Name:      "nodeBootstrapDuration",
+		Help:      "Time from a node creation until it becomes active (seconds)",
+		Buckets:   lifecycleDurationBuckets,
+	}, labels))
+	if err != nil {
+		return nil, err
+	}
+	phasedOutDuration, err := poolUtil.RegisterCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: metricsSubsystem,
+		Name:      "nodePhasedOutDuration",
+		Help:      "Time a node spends phased out before it is decommissioned (seconds)",
+		Buckets:   lifecycleDurationBuckets,
+	}, labels))
+	if err != nil {
+		return nil, err
+	}
+	drainDuration, err := poolUtil.RegisterCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: metricsSubsystem,
+		Name:      "nodeDrainDuration",
+		Help:      "Time from a node selection for removal until it is removable or deleted (seconds)",
+		Buckets:   lifecycleDurationBuckets,
+	}, labels))
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := poolUtil.RegisterCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: metricsSubsystem,
+		Name:      "nodeStateTransitions",
+		Help:      "Number of node state transitions",
+	}, []string{"resourcePool", "machineType", "from", "to"}))
+	if err != nil {
+		return nil, err
+	}
+	return &LifecycleMetrics{
+		bootstrapDuration: bootstrapDuration.(*prometheus.HistogramVec),
+		phasedOutDuration: phasedOutDuration.(*prometheus.HistogramVec),
+		drainDuration:     drainDuration.(*prometheus.HistogramVec),
+		transitions:       transitions.(*prometheus.CounterVec),
+	}, nil
+}
+
+func (m *LifecycleMetrics) observeTransition(transition NodeTransition) {
+	m.transitions.WithLabelValues(transition.ResourcePool, transition.MachineType, transition.From, transition.To).Inc()
+}
+
+func (m *LifecycleMetrics) observeBootstrap(timeline *NodeTimeline) {
+	m.bootstrapDuration.WithLabelValues(timeline.ResourcePool, timeline.MachineType).Observe(timeline.BootstrapDuration.Seconds())
+}
+
+func (m *LifecycleMetrics) observePhasedOut(timeline *NodeTimeline) {
+	m.phasedOutDuration.WithLabelValues(timeline.ResourcePool, timeline.MachineType).Observe(timeline.PhasedOutDuration.Seconds())
+}
+
+func (m *LifecycleMetrics) observeDrain(timeline *NodeTimeline) {
+	m.drainDuration.WithLabelValues(timeline.ResourcePool, timeline.MachineType).Observe(timeline.DrainDuration.Seconds())
+}
@@ -0,0 +1,162 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	commonNode "stash.corp.netflix.com/tn/titus-kube-common/node"
+)
+
+func TestLifecycleTracker(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics, err := NewLifecycleMetrics(registry, "test")
+	require.NoError(t, err)
+	tracker := NewLifecycleTracker(metrics)
+
+	start := time.Now()
+	node := ButNodeCreatedTimestamp(NewRandomNode(), start)
+	ButNodeWithTaint(node, &k8sCore.Taint{Key: commonNode.TaintKeyInit, Effect: k8sCore.TaintEffectNoSchedule})
+	observe := func(at time.Time, nodes ...*k8sCore.Node) []NodeTransition {
+		snapshot, _ := NewSnapshotOfResourcePool(nodes, ResourcePoolElastic, nil, Options{})
+		return tracker.Observe(snapshot, at)
+	}
+
+	require.Empty(t, observe(start, node))
+
+	// Bootstrapped
+	node.Spec.Taints = nil
+	transitions := observe(start.Add(5*time.Minute), node)
+	require.Len(t, transitions, 1)
+	require.Equal(t, NodeStateBootstrapping, transitions[0].From)
+	require.Equal(t, NodeStateActive, transitions[0].To)
+
+	// Phased out
+	ButNodeWithTaint(node, &k8sCore.Taint{Key: commonNode.TaintKeyNodeDecommissioning, Effect: k8sCore.TaintEffectPreferNoSchedule})
+	observe(start.Add(time.Hour), node)
+
+	// Decommissioned
+	node.Spec.Taints = nil
+	ButNodeDecommissioned("test", node)
+	observe(start.Add(3*time.Hour), node)
+
+	timeline, ok := tracker.Timeline(node.Name)
+	require.True(t, ok)
+	require.Equal(t, NodeStateDecommissioned, timeline.State)
+	require.Len(t, timeline.Transitions, 3)
+	require.True(t, timeline.Bootstrapped)
+	require.Equal(t, 5*time.Minute, timeline.BootstrapDuration)
+	require.True(t, timeline.Decommissioned)
+	require.Equal(t, 2*time.Hour, timeline.PhasedOutDuration)
+	require.False(t, timeline.Drained)
+
+	// Node deleted
+	observe(start.Add(4 * time.Hour))
+	_, ok = tracker.Timeline(node.Name)
+	require.False(t, ok)
+
+	aggregates := tracker.Aggregates()
+	require.Len(t, aggregates, 1)
+	aggregate := aggregates[0]
+	require.Equal(t, ResourcePoolElastic, aggregate.ResourcePool)
+	require.Equal(t, "r5.metal", aggregate.MachineType)
+	require.EqualValues(t, 1, aggregate.Bootstrap.Count)
+	require.Equal(t, 5*time.Minute, aggregate.Bootstrap.Mean())
+	require.EqualValues(t, 1, aggregate.PhasedOut.Count)
+	require.EqualValues(t, 1, aggregate.Drain.Count)
+	require.Equal(t, time.Hour, aggregate.Drain.Max)
+	require.EqualValues(t, 1, aggregate.Transitions[NodeStateBootstrapping+"->"+NodeStateActive])
+
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.transitions.WithLabelValues(
+		ResourcePoolElastic, "r5.metal", NodeStatePhasedOut, NodeStateDecommissioned)))
+	count, err := testutil.GatherAndCount(registry, "test_nodeBootstrapDuration", "test_nodeDrainDuration")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
+
+func TestLifecycleTrackerIgnoresDurationsOfNodesSeenMidway(t *testing.T) {
+	tracker := NewLifecycleTracker(nil)
+	now := time.Now()
+	node := ButNodeScalingDown("test", NewRandomNode())
+	snapshot, _ := NewSnapshotOfResourcePool([]*k8sCore.Node{node}, ResourcePoolElastic, nil, Options{})
+	tracker.Observe(snapshot, now)
+
+	tracker.Observe(NewEmptySnapshot(), now.Add(time.Minute))
+	require.Len(t, tracker.Aggregates(), 0)
+}
+
+func TestLifecycleTrackerIgnoresPhasedOutDurationOfNodesSeenMidway(t *testing.T) {
+	tracker := NewLifecycleTracker(nil)
+	now := time.Now()
+	node := ButNodeWithTaint(NewRandomNode(),
+		&k8sCore.Taint{Key: commonNode.TaintKeyNodeDecommissioning, Effect: k8sCore.TaintEffectPreferNoSchedule})
+	snapshot, _ := NewSnapshotOfResourcePool([]*k8sCore.Node{node}, ResourcePoolElastic, nil, Options{})
+	tracker.Observe(snapshot, now)
+
+	node.Spec.Taints = nil
+	ButNodeDecommissioned("test", node)
+	snapshot, _ = NewSnapshotOfResourcePool([]*k8sCore.Node{node}, ResourcePoolElastic, nil, Options{})
+	tracker.Observe(snapshot, now.Add(time.Hour))
+
+	timeline, ok := tracker.Timeline(node.Name)
+	require.True(t, ok)
+	require.False(t, timeline.Decommissioned)
+	require.Zero(t, timeline.PhasedOutDuration)
+	require.Len(t, tracker.Aggregates(), 1)
+	require.Zero(t, tracker.Aggregates()[0].PhasedOut.Count)
+}
+
+func TestLifecycleTrackerCancelledDrain(t *testing.T) {
+	tracker := NewLifecycleTracker(nil)
+	start := time.Now()
+	node := NewRandomNode()
+	observe := func(at time.Time, nodes ...*k8sCore.Node) {
+		snapshot, _ := NewSnapshotOfResourcePool(nodes, ResourcePoolElastic, nil, Options{})
+		tracker.Observe(snapshot, at)
+	}
+	observe(start, node)
+
+	ButNodeDecommissioned("test", node)
+	observe(start.Add(time.Hour), node)
+	// Decommissioning cancelled
+	node.Spec.Taints = nil
+	observe(start.Add(2*time.Hour), node)
+	timeline, _ := tracker.Timeline(node.Name)
+	require.False(t, timeline.Drained)
+
+	ButNodeDecommissioned("test", node)
+	observe(start.Add(3*time.Hour), node)
+	observe(start.Add(5 * time.Hour))
+
+	aggregates := tracker.Aggregates()
+	require.Len(t, aggregates, 1)
+	require.EqualValues(t, 1, aggregates[0].Drain.Count)
+	require.Equal(t, 2*time.Hour, aggregates[0].Drain.Max)
+}
+
+func TestLifecycleTrackerDrainEndsWhenRemovable(t *testing.T) {
+	tracker := NewLifecycleTracker(nil)
+	start := time.Now()
+	node := NewRandomNode()
+	observe := func(at time.Time) {
+		snapshot, _ := NewSnapshotOfResourcePool([]*k8sCore.Node{node}, ResourcePoolElastic, nil, Options{})
+		tracker.Observe(snapshot, at)
+	}
+	observe(start)
+
+	ButNodeDecommissioned("test", node)
+	observe(start.Add(time.Hour))
+	// The decommissioning taint is kept.
+	ButNodeRemovable(node)
+	observe(start.Add(2 * time.Hour))
+
+	timeline, _ := tracker.Timeline(node.Name)
+	require.Equal(t, NodeStateRemovable, timeline.State)
+	require.True(t, timeline.Drained)
+	require.Equal(t, time.Hour, timeline.DrainDuration)
+}
@@ -21,27 +21,9 @@ func AsNodeReferenceList(nodeList *k8sCore.NodeList) []*k8sCore.Node {
 	return result
 }
 
-// Resolve node state.
+// Resolve node state using the default node state rules.
 func UniqueNodeState(node *k8sCore.Node, now time.Time, ageThreshold time.Duration) string {
-	if IsNodeBootstrapping(node, now, ageThreshold) {
-		return NodeStateBootstrapping
-	}
-	if IsNodeAvailableForScheduling(node, now, ageThreshold) {
-		return NodeStateActive
-	}
-	if IsNodeDecommissioned(node) {
-		return NodeStateDecommissioned
-	}
-	if IsNodePhasedOut(node) {
-		return NodeStatePhasedOut
-	}
-	if IsNodeScalingDown(node) {
-		return NodeStateScalingDown
-	}
-	if IsNodeRemovable(node) {
-		return NodeStateRemovable
-	}
-	return NodeStateBroken
+	return defaultStateClassifier.UniqueNodeState(node, now, ageThreshold)
 }
 
 // Returns true, if a node is a Kubelet node
@@ -64,81 +46,55 @@ func HasNoExecuteTaint(node *k8sCore.Node) bool {
 // Returns true for a new node that is still bootstrapping. `ageThreshold` is a time limit for a node to be
 // regarded as new.
 func IsNodeBootstrapping(node *k8sCore.Node, now time.Time, ageThreshold time.Duration) bool {
-	// This taint explicitly tells us that the node is initializing.
-	if FindTaint(node, commonNode.TaintKeyInit) != nil {
-		return true
-	}
-
-	if node.CreationTimestamp.Add(ageThreshold).Before(now) {
-		return false
-	}
-
-	// Getting here does not guarantee (at least at the time of writing this change), that the new node is
-	// fully initialized and ready to take traffic. We make here a few heuristic guesses to improve th evaluation
-	// accuracy.
-	return IsNodeBroken(node)
+	return defaultStateClassifier.IsNodeBootstrapping(node, now, ageThreshold)
 }
 
 func IsNodeBootstrapping2(node *k8sCore.Node, pastDeadline func(*k8sCore.Node) bool) bool {
-	// This taint explicitly tells us that the node is initializing.
-	if FindTaint(node, commonNode.TaintKeyInit) != nil {
-		return true
-	}
-
-	if pastDeadline(node) {
-		return false
-	}
-
-	// Getting here does not guarantee (at least at the time of writing this change), that the new node is
-	// fully initialized and ready to take traffic. We make here a few heuristic guesses to improve th evaluation
-	// accuracy.
-	return IsNodeBroken(node)
+	return defaultStateClassifier.IsNodeBootstrapping2(node, pastDeadline)
 }
 
+// IsNodeBroken returns true if the node matches any of the broken node rules from DefaultNodeStateRules.
 func IsNodeBroken(node *k8sCore.Node) bool {
-	// FIXME Discern better between actual bad states and other cases
-	if HasNoExecuteTaint(node) {
-		return true
-	}
-
-	// It happens that there are node objects registered with no resources
-	if node.Status.Allocatable.Cpu().IsZero() {
-		return true
-	}
-
-	return false
+	return defaultStateClassifier.IsNodeBroken(node)
 }
 
 func IsNodeAvailableForScheduling(node *k8sCore.Node, now time.Time, ageThreshold time.Duration) bool {
-	return !IsNodeBootstrapping(node, now, ageThreshold) &&
-		!IsNodeToRemove(node) &&
-		!IsNodeRemovable(node) &&
-		!IsNodeTerminated(node)
+	return defaultStateClassifier.IsNodeAvailableForScheduling(node, now, ageThreshold)
 }
 
 func IsNodeOnItsWayOut(node *k8sCore.Node) bool {
-	return IsNodeToRemove(node) || IsNodeRemovable(node) || IsNodeTerminated(node)
+	return defaultStateClassifier.IsNodeOnItsWayOut(node)
+}
+
+// IsNodeUnhealthy returns true if the node is not ready for longer than the default grace period, or reports
+// a resource pressure or network unavailable condition.
+func IsNodeUnhealthy(node *k8sCore.Node, now time.Time) bool {
+	return defaultStateClassifier.IsNodeUnhealthy(node, now)
+}
+
+// IsNodeNotReady returns true if the node reports the Ready condition in False or Unknown status, or any of the
+// unhealthy conditions from DefaultNodeStateRules. Nodes which have not reported the Ready condition yet are not
+// regarded as not ready.
+func IsNodeNotReady(node *k8sCore.Node) bool {
+	return defaultStateClassifier.IsNodeNotReady(node)
 }
 
 // IsNodeDecommissioned returns true if the node has a decommissioning
 // taint with NoExecute effect. Nodes with decommissioning taint with any other effects (NoSchedule
 // and PreferNoSchedule) are considered schedulable.
 func IsNodeDecommissioned(node *k8sCore.Node) bool {
-	taint := FindTaint(node, commonNode.TaintKeyNodeDecommissioning)
-	return taint != nil && taint.Effect == k8sCore.TaintEffectNoExecute
+	return defaultStateClassifier.IsNodeDecommissioned(node)
 }
 
 // IsNodePhasedOut returns true if the node is tainted decommissioning with NoSchedule or PreferNoSchedule effect.
 // Such nodes are considered schedulable for most workloads with an exception of Titus Jobs with ActiveHost
 // hard constraints.
 func IsNodePhasedOut(node *k8sCore.Node) bool {
-	taint := FindTaint(node, commonNode.TaintKeyNodeDecommissioning)
-	return taint != nil && (taint.Effect == k8sCore.TaintEffectPreferNoSchedule ||
-		taint.Effect == k8sCore.TaintEffectNoSchedule)
+	return defaultStateClassifier.IsNodePhasedOut(node)
 }
 
 func IsNodeScalingDown(node *k8sCore.Node) bool {
-	return FindTaint(node, commonNode.TaintKeyNodeScalingDown) != nil
+	return defaultStateClassifier.IsNodeScalingDown(node)
 }
 
 func IsNodeEvacuating(node *k8sCore.Node) bool {
@@ -147,12 +103,11 @@ func IsNodeEvacuating(node *k8sCore.Node) bool {
 }
 
 func IsNodeToRemove(node *k8sCore.Node) bool {
-	return IsNodeDecommissioned(node) || IsNodeScalingDown(node) || IsNodeEvacuating(node)
+	return defaultStateClassifier.IsNodeToRemove(node)
 }
 
 func IsNodeRemovable(node *k8sCore.Node) bool {
-	_, ok := poolUtil.FindLabel(node.Labels, commonNode.LabelKeyRemovable)
-	return ok
+	return defaultStateClassifier.IsNodeRemovable(node)
 }
 
 func IsNodeUnremovable(node *k8sCore.Node) bool {
@@ -160,10 +115,11 @@ func IsNodeUnremovable(node *k8sCore.Node) bool {
 	return ok
 }
 
-// TODO There is no obvious way to determine if a node object corresponds to an existing node instance.
-// We trust here that node GC or node graceful shutdown deal with it quickly enough.
+// IsNodeTerminated returns true if the node instance is shut down, or the node is not reachable for a long time.
+// There is no obvious way to determine from the node object alone if it corresponds to an existing node instance.
+// A more accurate evaluation requires a StateClassifier with a cloud provider specific InstanceStateProvider.
 func IsNodeTerminated(node *k8sCore.Node) bool {
-	return false
+	return defaultStateClassifier.IsNodeTerminated(node)
 }
 
 func FindNodeResourcePool(node *k8sCore.Node) (string, bool) {
@@ -186,6 +142,14 @@ func FromNodeToComputeResource(node *k8sCore.Node) poolApi.ComputeResource {
 	return poolUtil.FromResourceListToComputeResource(node.Status.Allocatable)
 }
 
+func FromNodeToExactComputeResource(node *k8sCore.Node) poolUtil.ExactComputeResource {
+	return poolUtil.FromResourceListToExactComputeResource(node.Status.Allocatable)
+}
+
+func FromNodeToExtendedResources(node *k8sCore.Node, registry *poolUtil.ResourceRegistry) poolUtil.ExtendedResources {
+	return registry.ToExtendedResources(node.Status.Allocatable)
+}
+
 func FromNodeToPhysicalComputeResource(node *k8sCore.Node, machinesByName map[string]*machineTypeV1.MachineTypeConfig) (*poolApi.ComputeResource, bool) {
 	iType, ok := FindNodeInstanceType(node)
 	if !ok {
@@ -213,6 +177,15 @@ func Names(nodes []*k8sCore.Node) []string {
 	return names
 }
 
+func FindCondition(node *k8sCore.Node, conditionType k8sCore.NodeConditionType) *k8sCore.NodeCondition {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == conditionType {
+			return &condition
+		}
+	}
+	return nil
+}
+
 func FindTaint(node *k8sCore.Node, taintKey string) *k8sCore.Taint {
 	for _, taint := range node.Spec.Taints {
 		if taint.Key == taintKey {
@@ -8,12 +8,17 @@ import (
 
 	machineV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
 	v1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
 )
 
 type Metadata struct {
 	ResourcePool  string
 	NodeResources v1.ComputeResource
-	MachineType   *machineV1.MachineTypeConfig
+	// Node allocatable resources in exact units. NodeResources is derived from it if Options.ExactResources is set.
+	ExactNodeResources poolUtil.ExactComputeResource
+	// Registered extended resources of the node (see Options.ResourceRegistry).
+	ExtendedResources poolUtil.ExtendedResources
+	MachineType       *machineV1.MachineTypeConfig
 }
 
 // Node data snapshot with useful indexes for fast access. Snapshot struct can be mutated by calling the provided
@@ -25,9 +30,15 @@ type Snapshot struct {
 	BootstrappingByName map[string]*k8sCore.Node
 	ActiveByName        map[string]*k8sCore.Node
 	OnWayOutByName      map[string]*k8sCore.Node
-	MetadataByteName    map[string]*Metadata
-	// Explicitly excluded nodes which otherwise would be in AllByName and one of the BootstrappingByName, ActiveByName
-	// or OnWayOutByName collections. Primary use case is to exclude nodes running experimental Kube backends.
+	// Nodes past bootstrap stage, and not on their way out, with conditions that make them unhealthy (for example
+	// not ready for too long, or under memory pressure). Those nodes are not included in ActiveByName.
+	UnhealthyByName map[string]*k8sCore.Node
+	// Nodes with no backing instance. Those nodes are not included in any other collection except AllByName.
+	TerminatedByName map[string]*k8sCore.Node
+	MetadataByteName map[string]*Metadata
+	// Explicitly excluded nodes which otherwise would be in AllByName and one of the BootstrappingByName, ActiveByName,
+	// OnWayOutByName, UnhealthyByName or TerminatedByName collections. Primary use case is to exclude nodes running
+	// experimental Kube backends.
 	ExcludedByName map[string]*k8sCore.Node
 	// Internal state
 	machines map[string]*machineV1.MachineTypeConfig
@@ -39,6 +50,12 @@ type Options struct {
 	PastBootstrapDeadline func(node *k8sCore.Node, now time.Time) bool
 	// A predicate for identifying nodes to be excluded.
 	Exclude func(node *k8sCore.Node) bool
+	// Node state classifier. If not set, the classifier with DefaultNodeStateRules is used.
+	Classifier *StateClassifier
+	// If set, node resources are computed from exact allocatable values, with fractional parts truncated.
+	ExactResources bool
+	// Extended resources to track in the node metadata. If not set, only the built-in resources are tracked.
+	ResourceRegistry *poolUtil.ResourceRegistry
 }
 
 func NewEmptySnapshot() *Snapshot {
@@ -47,6 +64,8 @@ func NewEmptySnapshot() *Snapshot {
 		BootstrappingByName: map[string]*k8sCore.Node{},
 		ActiveByName:        map[string]*k8sCore.Node{},
 		OnWayOutByName:      map[string]*k8sCore.Node{},
+		UnhealthyByName:     map[string]*k8sCore.Node{},
+		TerminatedByName:    map[string]*k8sCore.Node{},
 		MetadataByteName:    map[string]*Metadata{},
 		ExcludedByName:      map[string]*k8sCore.Node{},
 		options:             Options{},
@@ -58,6 +77,7 @@ func NewSnapshotOfResourcePool(nodes []*k8sCore.Node, resourcePool string, machi
 	options Options) (*Snapshot, []*k8sCore.Node) {
 	now := time.Now()
 	pastBootstrapDeadline := currentPastBootstrapDeadline(options, now)
+	classifier := currentClassifier(options)
 
 	result := NewEmptySnapshot()
 	result.machines = machines
@@ -70,11 +90,15 @@ func NewSnapshotOfResourcePool(nodes []*k8sCore.Node, resourcePool string, machi
 				result.ExcludedByName[node.Name] = node
 			} else {
 				result.AllByName[node.Name] = node
-				result.MetadataByteName[node.Name] = buildMetadata(node, machines)
-				if IsNodeOnItsWayOut(node) {
+				result.MetadataByteName[node.Name] = buildMetadata(node, machines, options)
+				if classifier.IsNodeTerminatedAt(node, now) {
+					result.TerminatedByName[node.Name] = node
+				} else if classifier.IsNodeOnItsWayOut(node) {
 					result.OnWayOutByName[node.Name] = node
-				} else if IsNodeBootstrapping2(node, pastBootstrapDeadline) {
+				} else if classifier.IsNodeBootstrapping2(node, pastBootstrapDeadline) {
 					result.BootstrappingByName[node.Name] = node
+				} else if classifier.IsNodeUnhealthy(node, now) {
+					result.UnhealthyByName[node.Name] = node
 				} else {
 					result.ActiveByName[node.Name] = node
 				}
@@ -86,16 +110,25 @@ func NewSnapshotOfResourcePool(nodes []*k8sCore.Node, resourcePool string, machi
 	return result, other
 }
 
-func buildMetadata(node *k8sCore.Node, machines map[string]*machineV1.MachineTypeConfig) *Metadata {
+func buildMetadata(node *k8sCore.Node, machines map[string]*machineV1.MachineTypeConfig, options Options) *Metadata {
 	resourcePool, _ := FindNodeResourcePool(node)
 	var machineType *machineV1.MachineTypeConfig
 	if machineName, ok := FindNodeInstanceType(node); ok {
 		machineType = machines[machineName]
 	}
+	exactNodeResources := FromNodeToExactComputeResource(node)
+	var nodeResources v1.ComputeResource
+	if options.ExactResources {
+		nodeResources = exactNodeResources.ToComputeResource()
+	} else {
+		nodeResources = FromNodeToComputeResource(node)
+	}
 	return &Metadata{
-		ResourcePool:  resourcePool,
-		NodeResources: FromNodeToComputeResource(node),
-		MachineType:   machineType,
+		ResourcePool:       resourcePool,
+		NodeResources:      nodeResources,
+		ExactNodeResources: exactNodeResources,
+		ExtendedResources:  FromNodeToExtendedResources(node, options.ResourceRegistry),
+		MachineType:        machineType,
 	}
 }
 
@@ -113,6 +146,13 @@ func currentPastBootstrapDeadline(options Options, now time.Time) func(node *k8s
 	return pastBootstrapDeadline
 }
 
+func currentClassifier(options Options) *StateClassifier {
+	if options.Classifier == nil {
+		return defaultStateClassifier
+	}
+	return options.Classifier
+}
+
 // Add a node. If a node already exists, it is overridden. Returns true if the node was not in the snapshot yet.
 func (s *Snapshot) Add(node *k8sCore.Node) bool {
 	_, found := s.AllByName[node.Name]
@@ -126,23 +166,33 @@ func (s *Snapshot) Add(node *k8sCore.Node) bool {
 		delete(s.BootstrappingByName, node.Name)
 		delete(s.ActiveByName, node.Name)
 		delete(s.OnWayOutByName, node.Name)
+		delete(s.UnhealthyByName, node.Name)
+		delete(s.TerminatedByName, node.Name)
 		delete(s.MetadataByteName, node.Name)
 		return found
 	}
 
-	pastBootstrapDeadline := currentPastBootstrapDeadline(s.options, time.Now())
+	now := time.Now()
+	pastBootstrapDeadline := currentPastBootstrapDeadline(s.options, now)
 
 	delete(s.ExcludedByName, node.Name)
 	delete(s.BootstrappingByName, node.Name)
 	delete(s.ActiveByName, node.Name)
 	delete(s.OnWayOutByName, node.Name)
+	delete(s.UnhealthyByName, node.Name)
+	delete(s.TerminatedByName, node.Name)
 
 	s.AllByName[node.Name] = node
-	s.MetadataByteName[node.Name] = buildMetadata(node, s.machines)
-	if IsNodeOnItsWayOut(node) {
+	s.MetadataByteName[node.Name] = buildMetadata(node, s.machines, s.options)
+	classifier := currentClassifier(s.options)
+	if classifier.IsNodeTerminatedAt(node, now) {
+		s.TerminatedByName[node.Name] = node
+	} else if classifier.IsNodeOnItsWayOut(node) {
 		s.OnWayOutByName[node.Name] = node
-	} else if IsNodeBootstrapping2(node, pastBootstrapDeadline) {
+	} else if classifier.IsNodeBootstrapping2(node, pastBootstrapDeadline) {
 		s.BootstrappingByName[node.Name] = node
+	} else if classifier.IsNodeUnhealthy(node, now) {
+		s.UnhealthyByName[node.Name] = node
 	} else {
 		s.ActiveByName[node.Name] = node
 	}
@@ -161,6 +211,42 @@ func (s *Snapshot) Transform(nodeName string, transformer func(*k8sCore.Node)) (
 	return node, nil
 }
 
+// Returns the node state (one of NodeState* values) resolved from the snapshot collection the node belongs to.
+// Unlike UniqueNodeState, schedulable nodes that are phased out are reported as NodeStatePhasedOut.
+func (s *Snapshot) NodeState(nodeName string) (string, bool) {
+	node, ok := s.AllByName[nodeName]
+	if !ok {
+		return "", false
+	}
+	classifier := currentClassifier(s.options)
+	if _, ok := s.TerminatedByName[nodeName]; ok {
+		return NodeStateTerminated, true
+	}
+	if _, ok := s.BootstrappingByName[nodeName]; ok {
+		return NodeStateBootstrapping, true
+	}
+	if _, ok := s.UnhealthyByName[nodeName]; ok {
+		return NodeStateUnhealthy, true
+	}
+	if _, ok := s.ActiveByName[nodeName]; ok {
+		if classifier.IsNodePhasedOut(node) {
+			return NodeStatePhasedOut, true
+		}
+		return NodeStateActive, true
+	}
+	// Removable nodes often keep their decommissioning or scaling down taints, so this is checked first.
+	if classifier.IsNodeRemovable(node) {
+		return NodeStateRemovable, true
+	}
+	if classifier.IsNodeDecommissioned(node) {
+		return NodeStateDecommissioned, true
+	}
+	if classifier.IsNodeScalingDown(node) {
+		return NodeStateScalingDown, true
+	}
+	return NodeStateBroken, true
+}
+
 func (s *Snapshot) ContainsName(nodeName string) bool {
 	if _, ok := s.AllByName[nodeName]; ok {
 		return true
@@ -2,6 +2,7 @@ package node
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -54,3 +55,32 @@ func NewNodes(count int64, namePrefix string, resourcePoolName string,
 	}
 	return nodes
 }
+
+// FakeInstanceStateProvider reports as terminated nodes with the registered provider ids. Nodes with no provider id
+// are looked up by name.
+type FakeInstanceStateProvider struct {
+	Terminated map[string]bool
+}
+
+func NewFakeInstanceStateProvider(terminatedIDs ...string) *FakeInstanceStateProvider {
+	provider := &FakeInstanceStateProvider{Terminated: map[string]bool{}}
+	for _, id := range terminatedIDs {
+		provider.Terminated[id] = true
+	}
+	return provider
+}
+
+func (p *FakeInstanceStateProvider) MarkTerminated(node *coreV1.Node) {
+	p.Terminated[fakeInstanceID(node)] = true
+}
+
+func (p *FakeInstanceStateProvider) IsInstanceTerminated(node *coreV1.Node, _ time.Time) bool {
+	return p.Terminated[fakeInstanceID(node)]
+}
+
+func fakeInstanceID(node *coreV1.Node) string {
+	if node.Spec.ProviderID != "" {
+		return node.Spec.ProviderID
+	}
+	return node.Name
+}
@@ -62,3 +62,20 @@ func NewScalingDownTaintWithValue(now time.Time, value string) *v1.Taint {
 func NewScalingDownTaint(source string, now time.Time) *v1.Taint {
 	return NewScalingDownTaintWithValue(now, source)
 }
+
+func ButNodeCondition(node *v1.Node, conditionType v1.NodeConditionType, status v1.ConditionStatus,
+	lastTransition time.Time) *v1.Node {
+	condition := v1.NodeCondition{
+		Type:               conditionType,
+		Status:             status,
+		LastTransitionTime: metaV1.Time{Time: lastTransition},
+	}
+	for i, existing := range node.Status.Conditions {
+		if existing.Type == conditionType {
+			node.Status.Conditions[i] = condition
+			return node
+		}
+	}
+	node.Status.Conditions = append(node.Status.Conditions, condition)
+	return node
+}
@@ -1,6 +1,8 @@
 package pod
 
 import (
+	"sort"
+
 	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
 	v1 "k8s.io/api/core/v1"
 
@@ -8,10 +10,13 @@ import (
 )
 
 func FormatPod(pod *v1.Pod, options poolUtil.FormatterOptions) string {
+	if options.IsTableOutput() {
+		return FormatPodsTable([]*v1.Pod{pod}, options)
+	}
 	if options.Level == poolUtil.FormatCompact {
 		return formatPodCompact(pod)
 	} else if options.Level == poolUtil.FormatEssentials {
-		return formatPodEssentials(pod)
+		return formatPodEssentials(pod, options.ResourceRegistry)
 	} else if options.Level == poolUtil.FormatDetails {
 		return poolUtil.ToJSONString(pod)
 	}
@@ -32,22 +37,39 @@ func formatPodCompact(pod *v1.Pod) string {
 	return poolUtil.ToJSONString(value)
 }
 
-func formatPodEssentials(pod *v1.Pod) string {
+func formatPodEssentials(pod *v1.Pod, registry *poolUtil.ResourceRegistry) string {
 	type Compact struct {
-		Name             string
-		State            string
-		Node             string
-		ComputeResources poolV1.ComputeResource
+		Name              string
+		State             string
+		Node              string
+		ComputeResources  poolV1.ComputeResource
+		ExtendedResources poolUtil.ExtendedResources `json:",omitempty"`
 	}
 	value := Compact{
-		Name:             pod.Name,
-		State:            toPodState(pod),
-		Node:             pod.Spec.NodeName,
-		ComputeResources: FromPodToComputeResource(pod),
+		Name:              pod.Name,
+		State:             toPodState(pod),
+		Node:              pod.Spec.NodeName,
+		ComputeResources:  FromPodToComputeResource(pod),
+		ExtendedResources: FromPodToExtendedResources(pod, registry),
 	}
 	return poolUtil.ToJSONString(value)
 }
 
+// FormatPodsTable renders pods as a text table, sorted by name unless options.SortBy is set.
+func FormatPodsTable(pods []*v1.Pod, options poolUtil.FormatterOptions) string {
+	sorted := make([]*v1.Pod, len(pods))
+	copy(sorted, pods)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	table := poolUtil.NewTable(append([]string{"NAME", "STATE", "NODE"}, poolUtil.ComputeResourceColumns...)...)
+	for _, pod := range sorted {
+		table.AddCells(append(poolUtil.Cells(pod.Name, toPodState(pod), pod.Spec.NodeName),
+			poolUtil.ComputeResourceCells(FromPodToComputeResource(pod))...)...)
+	}
+	return table.Render(options)
+}
+
 func toPodState(pod *v1.Pod) string {
 	if IsPodRunning(pod) {
 		return "running"
@@ -0,0 +1,190 @@
+package pod
+
+import (
+	"fmt"
+	"strconv"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+)
+
+const (
+	PlacementViolationTaint        = "taint"
+	PlacementViolationNodeSelector = "nodeSelector"
+	PlacementViolationNodeAffinity = "nodeAffinity"
+	PlacementViolationGPU          = "gpu"
+)
+
+type PlacementOptions struct {
+	// If set, PreferNoSchedule taints are treated as hard constraints. This is useful when evaluating capacity
+	// that should not depend on phased out nodes.
+	StrictPreferNoSchedule bool
+	// If set, GPU requests are not checked.
+	IgnoreGPUs bool
+	// Controls how the pod GPU request is computed.
+	Resources ResourceOptions
+}
+
+// PlacementViolation describes a single reason why a pod cannot be placed on a node.
+type PlacementViolation struct {
+	Kind    string
+	Message string
+}
+
+// Predicate telling if a pod can be placed on a node.
+type PlacementPredicate func(pod *k8sCore.Pod, node *k8sCore.Node) bool
+
+func NewPlacementPredicate(options PlacementOptions) PlacementPredicate {
+	return func(pod *k8sCore.Pod, node *k8sCore.Node) bool {
+		return CanPodBePlacedOnNode(pod, node, options)
+	}
+}
+
+// CanPodBePlacedOnNode returns true if the pod tolerates the node taints, and the node matches the pod node selector,
+// required node affinity and GPU requirements. Resource availability is not checked.
+func CanPodBePlacedOnNode(pod *k8sCore.Pod, node *k8sCore.Node, options PlacementOptions) bool {
+	return len(FindPlacementViolations(pod, node, options)) == 0
+}
+
+// FindPlacementViolations returns all reasons why a pod cannot be placed on a node, or an empty list if there are none.
+func FindPlacementViolations(pod *k8sCore.Pod, node *k8sCore.Node, options PlacementOptions) []PlacementViolation {
+	violations := []PlacementViolation{}
+	if taint, ok := FindUntoleratedTaint(pod, node, options.StrictPreferNoSchedule); !ok {
+		violations = append(violations, PlacementViolation{
+			Kind:    PlacementViolationTaint,
+			Message: fmt.Sprintf("taint not tolerated: %s=%s:%s", taint.Key, taint.Value, taint.Effect),
+		})
+	}
+	for key, value := range pod.Spec.NodeSelector {
+		if nodeValue, ok := node.Labels[key]; !ok || nodeValue != value {
+			violations = append(violations, PlacementViolation{
+				Kind:    PlacementViolationNodeSelector,
+				Message: fmt.Sprintf("node label %s=%s required", key, value),
+			})
+		}
+	}
+	if !MatchesRequiredNodeAffinity(pod, node) {
+		violations = append(violations, PlacementViolation{
+			Kind:    PlacementViolationNodeAffinity,
+			Message: "none of the required node affinity terms match",
+		})
+	}
+	if !options.IgnoreGPUs {
+		if requested := FromPodToComputeResourceWithOptions(pod, options.Resources).GPU; requested > 0 {
+			if available := poolNode.FromNodeToComputeResource(node).GPU; available < requested {
+				violations = append(violations, PlacementViolation{
+					Kind:    PlacementViolationGPU,
+					Message: fmt.Sprintf("requested %d GPUs, node has %d", requested, available),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// FindUntoleratedTaint returns the first node taint with NoSchedule or NoExecute effect, which is not tolerated by
+// the pod. PreferNoSchedule taints are checked only if strictPreferNoSchedule is set. The second return value is
+// false if such a taint exists.
+func FindUntoleratedTaint(pod *k8sCore.Pod, node *k8sCore.Node, strictPreferNoSchedule bool) (*k8sCore.Taint, bool) {
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == k8sCore.TaintEffectPreferNoSchedule && !strictPreferNoSchedule {
+			continue
+		}
+		if !toleratesTaint(pod.Spec.Tolerations, taint) {
+			return taint, false
+		}
+	}
+	return nil, true
+}
+
+func toleratesTaint(tolerations []k8sCore.Toleration, taint *k8sCore.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesRequiredNodeAffinity returns true if the pod has no required node affinity, or if at least one of its node
+// selector terms matches the node.
+func MatchesRequiredNodeAffinity(pod *k8sCore.Pod, node *k8sCore.Node) bool {
+	terms := getRequiredNodeSelectorTerms(pod)
+	if terms == nil {
+		return true
+	}
+	return MatchNodeSelectorTerms(terms, node.Labels, node.Name)
+}
+
+// MatchNodeSelectorTerms returns true if any of the terms matches. Terms are evaluated the same way as by the Kube
+// scheduler: requirements within a term are ANDed, and a term with no requirements matches nothing.
+func MatchNodeSelectorTerms(terms []k8sCore.NodeSelectorTerm, labels map[string]string, nodeName string) bool {
+	for _, term := range terms {
+		if MatchNodeSelectorTerm(term, labels, nodeName) {
+			return true
+		}
+	}
+	return false
+}
+
+func MatchNodeSelectorTerm(term k8sCore.NodeSelectorTerm, labels map[string]string, nodeName string) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	for _, requirement := range term.MatchExpressions {
+		value, exists := labels[requirement.Key]
+		if !MatchNodeSelectorRequirement(requirement, value, exists) {
+			return false
+		}
+	}
+	for _, requirement := range term.MatchFields {
+		// metadata.name is the only field supported by Kube.
+		if requirement.Key != "metadata.name" || !MatchNodeSelectorRequirement(requirement, nodeName, true) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchNodeSelectorRequirement evaluates a single requirement against a label value. The exists argument tells if
+// the label is set at all.
+func MatchNodeSelectorRequirement(requirement k8sCore.NodeSelectorRequirement, value string, exists bool) bool {
+	switch requirement.Operator {
+	case k8sCore.NodeSelectorOpIn:
+		return exists && containsString(requirement.Values, value)
+	case k8sCore.NodeSelectorOpNotIn:
+		return !exists || !containsString(requirement.Values, value)
+	case k8sCore.NodeSelectorOpExists:
+		return exists
+	case k8sCore.NodeSelectorOpDoesNotExist:
+		return !exists
+	case k8sCore.NodeSelectorOpGt, k8sCore.NodeSelectorOpLt:
+		if !exists || len(requirement.Values) != 1 {
+			return false
+		}
+		actual, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		expected, err := strconv.ParseInt(requirement.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if requirement.Operator == k8sCore.NodeSelectorOpGt {
+			return actual > expected
+		}
+		return actual < expected
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, next := range values {
+		if next == value {
+			return true
+		}
+	}
+	return false
+}
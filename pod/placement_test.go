@@ -0,0 +1,120 @@
+package pod
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	poolApi "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+	"github.com/Netflix/titus-resource-pool/node"
+	commonNode "stash.corp.netflix.com/tn/titus-kube-common/node"
+)
+
+func TestPlacementTaints(t *testing.T) {
+	phasedOut := node.ButNodeWithTaint(node.NewRandomNode(), &k8sCore.Taint{
+		Key:    commonNode.TaintKeyNodeDecommissioning,
+		Effect: k8sCore.TaintEffectPreferNoSchedule,
+	})
+	require.True(t, CanPodBePlacedOnNode(EmptyPod(), phasedOut, PlacementOptions{}))
+	require.False(t, CanPodBePlacedOnNode(EmptyPod(), phasedOut, PlacementOptions{StrictPreferNoSchedule: true}))
+
+	tainted := node.ButNodeWithTaint(node.NewRandomNode(), &k8sCore.Taint{
+		Key:    "example.com/dedicated",
+		Value:  "team",
+		Effect: k8sCore.TaintEffectNoSchedule,
+	})
+	violations := FindPlacementViolations(EmptyPod(), tainted, PlacementOptions{})
+	require.Len(t, violations, 1)
+	require.Equal(t, PlacementViolationTaint, violations[0].Kind)
+
+	tolerating := EmptyPod()
+	tolerating.Spec.Tolerations = []k8sCore.Toleration{{
+		Key:      "example.com/dedicated",
+		Operator: k8sCore.TolerationOpEqual,
+		Value:    "team",
+		Effect:   k8sCore.TaintEffectNoSchedule,
+	}}
+	require.True(t, CanPodBePlacedOnNode(tolerating, tainted, PlacementOptions{}))
+
+	tolerateAll := EmptyPod()
+	tolerateAll.Spec.Tolerations = []k8sCore.Toleration{{Operator: k8sCore.TolerationOpExists}}
+	require.True(t, CanPodBePlacedOnNode(tolerateAll, tainted, PlacementOptions{}))
+	require.True(t, CanPodBePlacedOnNode(tolerateAll, phasedOut, PlacementOptions{StrictPreferNoSchedule: true}))
+}
+
+func TestPlacementNodeSelector(t *testing.T) {
+	labeled := node.ButNodeLabel(node.NewRandomNode(), "zone", "a")
+	pod := EmptyPod()
+	pod.Spec.NodeSelector = map[string]string{"zone": "a"}
+	require.True(t, CanPodBePlacedOnNode(pod, labeled, PlacementOptions{}))
+	require.False(t, CanPodBePlacedOnNode(pod, node.NewRandomNode(), PlacementOptions{}))
+	pod.Spec.NodeSelector = map[string]string{"zone": "b"}
+	require.False(t, CanPodBePlacedOnNode(pod, labeled, PlacementOptions{}))
+}
+
+func TestPlacementNodeAffinity(t *testing.T) {
+	target := node.ButNodeLabel(node.ButNodeLabel(node.NewRandomNode(), "zone", "a"), "generation", "5")
+
+	withAffinity := func(terms ...k8sCore.NodeSelectorTerm) *k8sCore.Pod {
+		pod := EmptyPod()
+		pod.Spec.Affinity = &k8sCore.Affinity{NodeAffinity: &k8sCore.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &k8sCore.NodeSelector{NodeSelectorTerms: terms},
+		}}
+		return pod
+	}
+	term := func(requirements ...k8sCore.NodeSelectorRequirement) k8sCore.NodeSelectorTerm {
+		return k8sCore.NodeSelectorTerm{MatchExpressions: requirements}
+	}
+	requirement := func(key string, operator k8sCore.NodeSelectorOperator, values ...string) k8sCore.NodeSelectorRequirement {
+		return k8sCore.NodeSelectorRequirement{Key: key, Operator: operator, Values: values}
+	}
+
+	require.True(t, MatchesRequiredNodeAffinity(EmptyPod(), target))
+	require.True(t, MatchesRequiredNodeAffinity(withAffinity(term(
+		requirement("zone", k8sCore.NodeSelectorOpIn, "a", "b"),
+		requirement("zone", k8sCore.NodeSelectorOpNotIn, "c"),
+		requirement("generation", k8sCore.NodeSelectorOpGt, "4"),
+		requirement("generation", k8sCore.NodeSelectorOpLt, "6"),
+		requirement("zone", k8sCore.NodeSelectorOpExists),
+		requirement("missing", k8sCore.NodeSelectorOpDoesNotExist),
+	)), target))
+
+	// All requirements in a term must match
+	require.False(t, MatchesRequiredNodeAffinity(withAffinity(term(
+		requirement("zone", k8sCore.NodeSelectorOpIn, "a"),
+		requirement("generation", k8sCore.NodeSelectorOpGt, "5"),
+	)), target))
+	// Any term may match
+	require.True(t, MatchesRequiredNodeAffinity(withAffinity(
+		term(requirement("zone", k8sCore.NodeSelectorOpIn, "b")),
+		term(requirement("zone", k8sCore.NodeSelectorOpIn, "a")),
+	), target))
+	// Empty term matches nothing
+	require.False(t, MatchesRequiredNodeAffinity(withAffinity(term()), target))
+	// Match by node name
+	require.True(t, MatchesRequiredNodeAffinity(withAffinity(k8sCore.NodeSelectorTerm{
+		MatchFields: []k8sCore.NodeSelectorRequirement{requirement("metadata.name", k8sCore.NodeSelectorOpIn, target.Name)},
+	}), target))
+	// Instance type is evaluated as any other label
+	require.False(t, CanPodBePlacedOnNode(ButPodMachineRequiredAffinity(EmptyPod(), []string{"m5.metal"}), target,
+		PlacementOptions{}))
+	require.True(t, CanPodBePlacedOnNode(ButPodMachineRequiredAffinity(EmptyPod(), []string{"r5.metal"}), target,
+		PlacementOptions{}))
+}
+
+func TestPlacementGPU(t *testing.T) {
+	gpuPod := ButPodResources(NewRandomNotScheduledPod(), poolApi.ComputeResource{CPU: 1, GPU: 1})
+	cpuNode := node.NewNode("cpu", node.ResourcePoolElastic, machine.R5Metal())
+	violations := FindPlacementViolations(gpuPod, cpuNode, PlacementOptions{})
+	require.Len(t, violations, 1)
+	require.Equal(t, PlacementViolationGPU, violations[0].Kind)
+	require.True(t, CanPodBePlacedOnNode(gpuPod, cpuNode, PlacementOptions{IgnoreGPUs: true}))
+
+	gpuMachine := machine.R5Metal()
+	gpuMachine.Spec.GPU = 4
+	gpuNode := node.NewNode("gpu", node.ResourcePoolElastic, gpuMachine)
+	require.True(t, CanPodBePlacedOnNode(gpuPod, gpuNode, PlacementOptions{}))
+}
@@ -19,6 +19,8 @@ import (
 // TODO Remove when no longer in use
 const legacyInstanceTypeLabel = "beta.kubernetes.io/instance-type"
 
+var instanceTypeLabels = []string{commonNode.LabelKeyInstanceType, legacyInstanceTypeLabel}
+
 func AsPodReferenceList(podList *k8sCore.PodList) []*k8sCore.Pod {
 	result := []*k8sCore.Pod{}
 	for _, node := range podList.Items {
@@ -28,21 +30,82 @@ func AsPodReferenceList(podList *k8sCore.PodList) []*k8sCore.Pod {
 	return result
 }
 
-// Return machine types explicitly requested by pod using hard affinity rules.
+// Return machine types explicitly requested by pod using hard affinity rules or the node selector. Only machine types
+// listed by the `In` operator (or set in the node selector) are returned, and only if they are accepted by all
+// other instance type constraints of the pod (see IsMachineTypeAcceptedByPod). An empty result means that the pod
+// does not name any machine types, which does not imply that all machine types are accepted (for example
+// for a pod with `NotIn` constraint only).
 func GetPodRequestedMachineTypes(pod *k8sCore.Pod) []string {
-	if pod.Spec.Affinity == nil || pod.Spec.Affinity.NodeAffinity == nil ||
-		pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
-		return []string{}
+	result := []string{}
+	seen := map[string]bool{}
+	addIfAccepted := func(machineType string) {
+		if !seen[machineType] && IsMachineTypeAcceptedByPod(pod, machineType) {
+			result = append(result, machineType)
+		}
+		seen[machineType] = true
 	}
-	terms := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
-	for _, term := range terms {
+	for _, key := range instanceTypeLabels {
+		if machineType, ok := pod.Spec.NodeSelector[key]; ok {
+			addIfAccepted(machineType)
+		}
+	}
+	for _, term := range getRequiredNodeSelectorTerms(pod) {
 		for _, expr := range term.MatchExpressions {
-			if expr.Key == commonNode.LabelKeyInstanceType || expr.Key == legacyInstanceTypeLabel {
-				return expr.Values
+			if isInstanceTypeLabel(expr.Key) && expr.Operator == k8sCore.NodeSelectorOpIn {
+				for _, machineType := range expr.Values {
+					addIfAccepted(machineType)
+				}
 			}
 		}
 	}
-	return []string{}
+	return result
+}
+
+// IsMachineTypeAcceptedByPod returns true if a node of the given machine type satisfies the instance type
+// constraints of the pod node selector and required node affinity. Node affinity terms are ORed, and the requirements
+// within a term are ANDed. Requirements not related to the instance type are ignored.
+func IsMachineTypeAcceptedByPod(pod *k8sCore.Pod, machineType string) bool {
+	for _, key := range instanceTypeLabels {
+		if value, ok := pod.Spec.NodeSelector[key]; ok && value != machineType {
+			return false
+		}
+	}
+	terms := getRequiredNodeSelectorTerms(pod)
+	if terms == nil {
+		return true
+	}
+	for _, term := range terms {
+		if isMachineTypeAcceptedByTerm(term, machineType) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMachineTypeAcceptedByTerm(term k8sCore.NodeSelectorTerm, machineType string) bool {
+	// Consistent with MatchNodeSelectorTerm, an empty term matches nothing.
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	for _, expr := range term.MatchExpressions {
+		if isInstanceTypeLabel(expr.Key) && !MatchNodeSelectorRequirement(expr, machineType, true) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns nil if the pod has no required node affinity.
+func getRequiredNodeSelectorTerms(pod *k8sCore.Pod) []k8sCore.NodeSelectorTerm {
+	if pod.Spec.Affinity == nil || pod.Spec.Affinity.NodeAffinity == nil ||
+		pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return nil
+	}
+	return pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+}
+
+func isInstanceTypeLabel(key string) bool {
+	return key == commonNode.LabelKeyInstanceType || key == legacyInstanceTypeLabel
 }
 
 // See IsPodOkWithMachineTypes to understand the filtering criteria.
@@ -57,23 +120,16 @@ func FilterPodsOkWithMachineTypes(pods []*k8sCore.Pod, machineTypes []string) []
 	return result
 }
 
-// Returns true if the given pod can be run on the provided machine types. If the machine types list is empty, returns
-// false. Otherwise for a pod to not match, it must have a machine hard constraint with machine types disjoned with
-// the provided set.
+// Returns true if the given pod can be run on at least one of the provided machine types. If the machine types list
+// is empty, returns false. Instance type constraints are evaluated with IsMachineTypeAcceptedByPod.
 //
 // For example if machinesTypes=["r5.metal", "m5.metal"] and pod requires ["c5.metal], it will not be added to the
 // result.
 // If machinesTypes=["r5.metal", "m5.metal"] and pod requires ["r5.metal", "c5.metal], it will be added to the result.
+// If machinesTypes=["r5.metal"] and pod requires machine type not in ["r5.metal"], it will not be added to the result.
 func IsPodOkWithMachineTypesSet(pod *k8sCore.Pod, machineTypes map[string]bool) bool {
-	if len(machineTypes) == 0 {
-		return false
-	}
-	requestedList := GetPodRequestedMachineTypes(pod)
-	if len(requestedList) == 0 {
-		return true
-	}
-	for _, next := range requestedList {
-		if _, ok := machineTypes[next]; ok {
+	for machineType := range machineTypes {
+		if IsMachineTypeAcceptedByPod(pod, machineType) {
 			return true
 		}
 	}
@@ -247,12 +303,17 @@ func PodBelongsToResourcePool(pod *k8sCore.Pod, assignedPools []string, resource
 	return false
 }
 
+// FromPodToComputeResource returns the effective pod resource requests, including init containers and the pod
+// overhead. See FromPodToComputeResourceWithOptions.
 func FromPodToComputeResource(pod *k8sCore.Pod) poolApi.ComputeResource {
-	total := poolApi.ComputeResource{}
-	for _, container := range pod.Spec.Containers {
-		total = total.Add(poolUtil.FromResourceListToComputeResource(container.Resources.Requests))
-	}
-	return total
+	return FromPodToComputeResourceWithOptions(pod, ResourceOptions{})
+}
+
+// FromPodToExactComputeResource returns the effective pod resource requests in exact units. Unlike
+// FromPodToComputeResource, CPU requests are not rounded up to whole CPUs per container, and memory is not
+// truncated to MB.
+func FromPodToExactComputeResource(pod *k8sCore.Pod) poolUtil.ExactComputeResource {
+	return FromPodToExactComputeResourceWithOptions(pod, ResourceOptions{})
 }
 
 func Names(pods *[]k8sCore.Pod) []string {
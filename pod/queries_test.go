@@ -6,10 +6,12 @@ import (
 	"github.com/stretchr/testify/require"
 
 	k8sCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	"github.com/Netflix/titus-resource-pool/machine"
 	"github.com/Netflix/titus-resource-pool/node"
 	"github.com/Netflix/titus-resource-pool/util/xcollection"
+	commonNode "stash.corp.netflix.com/tn/titus-kube-common/node"
 )
 
 var machineTypes = []string{"r5.metal", "m5.metal"}
@@ -23,6 +25,63 @@ func TestGetPodRequestedMachineTypes(t *testing.T) {
 	)
 }
 
+func TestGetPodRequestedMachineTypesWithManyTerms(t *testing.T) {
+	pod := ButPodMachineRequiredAffinity(ButPodMachineRequiredAffinity(EmptyPod(), []string{"r5.metal"}),
+		[]string{"m5.metal", "r5.metal"})
+	require.Equal(t, []string{"r5.metal", "m5.metal"}, GetPodRequestedMachineTypes(pod))
+
+	// Machine types excluded in the same term are removed
+	term := &pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[1]
+	term.MatchExpressions = append(term.MatchExpressions, k8sCore.NodeSelectorRequirement{
+		Key:      commonNode.LabelKeyInstanceType,
+		Operator: k8sCore.NodeSelectorOpNotIn,
+		Values:   []string{"m5.metal"},
+	})
+	require.Equal(t, []string{"r5.metal"}, GetPodRequestedMachineTypes(pod))
+
+	withSelector := EmptyPod()
+	withSelector.Spec.NodeSelector = map[string]string{commonNode.LabelKeyInstanceType: "m5.metal"}
+	require.Equal(t, []string{"m5.metal"}, GetPodRequestedMachineTypes(withSelector))
+}
+
+func TestIsMachineTypeAcceptedByPod(t *testing.T) {
+	withRequirements := func(requirements ...k8sCore.NodeSelectorRequirement) *k8sCore.Pod {
+		pod := EmptyPod()
+		pod.Spec.Affinity = &k8sCore.Affinity{NodeAffinity: &k8sCore.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &k8sCore.NodeSelector{
+				NodeSelectorTerms: []k8sCore.NodeSelectorTerm{{MatchExpressions: requirements}},
+			},
+		}}
+		return pod
+	}
+	instanceType := func(operator k8sCore.NodeSelectorOperator, values ...string) k8sCore.NodeSelectorRequirement {
+		return k8sCore.NodeSelectorRequirement{Key: commonNode.LabelKeyInstanceType, Operator: operator, Values: values}
+	}
+
+	require.True(t, IsMachineTypeAcceptedByPod(EmptyPod(), "r5.metal"))
+
+	notIn := withRequirements(instanceType(k8sCore.NodeSelectorOpNotIn, "r5.metal"))
+	require.False(t, IsMachineTypeAcceptedByPod(notIn, "r5.metal"))
+	require.True(t, IsMachineTypeAcceptedByPod(notIn, "m5.metal"))
+	require.Empty(t, GetPodRequestedMachineTypes(notIn))
+	require.True(t, IsPodOkWithMachineTypesSet(notIn, machineTypeSet))
+	require.False(t, IsPodOkWithMachineTypesSet(notIn, map[string]bool{"r5.metal": true}))
+
+	require.True(t, IsMachineTypeAcceptedByPod(withRequirements(instanceType(k8sCore.NodeSelectorOpExists)), "r5.metal"))
+	require.False(t, IsMachineTypeAcceptedByPod(withRequirements(instanceType(k8sCore.NodeSelectorOpDoesNotExist)), "r5.metal"))
+
+	// Other labels are ignored
+	otherLabel := withRequirements(k8sCore.NodeSelectorRequirement{Key: "zone", Operator: k8sCore.NodeSelectorOpIn, Values: []string{"a"}})
+	require.True(t, IsMachineTypeAcceptedByPod(otherLabel, "r5.metal"))
+
+	// Node selector and affinity must both match
+	withSelector := ButPodMachineRequiredAffinity(EmptyPod(), machineTypes)
+	withSelector.Spec.NodeSelector = map[string]string{commonNode.LabelKeyInstanceType: "m5.metal"}
+	require.True(t, IsMachineTypeAcceptedByPod(withSelector, "m5.metal"))
+	require.False(t, IsMachineTypeAcceptedByPod(withSelector, "r5.metal"))
+	require.False(t, IsPodOkWithMachineTypesSet(withSelector, map[string]bool{"r5.metal": true}))
+}
+
 func TestFilterPodsOkWithMachineTypes(t *testing.T) {
 	filtered := FilterPodsOkWithMachineTypes(
 		[]*k8sCore.Pod{
@@ -67,3 +126,29 @@ func TestScheduledPodBelongsToResourcePool(t *testing.T) {
 	require.False(t, PodBelongsToResourcePool(pod, []string{"pool1", "pool2"}, "pool2", false, nodes))
 	require.False(t, PodBelongsToResourcePool(pod, []string{"pool1", "pool2"}, "pool3", false, nodes))
 }
+
+func TestFromPodToExactComputeResource(t *testing.T) {
+	pod := NewRandomNotScheduledPod()
+	halfCPU := k8sCore.ResourceList{
+		k8sCore.ResourceCPU:    resource.MustParse("500m"),
+		k8sCore.ResourceMemory: resource.MustParse("1536Ki"),
+	}
+	pod.Spec.Containers = []k8sCore.Container{
+		{Name: "main", Resources: k8sCore.ResourceRequirements{Requests: halfCPU}},
+		{Name: "sidecar", Resources: k8sCore.ResourceRequirements{Requests: halfCPU}},
+	}
+
+	exact := FromPodToExactComputeResource(pod)
+	require.EqualValues(t, 1000, exact.MilliCPU)
+	require.EqualValues(t, 3*1024*1024, exact.MemoryBytes)
+	require.EqualValues(t, 1, exact.ToComputeResourceRoundUp().CPU)
+	require.EqualValues(t, 3, exact.ToComputeResourceRoundUp().MemoryMB)
+	require.EqualValues(t, 2, FromPodToComputeResource(pod).CPU)
+	require.EqualValues(t, 2, FromPodToComputeResource(pod).MemoryMB)
+
+	snapshot, _ := NewSnapshotOfResourcePool([]*k8sCore.Pod{pod}, node.ResourcePoolElastic, Options{})
+	require.EqualValues(t, 2, snapshot.Metadata[pod.Name].PodResources.CPU)
+	snapshot, _ = NewSnapshotOfResourcePool([]*k8sCore.Pod{pod}, node.ResourcePoolElastic, Options{ExactResources: true})
+	require.EqualValues(t, 1, snapshot.Metadata[pod.Name].PodResources.CPU)
+	require.Equal(t, exact, snapshot.Metadata[pod.Name].ExactPodResources)
+}
@@ -0,0 +1,121 @@
+package pod
+
+import (
+	k8sCore "k8s.io/api/core/v1"
+
+	poolApi "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
+)
+
+// ResourceOptions controls how pod resources are computed.
+type ResourceOptions struct {
+	// If set, container limits are used instead of requests. Resources with no limit fall back to the request value.
+	UseLimits bool
+	// If set, Spec.Overhead is not added to the pod resources.
+	ExcludeOverhead bool
+	// Predicate identifying restartable (sidecar) init containers, which keep running next to the main containers.
+	// The Kube API version used by this library does not expose the container restart policy, so sidecars must be
+	// identified by the client (for example by container name or an annotation). If not set, all init containers
+	// are regarded as regular ones.
+	IsRestartableInitContainer func(pod *k8sCore.Pod, container *k8sCore.Container) bool
+}
+
+// FromPodToComputeResourceWithOptions returns the effective pod resources, following the Kube scheduler rules:
+// max(sum of containers and sidecars, the biggest init container with sidecars started before it) plus the pod
+// overhead. CPU of each container is rounded up to a whole CPU, and memory and disk are truncated to MB.
+func FromPodToComputeResourceWithOptions(pod *k8sCore.Pod, options ResourceOptions) poolApi.ComputeResource {
+	return computeEffectivePodResources(pod, options, func(resources k8sCore.ResourceList) podResources {
+		return podResources{
+			exact: poolUtil.FromComputeResourceToExact(poolUtil.FromResourceListToComputeResource(resources)),
+		}
+	}).exact.ToComputeResource()
+}
+
+// FromPodToExactComputeResourceWithOptions is FromPodToComputeResourceWithOptions computed in exact units.
+func FromPodToExactComputeResourceWithOptions(pod *k8sCore.Pod, options ResourceOptions) poolUtil.ExactComputeResource {
+	return computeEffectivePodResources(pod, options, func(resources k8sCore.ResourceList) podResources {
+		return podResources{exact: poolUtil.FromResourceListToExactComputeResource(resources)}
+	}).exact
+}
+
+// FromPodToExtendedResources returns the effective pod requests of the registered extended resources.
+func FromPodToExtendedResources(pod *k8sCore.Pod, registry *poolUtil.ResourceRegistry) poolUtil.ExtendedResources {
+	return FromPodToExtendedResourcesWithOptions(pod, registry, ResourceOptions{})
+}
+
+// FromPodToExtendedResourcesWithOptions computes the effective pod extended resources with the same rules as
+// FromPodToComputeResourceWithOptions.
+func FromPodToExtendedResourcesWithOptions(pod *k8sCore.Pod, registry *poolUtil.ResourceRegistry,
+	options ResourceOptions) poolUtil.ExtendedResources {
+	extended := computeEffectivePodResources(pod, options, func(resources k8sCore.ResourceList) podResources {
+		return podResources{extended: registry.ToExtendedResources(resources)}
+	}).extended
+	if extended == nil {
+		return poolUtil.ExtendedResources{}
+	}
+	return extended
+}
+
+// Built-in and extended resources summed up together by computeEffectivePodResources. Callers set only the part
+// they need in the conversion function. Extended resources stay nil if the conversion function does not set them.
+type podResources struct {
+	exact    poolUtil.ExactComputeResource
+	extended poolUtil.ExtendedResources
+}
+
+func (r podResources) add(o podResources) podResources {
+	result := podResources{exact: r.exact.Add(o.exact)}
+	if r.extended != nil || o.extended != nil {
+		result.extended = r.extended.Add(o.extended)
+	}
+	return result
+}
+
+func (r podResources) max(o podResources) podResources {
+	result := podResources{exact: r.exact.Max(o.exact)}
+	if r.extended != nil || o.extended != nil {
+		result.extended = r.extended.Max(o.extended)
+	}
+	return result
+}
+
+func computeEffectivePodResources(pod *k8sCore.Pod, options ResourceOptions,
+	convert func(k8sCore.ResourceList) podResources) podResources {
+	containers := podResources{}
+	for i := range pod.Spec.Containers {
+		containers = containers.add(convert(containerResources(&pod.Spec.Containers[i], options)))
+	}
+
+	sidecars := podResources{}
+	initPeak := podResources{}
+	for i := range pod.Spec.InitContainers {
+		container := &pod.Spec.InitContainers[i]
+		resources := convert(containerResources(container, options))
+		if options.IsRestartableInitContainer != nil && options.IsRestartableInitContainer(pod, container) {
+			sidecars = sidecars.add(resources)
+			initPeak = initPeak.max(sidecars)
+		} else {
+			initPeak = initPeak.max(resources.add(sidecars))
+		}
+	}
+
+	total := containers.add(sidecars).max(initPeak)
+	if !options.ExcludeOverhead && pod.Spec.Overhead != nil {
+		total = total.add(convert(pod.Spec.Overhead))
+	}
+	return total
+}
+
+func containerResources(container *k8sCore.Container, options ResourceOptions) k8sCore.ResourceList {
+	if !options.UseLimits {
+		return container.Resources.Requests
+	}
+	result := k8sCore.ResourceList{}
+	for name, value := range container.Resources.Requests {
+		result[name] = value
+	}
+	for name, value := range container.Resources.Limits {
+		result[name] = value
+	}
+	return result
+}
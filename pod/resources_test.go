@@ -0,0 +1,100 @@
+package pod
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	poolApi "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
+)
+
+func newContainer(name string, requests poolApi.ComputeResource) k8sCore.Container {
+	return k8sCore.Container{
+		Name:      name,
+		Resources: k8sCore.ResourceRequirements{Requests: poolUtil.FromComputeResourceToResourceList(requests)},
+	}
+}
+
+func TestEffectivePodResources(t *testing.T) {
+	pod := EmptyPod()
+	pod.Spec.Containers = []k8sCore.Container{
+		newContainer("main", poolApi.ComputeResource{CPU: 2, MemoryMB: 1024}),
+		newContainer("logger", poolApi.ComputeResource{CPU: 1, MemoryMB: 512}),
+	}
+	require.Equal(t, int64(3), FromPodToComputeResource(pod).CPU)
+
+	// Init container bigger than all containers in one dimension only
+	pod.Spec.InitContainers = []k8sCore.Container{
+		newContainer("init", poolApi.ComputeResource{CPU: 1, MemoryMB: 4096}),
+	}
+	resources := FromPodToComputeResource(pod)
+	require.EqualValues(t, 3, resources.CPU)
+	require.EqualValues(t, 4096, resources.MemoryMB)
+
+	// Overhead
+	pod.Spec.Overhead = k8sCore.ResourceList{k8sCore.ResourceCPU: resource.MustParse("1")}
+	require.EqualValues(t, 4, FromPodToComputeResource(pod).CPU)
+	require.EqualValues(t, 3, FromPodToComputeResourceWithOptions(pod, ResourceOptions{ExcludeOverhead: true}).CPU)
+}
+
+func TestEffectivePodResourcesWithSidecars(t *testing.T) {
+	pod := EmptyPod()
+	pod.Spec.Containers = []k8sCore.Container{
+		newContainer("main", poolApi.ComputeResource{CPU: 2, MemoryMB: 1024}),
+	}
+	pod.Spec.InitContainers = []k8sCore.Container{
+		newContainer("proxy", poolApi.ComputeResource{CPU: 1, MemoryMB: 256}),
+		newContainer("setup", poolApi.ComputeResource{CPU: 1, MemoryMB: 2048}),
+	}
+	options := ResourceOptions{
+		IsRestartableInitContainer: func(_ *k8sCore.Pod, container *k8sCore.Container) bool {
+			return container.Name == "proxy"
+		},
+	}
+
+	// Sidecar runs next to the main container, and the setup container
+	resources := FromPodToComputeResourceWithOptions(pod, options)
+	require.EqualValues(t, 3, resources.CPU)
+	require.EqualValues(t, 2048+256, resources.MemoryMB)
+
+	// Without sidecar identification, init containers do not add up
+	resources = FromPodToComputeResource(pod)
+	require.EqualValues(t, 2, resources.CPU)
+	require.EqualValues(t, 2048, resources.MemoryMB)
+}
+
+func TestEffectivePodResourcesWithLimits(t *testing.T) {
+	pod := EmptyPod()
+	container := newContainer("main", poolApi.ComputeResource{CPU: 2, MemoryMB: 1024})
+	container.Resources.Limits = k8sCore.ResourceList{k8sCore.ResourceCPU: resource.MustParse("4")}
+	pod.Spec.Containers = []k8sCore.Container{container}
+
+	require.EqualValues(t, 2, FromPodToComputeResource(pod).CPU)
+	resources := FromPodToComputeResourceWithOptions(pod, ResourceOptions{UseLimits: true})
+	require.EqualValues(t, 4, resources.CPU)
+	require.EqualValues(t, 1024, resources.MemoryMB)
+
+	exact := FromPodToExactComputeResourceWithOptions(pod, ResourceOptions{UseLimits: true})
+	require.EqualValues(t, 4000, exact.MilliCPU)
+}
+
+func TestExtendedPodResources(t *testing.T) {
+	registry, err := poolUtil.NewResourceRegistry(poolUtil.ResourceDefinition{Name: "vpc.amazonaws.com/pod-eni"})
+	require.NoError(t, err)
+
+	pod := EmptyPod()
+	main := newContainer("main", poolApi.ComputeResource{CPU: 1})
+	main.Resources.Requests["vpc.amazonaws.com/pod-eni"] = resource.MustParse("1")
+	main.Resources.Requests["example.com/unknown"] = resource.MustParse("5")
+	setup := newContainer("setup", poolApi.ComputeResource{CPU: 1})
+	setup.Resources.Requests["vpc.amazonaws.com/pod-eni"] = resource.MustParse("2")
+	pod.Spec.Containers = []k8sCore.Container{main}
+	pod.Spec.InitContainers = []k8sCore.Container{setup}
+
+	require.Equal(t, poolUtil.ExtendedResources{"vpc.amazonaws.com/pod-eni": 2}, FromPodToExtendedResources(pod, registry))
+	require.Empty(t, FromPodToExtendedResources(pod, nil))
+}
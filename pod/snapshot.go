@@ -5,6 +5,7 @@ import (
 
 	v1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
 	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
 	k8sCore "k8s.io/api/core/v1"
 )
 
@@ -13,6 +14,10 @@ type Metadata struct {
 	AssignedResourcePools []string
 	UsedResourcePool      string
 	PodResources          v1.ComputeResource
+	// Pod resources in exact units. PodResources is derived from it if Options.ExactResources is set.
+	ExactPodResources poolUtil.ExactComputeResource
+	// Registered extended resources requested by the pod (see Options.ResourceRegistry).
+	ExtendedResources poolUtil.ExtendedResources
 }
 
 // Node data snapshot with useful indexes for fast access. Snapshot struct can be mutated by calling the provided
@@ -33,6 +38,13 @@ type Snapshot struct {
 type Options struct {
 	SupportGPUs        bool
 	PastYoungThreshold func(pod *k8sCore.Pod, now time.Time) bool
+	// If set, pod resources are computed from exact container requests and rounded up once, instead of rounding up
+	// CPU of each container to a whole CPU.
+	ExactResources bool
+	// Controls how pod resources are computed (requests vs limits, sidecar containers).
+	Resources ResourceOptions
+	// Extended resources to track in the pod metadata. If not set, only the built-in resources are tracked.
+	ResourceRegistry *poolUtil.ResourceRegistry
 }
 
 func NewEmpty() *Snapshot {
@@ -147,7 +159,13 @@ func buildPodMetadata(pod *k8sCore.Pod, resourcePool string, options Options) (*
 		return nil, false
 	}
 
-	podResources := FromPodToComputeResource(pod)
+	exactPodResources := FromPodToExactComputeResourceWithOptions(pod, options.Resources)
+	var podResources v1.ComputeResource
+	if options.ExactResources {
+		podResources = exactPodResources.ToComputeResourceRoundUp()
+	} else {
+		podResources = FromPodToComputeResourceWithOptions(pod, options.Resources)
+	}
 
 	// Do not look at pods requesting GPU resources, but running in non-GPU resource pool.
 	if !options.SupportGPUs && podResources.GPU > 0 {
@@ -158,6 +176,8 @@ func buildPodMetadata(pod *k8sCore.Pod, resourcePool string, options Options) (*
 		PrimaryResourcePool:   podResourcePools[0],
 		AssignedResourcePools: podResourcePools,
 		PodResources:          podResources,
+		ExactPodResources:     exactPodResources,
+		ExtendedResources:     FromPodToExtendedResourcesWithOptions(pod, options.ResourceRegistry, options.Resources),
 	}
 
 	for _, pool := range podResourcePools {
@@ -0,0 +1,62 @@
+package reserved
+
+import (
+	"sort"
+
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
+)
+
+func FormatCapacityReservationUsage(usage *CapacityReservationUsage, options poolUtil.FormatterOptions) string {
+	if options.IsTableOutput() {
+		return formatCapacityReservationUsageTable(usage, options)
+	}
+	if options.Level == poolUtil.FormatCompact {
+		return formatCapacityReservationUsageCompact(usage)
+	}
+	return poolUtil.ToJSONString(usage)
+}
+
+func formatCapacityReservationUsageCompact(usage *CapacityReservationUsage) string {
+	type Compact struct {
+		CapacityGroupCount int64
+		AllReserved        Usage
+		Buffer             Usage
+		Elastic            Usage
+	}
+	value := Compact{
+		CapacityGroupCount: int64(len(usage.InCapacityGroup)),
+		AllReserved:        usage.AllReserved,
+		Buffer:             usage.Buffer,
+		Elastic:            usage.Elastic,
+	}
+	return poolUtil.ToJSONString(value)
+}
+
+// Renders one row per capacity group and usage kind (allocated, unallocated, overAllocation), followed by
+// the buffer, elastic and all reserved aggregates.
+func formatCapacityReservationUsageTable(usage *CapacityReservationUsage, options poolUtil.FormatterOptions) string {
+	table := poolUtil.NewTable(append([]string{"CAPACITY_GROUP", "USAGE"}, poolUtil.ComputeResourceColumns...)...)
+	addRow := func(name string, kind string, resources poolV1.ComputeResource) {
+		table.AddCells(append(poolUtil.Cells(name, kind), poolUtil.ComputeResourceCells(resources)...)...)
+	}
+	addUsage := func(name string, value Usage) {
+		addRow(name, "allocated", value.Allocated)
+		addRow(name, "unallocated", value.Unallocated)
+		addRow(name, "overAllocation", value.OverAllocation)
+	}
+
+	names := make([]string, 0, len(usage.InCapacityGroup))
+	for name := range usage.InCapacityGroup {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		addUsage(name, usage.InCapacityGroup[name])
+	}
+	addUsage("<buffer>", usage.Buffer)
+	addUsage("<elastic>", usage.Elastic)
+	addRow("<trough>", "allocated", usage.TroughUsedReservedUnallocated)
+	addUsage("<allReserved>", usage.AllReserved)
+	return table.Render(options)
+}
@@ -0,0 +1,25 @@
+package reserved
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
+)
+
+func TestFormatCapacityReservationUsageTableSortedByCPU(t *testing.T) {
+	usage := &CapacityReservationUsage{
+		InCapacityGroup: map[string]Usage{
+			"groupA": {Allocated: poolV1.ComputeResource{CPU: 128}},
+			"groupB": {Allocated: poolV1.ComputeResource{CPU: 96}},
+		},
+	}
+	text := FormatCapacityReservationUsage(usage, poolUtil.FormatterOptions{
+		Output: poolUtil.OutputTable,
+		SortBy: poolUtil.ColumnCPU,
+	})
+	require.Less(t, strings.Index(text, "96 cores"), strings.Index(text, "128 cores"))
+}
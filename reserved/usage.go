@@ -80,7 +80,7 @@ func NewActiveCapacityReservationUsage(snapshot *resourcepool.ResourceSnapshot,
 				allReserved.Unallocated = allReserved.Unallocated.Add(usage.Unallocated)
 				allReserved.OverAllocation = allReserved.OverAllocation.Add(usage.OverAllocation)
 
-				bufferAllocated, bufferOverallocation, elasticAllocated := buildBufferAndElasticUsage(remainingBuffer, overallocatedPods)
+				bufferAllocated, bufferOverallocation, elasticAllocated := buildBufferAndElasticUsage(snapshot, remainingBuffer, overallocatedPods)
 				bufferAllocatedByCapacityGroup[reservationName] = bufferAllocated
 				elasticAllocatedByCapacityGroup[reservationName] = elasticAllocated
 				remainingBuffer = remainingBuffer.Sub(bufferAllocated)
@@ -164,7 +164,7 @@ func NewCapacityReservationUsage(snapshot *resourcepool.ResourceSnapshot,
 				allReserved.Unallocated = allReserved.Unallocated.Add(usage.Unallocated)
 				allReserved.OverAllocation = allReserved.OverAllocation.Add(usage.OverAllocation)
 
-				bufferAllocated, bufferOverallocation, elasticAllocated := buildBufferAndElasticUsage(remainingBuffer, overallocatedPods)
+				bufferAllocated, bufferOverallocation, elasticAllocated := buildBufferAndElasticUsage(snapshot, remainingBuffer, overallocatedPods)
 				bufferAllocatedByCapacityGroup[reservationName] = bufferAllocated
 				elasticAllocatedByCapacityGroup[reservationName] = elasticAllocated
 				remainingBuffer = remainingBuffer.Sub(bufferAllocated)
@@ -222,7 +222,7 @@ func buildActiveUsage(snapshot *resourcepool.ResourceSnapshot, reservation *capa
 				!node.IsNodeAvailableForScheduling(n, time.Now(), 0) {
 				continue
 			}
-			podResources := poolPod.FromPodToComputeResource(pod)
+			podResources := snapshot.PodResources(pod)
 			nextAllocated := allocated.Add(podResources)
 			if nextAllocated != reservedResources && !nextAllocated.LessThan(reservedResources) {
 				overAllocated = overAllocated.Add(podResources)
@@ -247,7 +247,7 @@ func buildUsage(snapshot *resourcepool.ResourceSnapshot, reservation *capacityGr
 	overAllocationPods := []*v1.Pod{}
 	for _, pod := range snapshot.PodSnapshot.ScheduledByName {
 		if !poolPod.IsPodPreemptible(pod) && poolPod.IsPodInCapacityGroup(pod, reservation) {
-			podResources := poolPod.FromPodToComputeResource(pod)
+			podResources := snapshot.PodResources(pod)
 			nextAllocated := allocated.Add(podResources)
 			if nextAllocated != reservedResources && !nextAllocated.LessThan(reservedResources) {
 				overAllocated = overAllocated.Add(podResources)
@@ -265,13 +265,13 @@ func buildUsage(snapshot *resourcepool.ResourceSnapshot, reservation *capacityGr
 	}, overAllocationPods
 }
 
-func buildBufferAndElasticUsage(remainingBuffer poolV1.ComputeResource,
+func buildBufferAndElasticUsage(snapshot *resourcepool.ResourceSnapshot, remainingBuffer poolV1.ComputeResource,
 	bufferPods []*v1.Pod) (poolV1.ComputeResource, poolV1.ComputeResource, poolV1.ComputeResource) {
 	bufferAllocated := poolV1.ComputeResource{}
 	bufferOverallocation := poolV1.ComputeResource{}
 	elasticAllocated := poolV1.ComputeResource{}
 	for _, pod := range bufferPods {
-		podResources := poolPod.FromPodToComputeResource(pod)
+		podResources := snapshot.PodResources(pod)
 		nextBufferAllocated := bufferAllocated.Add(podResources)
 		if nextBufferAllocated != remainingBuffer && !nextBufferAllocated.LessThan(remainingBuffer) {
 			bufferOverallocation = bufferOverallocation.Add(podResources)
@@ -287,7 +287,7 @@ func buildTroughUsage(snapshot *resourcepool.ResourceSnapshot) poolV1.ComputeRes
 	sum := poolV1.Zero
 	for _, pod := range snapshot.PodSnapshot.ScheduledByName {
 		if poolPod.IsPodPreemptible(pod) {
-			sum = sum.Add(poolPod.FromPodToComputeResource(pod))
+			sum = sum.Add(snapshot.PodResources(pod))
 		}
 	}
 	return sum
@@ -299,7 +299,7 @@ func buildTroughUsageOnActiveNodes(snapshot *resourcepool.ResourceSnapshot) pool
 		if poolPod.IsPodPreemptible(pod) {
 			if n := snapshot.NodeSnapshot.AllByName[pod.Spec.NodeName]; n != nil &&
 				node.IsNodeAvailableForScheduling(n, time.Now(), 0) {
-				sum = sum.Add(poolPod.FromPodToComputeResource(pod))
+				sum = sum.Add(snapshot.PodResources(pod))
 			}
 		}
 	}
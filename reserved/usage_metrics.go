@@ -7,14 +7,39 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/component-base/metrics"
 	"k8s.io/component-base/metrics/legacyregistry"
+
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
 )
 
 const (
 	reserved = "reserved"
 	buffer   = "buffer"
 	elastic  = "elastic"
+
+	metricCapacityGroupUsageUnrestricted         = "capacityGroupUsageUnrestricted"
+	metricCapacityGroupUsageWithBufferAndElastic = "capacityGroupUsageWithBufferAndElastic"
+	metricTotalReservedAndElasticUsage           = "totalReservedAndElasticUsage"
+
+	helpCapacityGroupUsageUnrestricted         = "Capacity group resource usage with accounting the above reservation usage in the capacity group (%)"
+	helpCapacityGroupUsageWithBufferAndElastic = "Capacity group resource usage with excessive capacity group usage attributed to the buffer or elastic (%)"
+	helpTotalReservedAndElasticUsage           = "Total usage of resources split by reserved and elastic capacity (%)"
 )
 
+var (
+	labelsCapacityGroupUsageUnrestricted         = []string{"leader", "resourcePool", "capacityGroup", "used"}
+	labelsCapacityGroupUsageWithBufferAndElastic = []string{"leader", "resourcePool", "capacityGroup", "resourceType"}
+	labelsTotalReservedAndElasticUsage           = []string{"leader", "resourcePool", "resourceType", "buffer", "used"}
+)
+
+// UsageMetricsPublisher is a metrics backend to which UsageMetrics writes computed reservation usage values. The
+// resource pool and leader dimensions are bound by the publisher itself, so only the per-value labels are passed in.
+type UsageMetricsPublisher interface {
+	SetCapacityGroupUsageUnrestricted(capacityGroup string, used bool, value float64)
+	SetCapacityGroupUsageWithBufferAndElastic(capacityGroup string, resourceType string, value float64)
+	SetTotalReservedAndElasticUsage(resourceType string, buffer bool, used bool, value float64)
+	Reset()
+}
+
 type usageMetricsInternal struct {
 	capacityGroupUsageUnrestricted         *metrics.GaugeVec
 	capacityGroupUsageWithBufferAndElastic *metrics.GaugeVec
@@ -22,12 +47,17 @@ type usageMetricsInternal struct {
 }
 
 type UsageMetrics struct {
-	resourcePoolName                       string
-	bufferName                             string
+	resourcePoolName              string
+	bufferName                    string
+	publisher                     UsageMetricsPublisher
+	recentlyUpdatedCapacityGroups map[string]string
+}
+
+// PrometheusUsageMetricsPublisher publishes usage metrics as Prometheus gauges.
+type PrometheusUsageMetricsPublisher struct {
 	capacityGroupUsageUnrestricted         *prometheus.GaugeVec
 	capacityGroupUsageWithBufferAndElastic *prometheus.GaugeVec
 	totalReservedAndElasticUsage           *prometheus.GaugeVec
-	recentlyUpdatedCapacityGroups          map[string]string
 }
 
 // Duplicate metrics registrations are not allowed by prometheus, so we have to track it globally.
@@ -36,22 +66,78 @@ var (
 	usageMetricsRegistry     = map[string]*usageMetricsInternal{}
 )
 
+// NewUsageMetrics creates usage metrics published to the k8s.io/component-base legacy registry.
 func NewUsageMetrics(metricsSubsystem string, resourcePoolName string, bufferName string, leader bool) *UsageMetrics {
 	internalMetrics := getOrCreateInternalMetrics(metricsSubsystem)
-	sharedLabels := prometheus.Labels{
-		"leader":       strconv.FormatBool(leader),
-		"resourcePool": resourcePoolName,
-	}
+	sharedLabels := newSharedLabels(resourcePoolName, leader)
 
-	m := &UsageMetrics{
-		resourcePoolName:                       resourcePoolName,
-		bufferName:                             bufferName,
+	publisher := &PrometheusUsageMetricsPublisher{
 		capacityGroupUsageUnrestricted:         internalMetrics.capacityGroupUsageUnrestricted.MustCurryWith(sharedLabels),
 		capacityGroupUsageWithBufferAndElastic: internalMetrics.capacityGroupUsageWithBufferAndElastic.MustCurryWith(sharedLabels),
 		totalReservedAndElasticUsage:           internalMetrics.totalReservedAndElasticUsage.MustCurryWith(sharedLabels),
-		recentlyUpdatedCapacityGroups:          map[string]string{},
 	}
-	return m
+	return NewUsageMetricsWithPublisher(resourcePoolName, bufferName, publisher)
+}
+
+// NewUsageMetricsWithPublisher creates usage metrics written to the given publisher.
+func NewUsageMetricsWithPublisher(resourcePoolName string, bufferName string, publisher UsageMetricsPublisher) *UsageMetrics {
+	return &UsageMetrics{
+		resourcePoolName:              resourcePoolName,
+		bufferName:                    bufferName,
+		publisher:                     publisher,
+		recentlyUpdatedCapacityGroups: map[string]string{},
+	}
+}
+
+// NewPrometheusUsageMetricsPublisher creates a publisher with gauges registered in the caller supplied registerer.
+// Many resource pools may share the same registerer and subsystem, in which case the already registered gauges
+// are reused.
+func NewPrometheusUsageMetricsPublisher(registerer prometheus.Registerer, metricsSubsystem string,
+	resourcePoolName string, leader bool) (*PrometheusUsageMetricsPublisher, error) {
+	capacityGroupUsageUnrestricted, err := poolUtil.RegisterCollector(registerer, prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: metricsSubsystem,
+			Name:      metricCapacityGroupUsageUnrestricted,
+			Help:      helpCapacityGroupUsageUnrestricted,
+		}, labelsCapacityGroupUsageUnrestricted,
+	))
+	if err != nil {
+		return nil, err
+	}
+	capacityGroupUsageWithBufferAndElastic, err := poolUtil.RegisterCollector(registerer, prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: metricsSubsystem,
+			Name:      metricCapacityGroupUsageWithBufferAndElastic,
+			Help:      helpCapacityGroupUsageWithBufferAndElastic,
+		}, labelsCapacityGroupUsageWithBufferAndElastic,
+	))
+	if err != nil {
+		return nil, err
+	}
+	totalReservedAndElasticUsage, err := poolUtil.RegisterCollector(registerer, prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: metricsSubsystem,
+			Name:      metricTotalReservedAndElasticUsage,
+			Help:      helpTotalReservedAndElasticUsage,
+		}, labelsTotalReservedAndElasticUsage,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	sharedLabels := newSharedLabels(resourcePoolName, leader)
+	return &PrometheusUsageMetricsPublisher{
+		capacityGroupUsageUnrestricted:         capacityGroupUsageUnrestricted.(*prometheus.GaugeVec).MustCurryWith(sharedLabels),
+		capacityGroupUsageWithBufferAndElastic: capacityGroupUsageWithBufferAndElastic.(*prometheus.GaugeVec).MustCurryWith(sharedLabels),
+		totalReservedAndElasticUsage:           totalReservedAndElasticUsage.(*prometheus.GaugeVec).MustCurryWith(sharedLabels),
+	}, nil
+}
+
+func newSharedLabels(resourcePoolName string, leader bool) prometheus.Labels {
+	return prometheus.Labels{
+		"leader":       strconv.FormatBool(leader),
+		"resourcePool": resourcePoolName,
+	}
 }
 
 func getOrCreateInternalMetrics(metricsSubsystem string) *usageMetricsInternal {
@@ -65,26 +151,26 @@ func getOrCreateInternalMetrics(metricsSubsystem string) *usageMetricsInternal {
 	capacityGroupUsageUnrestricted := metrics.NewGaugeVec(
 		&metrics.GaugeOpts{
 			Subsystem:      metricsSubsystem,
-			Name:           "capacityGroupUsageUnrestricted",
-			Help:           "Capacity group resource usage with accounting the above reservation usage in the capacity group (%)",
+			Name:           metricCapacityGroupUsageUnrestricted,
+			Help:           helpCapacityGroupUsageUnrestricted,
 			StabilityLevel: metrics.ALPHA,
-		}, []string{"leader", "resourcePool", "capacityGroup", "used"},
+		}, labelsCapacityGroupUsageUnrestricted,
 	)
 	capacityGroupUsageWithBufferAndElastic := metrics.NewGaugeVec(
 		&metrics.GaugeOpts{
 			Subsystem:      metricsSubsystem,
-			Name:           "capacityGroupUsageWithBufferAndElastic",
-			Help:           "Capacity group resource usage with excessive capacity group usage attributed to the buffer or elastic (%)",
+			Name:           metricCapacityGroupUsageWithBufferAndElastic,
+			Help:           helpCapacityGroupUsageWithBufferAndElastic,
 			StabilityLevel: metrics.ALPHA,
-		}, []string{"leader", "resourcePool", "capacityGroup", "resourceType"},
+		}, labelsCapacityGroupUsageWithBufferAndElastic,
 	)
 	totalReservedAndElasticUsage := metrics.NewGaugeVec(
 		&metrics.GaugeOpts{
 			Subsystem:      metricsSubsystem,
-			Name:           "totalReservedAndElasticUsage",
-			Help:           "Total usage of resources split by reserved and elastic capacity (%)",
+			Name:           metricTotalReservedAndElasticUsage,
+			Help:           helpTotalReservedAndElasticUsage,
 			StabilityLevel: metrics.ALPHA,
-		}, []string{"leader", "resourcePool", "resourceType", "buffer", "used"},
+		}, labelsTotalReservedAndElasticUsage,
 	)
 
 	legacyregistry.MustRegister(
@@ -102,10 +188,28 @@ func getOrCreateInternalMetrics(metricsSubsystem string) *usageMetricsInternal {
 	return resourcePoolMetrics
 }
 
+func (p *PrometheusUsageMetricsPublisher) SetCapacityGroupUsageUnrestricted(capacityGroup string, used bool, value float64) {
+	p.capacityGroupUsageUnrestricted.WithLabelValues(capacityGroup, strconv.FormatBool(used)).Set(value)
+}
+
+func (p *PrometheusUsageMetricsPublisher) SetCapacityGroupUsageWithBufferAndElastic(capacityGroup string,
+	resourceType string, value float64) {
+	p.capacityGroupUsageWithBufferAndElastic.WithLabelValues(capacityGroup, resourceType).Set(value)
+}
+
+func (p *PrometheusUsageMetricsPublisher) SetTotalReservedAndElasticUsage(resourceType string, buffer bool, used bool,
+	value float64) {
+	p.totalReservedAndElasticUsage.WithLabelValues(resourceType, strconv.FormatBool(buffer), strconv.FormatBool(used)).Set(value)
+}
+
+func (p *PrometheusUsageMetricsPublisher) Reset() {
+	p.capacityGroupUsageUnrestricted.Reset()
+	p.capacityGroupUsageWithBufferAndElastic.Reset()
+	p.totalReservedAndElasticUsage.Reset()
+}
+
 func (m *UsageMetrics) Reset() {
-	m.capacityGroupUsageUnrestricted.Reset()
-	m.capacityGroupUsageWithBufferAndElastic.Reset()
-	m.totalReservedAndElasticUsage.Reset()
+	m.publisher.Reset()
 }
 
 func (m *UsageMetrics) Update(usage *CapacityReservationUsage) {
@@ -120,21 +224,21 @@ func (m *UsageMetrics) Update(usage *CapacityReservationUsage) {
 
 		// Here capacity group utilization can go above 100%
 		unrestrictedAllocatedPercentage := capacityGroupUsage.Allocated.Add(capacityGroupUsage.OverAllocation).MaxRatio(total) * 100
-		m.capacityGroupUsageUnrestricted.WithLabelValues(capacityGroupName, "true").Set(unrestrictedAllocatedPercentage)
-		m.capacityGroupUsageUnrestricted.WithLabelValues(capacityGroupName, "false").Set(unallocatedPercentage)
+		m.publisher.SetCapacityGroupUsageUnrestricted(capacityGroupName, true, unrestrictedAllocatedPercentage)
+		m.publisher.SetCapacityGroupUsageUnrestricted(capacityGroupName, false, unallocatedPercentage)
 
 		// Here excessive capacity group utilization > 100% is attribute to the buffer first, and elastic capacity second
 		allocatedPercentage := capacityGroupUsage.Allocated.MaxRatio(total) * 100
-		m.capacityGroupUsageWithBufferAndElastic.WithLabelValues(capacityGroupName, reserved).Set(allocatedPercentage)
+		m.publisher.SetCapacityGroupUsageWithBufferAndElastic(capacityGroupName, reserved, allocatedPercentage)
 
 		if bufferUsage, ok := usage.BufferAllocatedByCapacityGroup[capacityGroupName]; ok {
 			allocatedBufferPercentage := 0.0
 			if totalBuffer.IsAnyAboveZero() {
 				allocatedBufferPercentage = bufferUsage.MaxRatio(totalBuffer) * 100
 			}
-			m.capacityGroupUsageWithBufferAndElastic.WithLabelValues(capacityGroupName, buffer).Set(allocatedBufferPercentage)
+			m.publisher.SetCapacityGroupUsageWithBufferAndElastic(capacityGroupName, buffer, allocatedBufferPercentage)
 		} else {
-			m.capacityGroupUsageWithBufferAndElastic.WithLabelValues(capacityGroupName, buffer).Set(0)
+			m.publisher.SetCapacityGroupUsageWithBufferAndElastic(capacityGroupName, buffer, 0)
 		}
 
 		if elasticUsage, ok := usage.ElasticAllocatedByCapacityGroup[capacityGroupName]; ok {
@@ -142,9 +246,9 @@ func (m *UsageMetrics) Update(usage *CapacityReservationUsage) {
 			if totalElastic.IsAnyAboveZero() {
 				allocatedElasticPercentage = elasticUsage.MaxRatio(totalElastic) * 100
 			}
-			m.capacityGroupUsageWithBufferAndElastic.WithLabelValues(capacityGroupName, elastic).Set(allocatedElasticPercentage)
+			m.publisher.SetCapacityGroupUsageWithBufferAndElastic(capacityGroupName, elastic, allocatedElasticPercentage)
 		} else {
-			m.capacityGroupUsageWithBufferAndElastic.WithLabelValues(capacityGroupName, elastic).Set(0)
+			m.publisher.SetCapacityGroupUsageWithBufferAndElastic(capacityGroupName, elastic, 0)
 		}
 
 		updatedCapacityGroups[capacityGroupName] = capacityGroupName
@@ -153,11 +257,11 @@ func (m *UsageMetrics) Update(usage *CapacityReservationUsage) {
 	// Reset values for removed capacity groups
 	for previousCapacityGroup := range m.recentlyUpdatedCapacityGroups {
 		if _, ok := updatedCapacityGroups[previousCapacityGroup]; !ok {
-			m.capacityGroupUsageUnrestricted.WithLabelValues(previousCapacityGroup, "true").Set(0)
-			m.capacityGroupUsageUnrestricted.WithLabelValues(previousCapacityGroup, "false").Set(0)
-			m.capacityGroupUsageWithBufferAndElastic.WithLabelValues(previousCapacityGroup, reserved).Set(0)
-			m.capacityGroupUsageWithBufferAndElastic.WithLabelValues(previousCapacityGroup, buffer).Set(0)
-			m.capacityGroupUsageWithBufferAndElastic.WithLabelValues(previousCapacityGroup, elastic).Set(0)
+			m.publisher.SetCapacityGroupUsageUnrestricted(previousCapacityGroup, true, 0)
+			m.publisher.SetCapacityGroupUsageUnrestricted(previousCapacityGroup, false, 0)
+			m.publisher.SetCapacityGroupUsageWithBufferAndElastic(previousCapacityGroup, reserved, 0)
+			m.publisher.SetCapacityGroupUsageWithBufferAndElastic(previousCapacityGroup, buffer, 0)
+			m.publisher.SetCapacityGroupUsageWithBufferAndElastic(previousCapacityGroup, elastic, 0)
 		}
 	}
 	m.recentlyUpdatedCapacityGroups = updatedCapacityGroups
@@ -165,12 +269,12 @@ func (m *UsageMetrics) Update(usage *CapacityReservationUsage) {
 	// Total reserved and elastic.
 	nonBufferAllocated := usage.AllReserved.Allocated.Sub(usage.Buffer.Allocated)
 	nonBufferUnallocated := usage.AllReserved.Unallocated.Sub(usage.Buffer.Unallocated)
-	m.totalReservedAndElasticUsage.WithLabelValues(reserved, "false", "true").Set(nonBufferAllocated.MaxRatio(totalReserved) * 100)
-	m.totalReservedAndElasticUsage.WithLabelValues(reserved, "false", "false").Set(nonBufferUnallocated.MaxRatio(totalReserved) * 100)
-	m.totalReservedAndElasticUsage.WithLabelValues(reserved, "true", "true").Set(usage.Buffer.Allocated.MaxRatio(totalReserved) * 100)
-	m.totalReservedAndElasticUsage.WithLabelValues(reserved, "true", "false").Set(usage.Buffer.Unallocated.MaxRatio(totalReserved) * 100)
+	m.publisher.SetTotalReservedAndElasticUsage(reserved, false, true, nonBufferAllocated.MaxRatio(totalReserved)*100)
+	m.publisher.SetTotalReservedAndElasticUsage(reserved, false, false, nonBufferUnallocated.MaxRatio(totalReserved)*100)
+	m.publisher.SetTotalReservedAndElasticUsage(reserved, true, true, usage.Buffer.Allocated.MaxRatio(totalReserved)*100)
+	m.publisher.SetTotalReservedAndElasticUsage(reserved, true, false, usage.Buffer.Unallocated.MaxRatio(totalReserved)*100)
 
 	elasticPercentage := usage.Elastic.Allocated.MaxRatio(totalElastic) * 100
-	m.totalReservedAndElasticUsage.WithLabelValues(elastic, "false", "true").Set(elasticPercentage)
-	m.totalReservedAndElasticUsage.WithLabelValues(elastic, "false", "false").Set(100 - elasticPercentage)
+	m.publisher.SetTotalReservedAndElasticUsage(elastic, false, true, elasticPercentage)
+	m.publisher.SetTotalReservedAndElasticUsage(elastic, false, false, 100-elasticPercentage)
 }
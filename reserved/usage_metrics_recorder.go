@@ -0,0 +1,85 @@
+package reserved
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// InMemoryUsageMetricsPublisher keeps the most recently published usage values in memory. It is primarily intended
+// for tests, where the published values can be checked without going through a metrics registry.
+type InMemoryUsageMetricsPublisher struct {
+	lock                                   sync.Mutex
+	capacityGroupUsageUnrestricted         map[string]float64
+	capacityGroupUsageWithBufferAndElastic map[string]float64
+	totalReservedAndElasticUsage           map[string]float64
+}
+
+func NewInMemoryUsageMetricsPublisher() *InMemoryUsageMetricsPublisher {
+	return &InMemoryUsageMetricsPublisher{
+		capacityGroupUsageUnrestricted:         map[string]float64{},
+		capacityGroupUsageWithBufferAndElastic: map[string]float64{},
+		totalReservedAndElasticUsage:           map[string]float64{},
+	}
+}
+
+func (p *InMemoryUsageMetricsPublisher) SetCapacityGroupUsageUnrestricted(capacityGroup string, used bool, value float64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.capacityGroupUsageUnrestricted[recorderKey(capacityGroup, strconv.FormatBool(used))] = value
+}
+
+func (p *InMemoryUsageMetricsPublisher) SetCapacityGroupUsageWithBufferAndElastic(capacityGroup string,
+	resourceType string, value float64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.capacityGroupUsageWithBufferAndElastic[recorderKey(capacityGroup, resourceType)] = value
+}
+
+func (p *InMemoryUsageMetricsPublisher) SetTotalReservedAndElasticUsage(resourceType string, buffer bool, used bool,
+	value float64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.totalReservedAndElasticUsage[recorderKey(resourceType, strconv.FormatBool(buffer), strconv.FormatBool(used))] = value
+}
+
+func (p *InMemoryUsageMetricsPublisher) Reset() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.capacityGroupUsageUnrestricted = map[string]float64{}
+	p.capacityGroupUsageWithBufferAndElastic = map[string]float64{}
+	p.totalReservedAndElasticUsage = map[string]float64{}
+}
+
+// CapacityGroupUsageUnrestricted returns the last value recorded for the capacity group, or false if nothing was
+// recorded.
+func (p *InMemoryUsageMetricsPublisher) CapacityGroupUsageUnrestricted(capacityGroup string, used bool) (float64, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	value, ok := p.capacityGroupUsageUnrestricted[recorderKey(capacityGroup, strconv.FormatBool(used))]
+	return value, ok
+}
+
+// CapacityGroupUsageWithBufferAndElastic returns the last value recorded for the capacity group and resource type
+// (reserved, buffer or elastic), or false if nothing was recorded.
+func (p *InMemoryUsageMetricsPublisher) CapacityGroupUsageWithBufferAndElastic(capacityGroup string,
+	resourceType string) (float64, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	value, ok := p.capacityGroupUsageWithBufferAndElastic[recorderKey(capacityGroup, resourceType)]
+	return value, ok
+}
+
+// TotalReservedAndElasticUsage returns the last value recorded for the resource type (reserved or elastic), or false
+// if nothing was recorded.
+func (p *InMemoryUsageMetricsPublisher) TotalReservedAndElasticUsage(resourceType string, buffer bool,
+	used bool) (float64, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	value, ok := p.totalReservedAndElasticUsage[recorderKey(resourceType, strconv.FormatBool(buffer), strconv.FormatBool(used))]
+	return value, ok
+}
+
+func recorderKey(labelValues ...string) string {
+	return strings.Join(labelValues, "/")
+}
@@ -0,0 +1,68 @@
+package reserved
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	capacityGroupV1 "github.com/Netflix/titus-controllers-api/api/capacitygroup/v1"
+	"github.com/Netflix/titus-resource-pool/resourcepool"
+)
+
+func TestInMemoryUsageMetricsPublisher(t *testing.T) {
+	publisher := NewInMemoryUsageMetricsPublisher()
+	metrics := NewUsageMetricsWithPublisher(resourcepool.PoolNameIntegration, integrationBuffer, publisher)
+
+	poolSnapshot, _ := newResourcePoolSnapshotWithOneNodeAndScheduledPods(1)
+	capacityGroup1, capacityGroup2, _, bufferGroup := newCapacityGroupsWithBuffer()
+	usage := NewCapacityReservationUsage(poolSnapshot,
+		[]*capacityGroupV1.CapacityGroup{capacityGroup1, capacityGroup2, bufferGroup}, integrationBuffer)
+	metrics.Update(usage)
+
+	// One pod of 8 compute units in a capacity group of 6 x 16 compute units.
+	used, ok := publisher.CapacityGroupUsageUnrestricted("group_1", true)
+	require.True(t, ok)
+	require.InDelta(t, 100.0/12, used, 0.001)
+	reservedUsed, ok := publisher.CapacityGroupUsageWithBufferAndElastic("group_1", reserved)
+	require.True(t, ok)
+	require.InDelta(t, 100.0/12, reservedUsed, 0.001)
+	bufferUsed, ok := publisher.CapacityGroupUsageWithBufferAndElastic("group_1", buffer)
+	require.True(t, ok)
+	require.Equal(t, 0.0, bufferUsed)
+	_, ok = publisher.TotalReservedAndElasticUsage(elastic, false, true)
+	require.True(t, ok)
+
+	// Removed capacity groups are reset to zero
+	metrics.Update(NewCapacityReservationUsage(poolSnapshot, []*capacityGroupV1.CapacityGroup{capacityGroup2, bufferGroup},
+		integrationBuffer))
+	used, ok = publisher.CapacityGroupUsageUnrestricted("group_1", true)
+	require.True(t, ok)
+	require.Equal(t, 0.0, used)
+
+	metrics.Reset()
+	_, ok = publisher.CapacityGroupUsageUnrestricted("group_2", false)
+	require.False(t, ok)
+}
+
+func TestPrometheusUsageMetricsPublisherWithCustomRegistry(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	publisherA, err := NewPrometheusUsageMetricsPublisher(registry, "test", "resource_pool_a", true)
+	require.NoError(t, err)
+	// Same subsystem in the same registry must reuse already registered collectors.
+	publisherB, err := NewPrometheusUsageMetricsPublisher(registry, "test", "resource_pool_b", true)
+	require.NoError(t, err)
+
+	publisherA.SetCapacityGroupUsageUnrestricted("group_1", true, 10)
+	publisherB.SetCapacityGroupUsageUnrestricted("group_1", true, 20)
+	publisherA.SetTotalReservedAndElasticUsage(elastic, false, true, 30)
+
+	require.Equal(t, 10.0, testutil.ToFloat64(publisherA.capacityGroupUsageUnrestricted.WithLabelValues("group_1", "true")))
+	require.Equal(t, 20.0, testutil.ToFloat64(publisherB.capacityGroupUsageUnrestricted.WithLabelValues("group_1", "true")))
+	require.Equal(t, 30.0, testutil.ToFloat64(publisherA.totalReservedAndElasticUsage.WithLabelValues(elastic, "false", "true")))
+
+	count, err := testutil.GatherAndCount(registry, "test_"+metricCapacityGroupUsageUnrestricted)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
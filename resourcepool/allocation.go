@@ -7,13 +7,42 @@ import (
 
 	poolNode "github.com/Netflix/titus-resource-pool/node"
 	poolPod "github.com/Netflix/titus-resource-pool/pod"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
 )
 
 func ComputeAllocatableCapacityFromSnapshot(snapshot *ResourceSnapshot,
 	minimumResources scaler.ComputeResource, adjust bool, excludePreemptiblePods bool) (
 	scaler.ComputeResource, scaler.ComputeResource, map[string]scaler.ComputeResource) {
+	return ComputeAllocatableCapacityFromSnapshotWithOptions(snapshot, minimumResources, AllocationOptions{
+		Adjust:                 adjust,
+		ExcludePreemptiblePods: excludePreemptiblePods,
+	})
+}
+
+// ComputeAllocatableCapacityFromSnapshotWithOptions is ComputeAllocatableCapacityWithOptions applied to active nodes
+// and scheduled pods of the snapshot. Pod resources are computed with the snapshot PodResourceOptions (unless
+// options.PodResources is set), and in exact units if the snapshot ExactResources is set, so by default the result is
+// consistent with the snapshot metadata.
+func ComputeAllocatableCapacityFromSnapshotWithOptions(snapshot *ResourceSnapshot,
+	minimumResources scaler.ComputeResource, options AllocationOptions) (
+	scaler.ComputeResource, scaler.ComputeResource, map[string]scaler.ComputeResource) {
 	scheduledPods := snapshot.PodSnapshot.ScheduledByName
-	return ComputeAllocatableCapacity(scheduledPods, snapshot.NodeSnapshot.ActiveByName, minimumResources, adjust, excludePreemptiblePods)
+	return ComputeAllocatableCapacityWithOptions(scheduledPods, snapshot.NodeSnapshot.ActiveByName, minimumResources,
+		snapshot.allocationOptions(options))
+}
+
+// Applies the snapshot pod resource settings to the allocation options. Pod resource options set by the caller are
+// kept.
+func (snapshot *ResourceSnapshot) allocationOptions(options AllocationOptions) AllocationOptions {
+	if isDefaultPodResourceOptions(options.PodResources) {
+		options.PodResources = snapshot.PodResourceOptions
+	}
+	options.ExactResources = options.ExactResources || snapshot.ExactResources
+	return options
+}
+
+func isDefaultPodResourceOptions(options poolPod.ResourceOptions) bool {
+	return !options.UseLimits && !options.ExcludeOverhead && options.IsRestartableInitContainer == nil
 }
 
 // ComputeAllocatableCapacity returns available capacity for every node in the given input.
@@ -26,6 +55,72 @@ func ComputeAllocatableCapacityFromSnapshot(snapshot *ResourceSnapshot,
 func ComputeAllocatableCapacity(scheduledPods map[string]*v1.Pod, nodes map[string]*v1.Node,
 	minimumResources scaler.ComputeResource, adjust bool, excludePreemptiblePods bool) (
 	scaler.ComputeResource, scaler.ComputeResource, map[string]scaler.ComputeResource) {
+	return ComputeAllocatableCapacityWithOptions(scheduledPods, nodes, minimumResources, AllocationOptions{
+		Adjust:                 adjust,
+		ExcludePreemptiblePods: excludePreemptiblePods,
+	})
+}
+
+type AllocationOptions struct {
+	// Align the remaining resources to the mostly utilized resource (DRF adjustment).
+	Adjust bool
+	// Consider capacity occupied by preemptible pods as available.
+	ExcludePreemptiblePods bool
+	// Compute node usage in exact units (millicores, bytes), and convert the remaining capacity to ComputeResource
+	// units at the end, truncating fractional parts. Otherwise, requests of each pod container are rounded up
+	// to whole CPUs and truncated to MB before they are summed up.
+	ExactResources bool
+	// Controls how resources of the scheduled pods are computed. The snapshot based functions use the snapshot
+	// PodResourceOptions if it is not set.
+	PodResources poolPod.ResourceOptions
+}
+
+// ComputeAllocatableCapacityWithOptions is ComputeAllocatableCapacity with all parameters provided in AllocationOptions.
+func ComputeAllocatableCapacityWithOptions(scheduledPods map[string]*v1.Pod, nodes map[string]*v1.Node,
+	minimumResources scaler.ComputeResource, options AllocationOptions) (
+	scaler.ComputeResource, scaler.ComputeResource, map[string]scaler.ComputeResource) {
+	nodeRemainingAdjusted, nodeRemainingCapacityDebug := computeNodeRemaining(scheduledPods, nodes, options)
+
+	// Sum what is remaining, but only look at nodes with large enough resource chunks left.
+	tot := scaler.ComputeResource{}
+	remainingActual := scaler.ComputeResource{}
+	for nodeID, nodeRemaining := range nodeRemainingCapacityDebug {
+		if nodeRemaining.GreaterThanOrEqual(minimumResources) {
+			tot = tot.Add(nodeRemainingAdjusted[nodeID])
+			remainingActual = remainingActual.Add(nodeRemaining)
+		}
+	}
+
+	return tot, remainingActual, nodeRemainingCapacityDebug
+}
+
+// Returns the remaining capacity of each node with the DRF adjustment applied if options.Adjust is set, and without it.
+func computeNodeRemaining(scheduledPods map[string]*v1.Pod, nodes map[string]*v1.Node, options AllocationOptions) (
+	map[string]scaler.ComputeResource, map[string]scaler.ComputeResource) {
+	var nodeToAvailable, nodeToUsed map[string]scaler.ComputeResource
+	if options.ExactResources {
+		nodeToAvailable, nodeToUsed = computeExactNodeUsage(scheduledPods, nodes, options)
+	} else {
+		nodeToAvailable, nodeToUsed = computeNodeUsage(scheduledPods, nodes, options)
+	}
+
+	// Align the remaining resources to the mostly utilized resource.
+	nodeRemainingAdjusted := map[string]scaler.ComputeResource{}
+	nodeRemainingActual := map[string]scaler.ComputeResource{}
+	for nodeID, nodeUsed := range nodeToUsed {
+		nodeAvailable := nodeToAvailable[nodeID]
+		adjustedUsed := nodeUsed
+		if options.Adjust {
+			adjustedUsed = nodeUsed.AlignResourceRatios(nodeAvailable)
+		}
+		nodeRemainingAdjusted[nodeID] = nodeAvailable.SubWithLimit(adjustedUsed, 0)
+		nodeRemainingActual[nodeID] = nodeAvailable.SubWithLimit(nodeUsed, 0)
+	}
+	return nodeRemainingAdjusted, nodeRemainingActual
+}
+
+func computeNodeUsage(scheduledPods map[string]*v1.Pod, nodes map[string]*v1.Node, options AllocationOptions) (
+	map[string]scaler.ComputeResource, map[string]scaler.ComputeResource) {
 	nodeToAvailable := make(map[string]scaler.ComputeResource)
 	nodeToUsed := make(map[string]scaler.ComputeResource)
 
@@ -37,34 +132,114 @@ func ComputeAllocatableCapacity(scheduledPods map[string]*v1.Pod, nodes map[stri
 
 	// Used capacity per node. We only look at pods running on the active nodes.
 	for _, pod := range scheduledPods {
-		if poolPod.IsPodPreemptible(pod) && excludePreemptiblePods {
+		if poolPod.IsPodPreemptible(pod) && options.ExcludePreemptiblePods {
 			continue
 		}
 		nodeName := pod.Spec.NodeName
 		if nodeUsed, exists := nodeToUsed[nodeName]; exists {
-			nodeToUsed[nodeName] = nodeUsed.Add(poolPod.FromPodToComputeResource(pod))
+			nodeToUsed[nodeName] = nodeUsed.Add(poolPod.FromPodToComputeResourceWithOptions(pod, options.PodResources))
 		}
 	}
+	return nodeToAvailable, nodeToUsed
+}
 
-	// Sum what is remaining, but only look at nodes with large enough resource chunks left.
-	// Align the remaining resources to the mostly utilized resource.
-	tot := scaler.ComputeResource{}
-	remainingActual := scaler.ComputeResource{}
-	nodeRemainingCapacityDebug := map[string]scaler.ComputeResource{}
-	for nodeID, nodeUsed := range nodeToUsed {
-		nodeAvailable := nodeToAvailable[nodeID]
-		nodeRemaining := nodeAvailable.SubWithLimit(nodeUsed, 0)
-		if nodeRemaining.GreaterThanOrEqual(minimumResources) {
-			adjustedUsed := nodeUsed
-			if adjust {
-				adjustedUsed = nodeUsed.AlignResourceRatios(nodeAvailable)
-			}
-			remainingAdjusted := nodeAvailable.SubWithLimit(adjustedUsed, 0)
-			tot = tot.Add(remainingAdjusted)
-			remainingActual = remainingActual.Add(nodeRemaining)
+// Same as computeNodeUsage, but the usage is summed up in exact units. The used capacity is computed as the
+// difference between the available and the remaining capacity, so the remaining capacity is never overstated.
+func computeExactNodeUsage(scheduledPods map[string]*v1.Pod, nodes map[string]*v1.Node, options AllocationOptions) (
+	map[string]scaler.ComputeResource, map[string]scaler.ComputeResource) {
+	exactAvailable := make(map[string]poolUtil.ExactComputeResource)
+	exactUsed := make(map[string]poolUtil.ExactComputeResource)
+	for _, node := range nodes {
+		exactAvailable[node.Name] = poolNode.FromNodeToExactComputeResource(node)
+		exactUsed[node.Name] = poolUtil.ExactComputeResource{}
+	}
+	for _, pod := range scheduledPods {
+		if poolPod.IsPodPreemptible(pod) && options.ExcludePreemptiblePods {
+			continue
+		}
+		nodeName := pod.Spec.NodeName
+		if nodeUsed, exists := exactUsed[nodeName]; exists {
+			exactUsed[nodeName] = nodeUsed.Add(poolPod.FromPodToExactComputeResourceWithOptions(pod, options.PodResources))
 		}
-		nodeRemainingCapacityDebug[nodeID] = nodeRemaining
 	}
 
-	return tot, remainingActual, nodeRemainingCapacityDebug
+	nodeToAvailable := make(map[string]scaler.ComputeResource)
+	nodeToUsed := make(map[string]scaler.ComputeResource)
+	for nodeName, nodeAvailable := range exactAvailable {
+		available := nodeAvailable.ToComputeResource()
+		remaining := nodeAvailable.SubWithLimit(exactUsed[nodeName], 0).ToComputeResource()
+		nodeToAvailable[nodeName] = available
+		nodeToUsed[nodeName] = available.SubWithLimit(remaining, 0)
+	}
+	return nodeToAvailable, nodeToUsed
+}
+
+// ComputeAllocatableCapacityForPodFromSnapshot is ComputeAllocatableCapacityForPod applied to active nodes and
+// scheduled pods of the snapshot.
+func ComputeAllocatableCapacityForPodFromSnapshot(snapshot *ResourceSnapshot, pod *v1.Pod,
+	placementPredicate poolPod.PlacementPredicate, minimumResources scaler.ComputeResource, adjust bool,
+	excludePreemptiblePods bool) (scaler.ComputeResource, scaler.ComputeResource, map[string]scaler.ComputeResource) {
+	scheduledPods := snapshot.PodSnapshot.ScheduledByName
+	return computeAllocatableCapacityForPod(pod, placementPredicate, scheduledPods, snapshot.NodeSnapshot.ActiveByName,
+		minimumResources, snapshot.allocationOptions(AllocationOptions{
+			Adjust:                 adjust,
+			ExcludePreemptiblePods: excludePreemptiblePods,
+		}))
+}
+
+// ComputeAllocatableCapacityForPod works like ComputeAllocatableCapacity, but only nodes on which the given pod can be
+// placed according to the placement predicate are included in the result. Pods running on excluded nodes
+// are ignored. If the predicate is nil, all nodes are included.
+func ComputeAllocatableCapacityForPod(pod *v1.Pod, placementPredicate poolPod.PlacementPredicate,
+	scheduledPods map[string]*v1.Pod, nodes map[string]*v1.Node, minimumResources scaler.ComputeResource, adjust bool,
+	excludePreemptiblePods bool) (scaler.ComputeResource, scaler.ComputeResource, map[string]scaler.ComputeResource) {
+	return computeAllocatableCapacityForPod(pod, placementPredicate, scheduledPods, nodes, minimumResources,
+		AllocationOptions{
+			Adjust:                 adjust,
+			ExcludePreemptiblePods: excludePreemptiblePods,
+		})
+}
+
+func computeAllocatableCapacityForPod(pod *v1.Pod, placementPredicate poolPod.PlacementPredicate,
+	scheduledPods map[string]*v1.Pod, nodes map[string]*v1.Node, minimumResources scaler.ComputeResource,
+	options AllocationOptions) (scaler.ComputeResource, scaler.ComputeResource, map[string]scaler.ComputeResource) {
+	if placementPredicate == nil {
+		return ComputeAllocatableCapacityWithOptions(scheduledPods, nodes, minimumResources, options)
+	}
+	placeable := map[string]*v1.Node{}
+	for name, node := range nodes {
+		if placementPredicate(pod, node) {
+			placeable[name] = node
+		}
+	}
+	return ComputeAllocatableCapacityWithOptions(scheduledPods, placeable, minimumResources, options)
+}
+
+// ComputeExtendedAllocatableCapacity returns the remaining amount of the registered extended resources on the given
+// nodes, in total and per node. Nodes are not filtered by a minimum size, and no DRF adjustment is applied.
+func ComputeExtendedAllocatableCapacity(scheduledPods map[string]*v1.Pod, nodes map[string]*v1.Node,
+	registry *poolUtil.ResourceRegistry, excludePreemptiblePods bool) (
+	poolUtil.ExtendedResources, map[string]poolUtil.ExtendedResources) {
+	nodeToUsed := map[string]poolUtil.ExtendedResources{}
+	for _, node := range nodes {
+		nodeToUsed[node.Name] = poolUtil.ExtendedResources{}
+	}
+	for _, pod := range scheduledPods {
+		if poolPod.IsPodPreemptible(pod) && excludePreemptiblePods {
+			continue
+		}
+		nodeName := pod.Spec.NodeName
+		if nodeUsed, exists := nodeToUsed[nodeName]; exists {
+			nodeToUsed[nodeName] = nodeUsed.Add(poolPod.FromPodToExtendedResources(pod, registry))
+		}
+	}
+
+	total := poolUtil.ExtendedResources{}
+	nodeRemaining := map[string]poolUtil.ExtendedResources{}
+	for _, node := range nodes {
+		remaining := poolNode.FromNodeToExtendedResources(node, registry).SubWithLimit(nodeToUsed[node.Name], 0)
+		nodeRemaining[node.Name] = remaining
+		total = total.Add(remaining)
+	}
+	return total, nodeRemaining
 }
@@ -2,15 +2,19 @@ package resourcepool
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	k8sCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
 	scaler "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
 	"github.com/Netflix/titus-resource-pool/machine"
 	poolNode "github.com/Netflix/titus-resource-pool/node"
 	poolPod "github.com/Netflix/titus-resource-pool/pod"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
 )
 
 func TestComputeAllocatableCapacity(t *testing.T) {
@@ -40,3 +44,108 @@ func TestComputeAllocatableCapacity(t *testing.T) {
 	require.Equal(t, available, remainingActual)
 	require.Equal(t, nodesActualRemaining, nodeRemainingCapDebug)
 }
+
+func TestComputeAllocatableCapacityForPod(t *testing.T) {
+	active := poolNode.NewNode("active", "myResourcePool", machine.R5Metal())
+	phasedOut := poolNode.ButNodeWithTaint(poolNode.NewNode("phasedOut", "myResourcePool", machine.R5Metal()),
+		&k8sCore.Taint{Key: "example.com/phasedOut", Effect: k8sCore.TaintEffectPreferNoSchedule})
+	nodes := map[string]*k8sCore.Node{active.Name: active, phasedOut.Name: phasedOut}
+	nodeAvailable := machine.R5Metal().Spec.ComputeResource
+	pod := poolPod.NewRandomNotScheduledPod()
+
+	remaining, _, _ := ComputeAllocatableCapacityForPod(pod, nil, map[string]*k8sCore.Pod{}, nodes, scaler.Zero,
+		false, false)
+	require.Equal(t, nodeAvailable.Multiply(2), remaining)
+
+	remaining, _, nodeRemaining := ComputeAllocatableCapacityForPod(pod,
+		poolPod.NewPlacementPredicate(poolPod.PlacementOptions{StrictPreferNoSchedule: true}),
+		map[string]*k8sCore.Pod{}, nodes, scaler.Zero, false, false)
+	require.Equal(t, nodeAvailable, remaining)
+	require.Len(t, nodeRemaining, 1)
+	require.Contains(t, nodeRemaining, active.Name)
+}
+
+func TestComputeAllocatableCapacityInExactUnits(t *testing.T) {
+	node := poolNode.NewNode("node1", "myResourcePool", machine.R5Metal())
+	nodeAvailable := machine.R5Metal().Spec.ComputeResource
+
+	// Four pods requesting half a CPU and 1.5MB of memory each
+	scheduledPods := map[string]*k8sCore.Pod{}
+	for i := 0; i < 4; i++ {
+		pod := poolPod.ButPodAssignedToNode(poolPod.NewRandomNotScheduledPod(), node)
+		pod.Spec.Containers[0].Resources.Requests = k8sCore.ResourceList{
+			k8sCore.ResourceCPU:    resource.MustParse("500m"),
+			k8sCore.ResourceMemory: resource.MustParse("1536Ki"),
+		}
+		scheduledPods[pod.Name] = pod
+	}
+
+	_, remaining, _ := ComputeAllocatableCapacity(scheduledPods, map[string]*k8sCore.Node{node.Name: node}, scaler.Zero,
+		false, false)
+	require.Equal(t, nodeAvailable.CPU-4, remaining.CPU)
+	require.Equal(t, nodeAvailable.MemoryMB-4, remaining.MemoryMB)
+
+	_, remaining, _ = ComputeAllocatableCapacityWithOptions(scheduledPods, map[string]*k8sCore.Node{node.Name: node},
+		scaler.Zero, AllocationOptions{ExactResources: true})
+	require.Equal(t, nodeAvailable.CPU-2, remaining.CPU)
+	require.Equal(t, nodeAvailable.MemoryMB-6, remaining.MemoryMB)
+	require.Equal(t, nodeAvailable.DiskMB, remaining.DiskMB)
+}
+
+func TestComputeExtendedAllocatableCapacity(t *testing.T) {
+	const eni = "vpc.amazonaws.com/pod-eni"
+	registry, err := poolUtil.NewResourceRegistry(poolUtil.ResourceDefinition{Name: eni})
+	require.NoError(t, err)
+
+	node1 := poolNode.NewNode("node1", "myResourcePool", machine.R5Metal())
+	node1.Status.Allocatable[eni] = resource.MustParse("10")
+	node2 := poolNode.NewNode("node2", "myResourcePool", machine.R5Metal())
+	pod := poolPod.ButPodAssignedToNode(poolPod.NewRandomNotScheduledPod(), node1)
+	pod.Spec.Containers[0].Resources.Requests[eni] = resource.MustParse("3")
+
+	total, perNode := ComputeExtendedAllocatableCapacity(map[string]*k8sCore.Pod{pod.Name: pod},
+		map[string]*k8sCore.Node{node1.Name: node1, node2.Name: node2}, registry, false)
+	require.Equal(t, poolUtil.ExtendedResources{eni: 7}, total)
+	require.Equal(t, poolUtil.ExtendedResources{eni: 7}, perNode[node1.Name])
+	require.Empty(t, perNode[node2.Name])
+}
+
+func TestComputeAllocatableCapacityWithPodResourceOptions(t *testing.T) {
+	node := poolNode.NewNode("node1", "myResourcePool", machine.R5Metal())
+	nodeAvailable := machine.R5Metal().Spec.ComputeResource
+	pod := poolPod.ButPodAssignedToNode(poolPod.NewRandomNotScheduledPod(), node)
+	pod.Spec.Containers[0].Resources.Requests = k8sCore.ResourceList{k8sCore.ResourceCPU: resource.MustParse("2")}
+	pod.Spec.Overhead = k8sCore.ResourceList{k8sCore.ResourceCPU: resource.MustParse("1")}
+	scheduledPods := map[string]*k8sCore.Pod{pod.Name: pod}
+	nodes := map[string]*k8sCore.Node{node.Name: node}
+
+	remaining, _, _ := ComputeAllocatableCapacityWithOptions(scheduledPods, nodes, scaler.Zero, AllocationOptions{})
+	require.Equal(t, nodeAvailable.CPU-3, remaining.CPU)
+
+	remaining, _, _ = ComputeAllocatableCapacityWithOptions(scheduledPods, nodes, scaler.Zero, AllocationOptions{
+		PodResources: poolPod.ResourceOptions{ExcludeOverhead: true},
+	})
+	require.Equal(t, nodeAvailable.CPU-2, remaining.CPU)
+}
+
+func TestComputeAllocatableCapacityFromSnapshotWithPodResourceOptions(t *testing.T) {
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	node := poolNode.NewNode("node1", testPool, machine.R5Metal())
+	nodeAvailable := machine.R5Metal().Spec.ComputeResource
+	pod := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, scaler.ComputeResource{}, time.Now()), node)
+	pod.Spec.Containers[0].Resources.Requests = k8sCore.ResourceList{k8sCore.ResourceCPU: resource.MustParse("2")}
+	pod.Spec.Containers[0].Resources.Limits = k8sCore.ResourceList{k8sCore.ResourceCPU: resource.MustParse("4")}
+	pod.Spec.Overhead = k8sCore.ResourceList{k8sCore.ResourceCPU: resource.MustParse("1")}
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{node}, []*k8sCore.Pod{pod}, 10*time.Minute, 0, true)
+	snapshot.PodResourceOptions = poolPod.ResourceOptions{ExcludeOverhead: true}
+
+	_, remaining, _ := ComputeAllocatableCapacityFromSnapshotWithOptions(snapshot, scaler.Zero, AllocationOptions{})
+	require.Equal(t, nodeAvailable.CPU-2, remaining.CPU)
+
+	// Options set by the caller take precedence over the snapshot ones.
+	_, remaining, _ = ComputeAllocatableCapacityFromSnapshotWithOptions(snapshot, scaler.Zero, AllocationOptions{
+		PodResources: poolPod.ResourceOptions{UseLimits: true},
+	})
+	require.Equal(t, nodeAvailable.CPU-5, remaining.CPU)
+}
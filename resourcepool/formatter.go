@@ -1,11 +1,21 @@
 package resourcepool
 
 import (
+	"sort"
+	"strconv"
+	"strings"
+
+	k8sCore "k8s.io/api/core/v1"
+
 	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
 	poolUtil "github.com/Netflix/titus-resource-pool/util"
 )
 
 func FormatResourcePool(resourcePool *poolV1.ResourcePoolConfig, options poolUtil.FormatterOptions) string {
+	if options.IsTableOutput() {
+		return FormatResourcePoolsTable([]*poolV1.ResourcePoolConfig{resourcePool}, options)
+	}
 	if options.Level == poolUtil.FormatCompact {
 		return formatResourcePoolCompact(resourcePool)
 	} else if options.Level == poolUtil.FormatEssentials {
@@ -16,6 +26,26 @@ func FormatResourcePool(resourcePool *poolV1.ResourcePoolConfig, options poolUti
 	return formatResourcePoolCompact(resourcePool)
 }
 
+// FormatResourcePoolsTable renders resource pools as a text table, sorted by name unless options.SortBy is set.
+func FormatResourcePoolsTable(resourcePools []*poolV1.ResourcePoolConfig, options poolUtil.FormatterOptions) string {
+	sorted := make([]*poolV1.ResourcePoolConfig, len(resourcePools))
+	copy(sorted, resourcePools)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	table := poolUtil.NewTable(append([]string{"NAME", "RESOURCE_COUNT", "AUTO_SCALING", "MACHINE_TYPES"},
+		poolUtil.ComputeResourceColumns...)...)
+	for _, pool := range sorted {
+		table.AddCells(append(poolUtil.Cells(
+			pool.Name,
+			strconv.FormatInt(pool.Spec.ResourceCount, 10),
+			strconv.FormatBool(pool.Spec.ScalingRules.AutoScalingEnabled),
+			strings.Join(GetResourcePoolMachineTypes(pool), ","),
+		), poolUtil.ComputeResourceCells(pool.Spec.ResourceShape.ComputeResource)...)...)
+	}
+	return table.Render(options)
+}
+
 func formatResourcePoolCompact(pool *poolV1.ResourcePoolConfig) string {
 	type Compact struct {
 		Name               string
@@ -45,3 +75,251 @@ func formatResourcePoolEssentials(pool *poolV1.ResourcePoolConfig) string {
 	}
 	return poolUtil.ToJSONString(value)
 }
+
+func FormatFragmentation(fragmentation *PoolFragmentation, options poolUtil.FormatterOptions) string {
+	if options.Level == poolUtil.FormatCompact {
+		return formatFragmentationCompact(fragmentation)
+	} else if options.Level == poolUtil.FormatEssentials {
+		return formatFragmentationEssentials(fragmentation)
+	} else if options.Level == poolUtil.FormatDetails {
+		return poolUtil.ToJSONString(fragmentation)
+	}
+	return formatFragmentationCompact(fragmentation)
+}
+
+func formatFragmentationCompact(fragmentation *PoolFragmentation) string {
+	type Compact struct {
+		Slots              int64
+		FragmentationIndex float64
+	}
+	value := Compact{
+		Slots:              fragmentation.Slots,
+		FragmentationIndex: fragmentation.FragmentationIndex,
+	}
+	return poolUtil.ToJSONString(value)
+}
+
+func formatFragmentationEssentials(fragmentation *PoolFragmentation) string {
+	type Essentials struct {
+		Slots              int64
+		FragmentationIndex float64
+		Remaining          poolV1.ComputeResource
+		Stranded           poolV1.ComputeResource
+	}
+	value := Essentials{
+		Slots:              fragmentation.Slots,
+		FragmentationIndex: fragmentation.FragmentationIndex,
+		Remaining:          fragmentation.Remaining,
+		Stranded:           fragmentation.Stranded,
+	}
+	return poolUtil.ToJSONString(value)
+}
+
+func FormatReconciliationReport(report *ReconciliationReport, options poolUtil.FormatterOptions) string {
+	if options.Level == poolUtil.FormatCompact {
+		return formatReconciliationReportCompact(report)
+	} else if options.Level == poolUtil.FormatEssentials {
+		return formatReconciliationReportEssentials(report)
+	} else if options.Level == poolUtil.FormatDetails {
+		return poolUtil.ToJSONString(report)
+	}
+	return formatReconciliationReportCompact(report)
+}
+
+func formatReconciliationReportCompact(report *ReconciliationReport) string {
+	type Compact struct {
+		NodeCount                 int64
+		DeviatingNodeCount        int64
+		DeviatingMachineTypeCount int64
+		MissingInstanceTypeCount  int64
+		UnknownInstanceTypeCount  int64
+	}
+	value := Compact{
+		NodeCount:                 int64(len(report.Nodes)),
+		DeviatingNodeCount:        int64(len(report.DeviatingNodes())),
+		DeviatingMachineTypeCount: int64(len(report.DeviatingMachineTypes())),
+		MissingInstanceTypeCount:  int64(len(report.MissingInstanceType)),
+		UnknownInstanceTypeCount:  int64(len(report.UnknownInstanceType)),
+	}
+	return poolUtil.ToJSONString(value)
+}
+
+func formatReconciliationReportEssentials(report *ReconciliationReport) string {
+	type DeviatingNode struct {
+		NodeName    string
+		MachineType string
+		Deviations  []string
+	}
+	type Essentials struct {
+		MachineTypes        []MachineTypeReconciliation
+		DeviatingNodes      []DeviatingNode
+		MissingInstanceType []string
+		UnknownInstanceType map[string]string
+	}
+	value := Essentials{
+		MachineTypes:        report.MachineTypes,
+		DeviatingNodes:      []DeviatingNode{},
+		MissingInstanceType: report.MissingInstanceType,
+		UnknownInstanceType: report.UnknownInstanceType,
+	}
+	for _, node := range report.DeviatingNodes() {
+		value.DeviatingNodes = append(value.DeviatingNodes, DeviatingNode{
+			NodeName:    node.NodeName,
+			MachineType: node.MachineType,
+			Deviations:  node.Deviations,
+		})
+	}
+	return poolUtil.ToJSONString(value)
+}
+
+func FormatShapeFitAnalysis(analysis *ShapeFitAnalysis, options poolUtil.FormatterOptions) string {
+	if options.Level == poolUtil.FormatCompact {
+		return formatShapeFitAnalysisCompact(analysis)
+	} else if options.Level == poolUtil.FormatEssentials {
+		return formatShapeFitAnalysisEssentials(analysis)
+	} else if options.Level == poolUtil.FormatDetails {
+		return poolUtil.ToJSONString(analysis)
+	}
+	return formatShapeFitAnalysisCompact(analysis)
+}
+
+func formatShapeFitAnalysisCompact(analysis *ShapeFitAnalysis) string {
+	type Compact struct {
+		MachineTypeCount int64
+		Recommended      string
+	}
+	value := Compact{
+		MachineTypeCount: int64(len(analysis.Machines)),
+		Recommended:      analysis.Recommended,
+	}
+	return poolUtil.ToJSONString(value)
+}
+
+func formatShapeFitAnalysisEssentials(analysis *ShapeFitAnalysis) string {
+	type Machine struct {
+		MachineType string
+		Slots       int64
+		Waste       float64
+		PodMixWaste float64 `json:",omitempty"`
+	}
+	type Essentials struct {
+		ResourceShape poolV1.ComputeResource
+		Machines      []Machine
+		Recommended   string
+	}
+	value := Essentials{
+		ResourceShape: analysis.ResourceShape,
+		Machines:      []Machine{},
+		Recommended:   analysis.Recommended,
+	}
+	for _, fit := range analysis.Machines {
+		value.Machines = append(value.Machines, Machine{
+			MachineType: fit.MachineType,
+			Slots:       fit.Slots,
+			Waste:       fit.Waste,
+			PodMixWaste: fit.PodMixWaste,
+		})
+	}
+	return poolUtil.ToJSONString(value)
+}
+
+func FormatNodesAndPodsGroups(groups *NodesAndPodsGroups, options poolUtil.FormatterOptions) string {
+	if options.Level == poolUtil.FormatCompact {
+		return formatNodesAndPodsGroupsCompact(groups)
+	} else if options.Level == poolUtil.FormatEssentials {
+		return formatNodesAndPodsGroupsEssentials(groups, false)
+	} else if options.Level == poolUtil.FormatDetails {
+		return formatNodesAndPodsGroupsEssentials(groups, true)
+	}
+	return formatNodesAndPodsGroupsCompact(groups)
+}
+
+func formatNodesAndPodsGroupsCompact(groups *NodesAndPodsGroups) string {
+	type Compact struct {
+		NodeCount               int64
+		QueuedPodCount          int64
+		OnOtherPoolNodePodCount int64
+		OnMissingNodePodCount   int64
+		UnknownPrimaryPoolCount int64
+		OtherPodCount           int64
+	}
+	value := Compact{
+		NodeCount:               int64(len(groups.NodesAndPods)),
+		QueuedPodCount:          int64(len(groups.QueuedPods)),
+		OnOtherPoolNodePodCount: int64(len(groups.OnOtherPoolNodePods)),
+		OnMissingNodePodCount:   int64(len(groups.OnMissingNodePods)),
+		UnknownPrimaryPoolCount: int64(len(groups.UnknownPrimaryPoolPods)),
+		OtherPodCount:           int64(len(groups.OtherPods)),
+	}
+	return poolUtil.ToJSONString(value)
+}
+
+func formatNodesAndPodsGroupsEssentials(groups *NodesAndPodsGroups, withNodePods bool) string {
+	type MisroutedPod struct {
+		PodName       string
+		NodeName      string `json:",omitempty"`
+		ResourcePools []string
+	}
+	type Essentials struct {
+		NodePods           map[string][]string `json:",omitempty"`
+		QueuedPodCount     int64
+		OtherPodCount      int64
+		OnOtherPoolNode    []MisroutedPod
+		OnMissingNode      []MisroutedPod
+		UnknownPrimaryPool []MisroutedPod
+	}
+	misrouted := func(pods []*k8sCore.Pod) []MisroutedPod {
+		result := []MisroutedPod{}
+		for _, pod := range pods {
+			pools, _ := poolPod.FindPodAssignedResourcePools(pod)
+			result = append(result, MisroutedPod{PodName: pod.Name, NodeName: pod.Spec.NodeName, ResourcePools: pools})
+		}
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].PodName < result[j].PodName
+		})
+		return result
+	}
+	value := Essentials{
+		QueuedPodCount:     int64(len(groups.QueuedPods)),
+		OtherPodCount:      int64(len(groups.OtherPods)),
+		OnOtherPoolNode:    misrouted(groups.OnOtherPoolNodePods),
+		OnMissingNode:      misrouted(groups.OnMissingNodePods),
+		UnknownPrimaryPool: misrouted(groups.UnknownPrimaryPoolPods),
+	}
+	if withNodePods {
+		value.NodePods = map[string][]string{}
+		for name, nodeAndPods := range groups.NodesAndPods {
+			podNames := []string{}
+			for _, pod := range nodeAndPods.Pods {
+				podNames = append(podNames, pod.Name)
+			}
+			sort.Strings(podNames)
+			value.NodePods[name] = podNames
+		}
+	}
+	return poolUtil.ToJSONString(value)
+}
+
+// FormatAllocatableCapacity formats the allocatable capacity computed by ComputeAllocatableCapacityFromSnapshot. With
+// the table output, there is a row per node sorted by name, followed by the total row.
+func FormatAllocatableCapacity(total poolV1.ComputeResource, byNode map[string]poolV1.ComputeResource,
+	options poolUtil.FormatterOptions) string {
+	if !options.IsTableOutput() {
+		type capacity struct {
+			Total  poolV1.ComputeResource
+			ByNode map[string]poolV1.ComputeResource
+		}
+		return poolUtil.ToJSONString(capacity{Total: total, ByNode: byNode})
+	}
+	table := poolUtil.NewTable(append([]string{"NODE"}, poolUtil.ComputeResourceColumns...)...)
+	names := make([]string, 0, len(byNode))
+	for name := range byNode {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		table.AddCells(append(poolUtil.Cells(name), poolUtil.ComputeResourceCells(byNode[name])...)...)
+	}
+	table.AddCells(append(poolUtil.Cells("<total>"), poolUtil.ComputeResourceCells(total)...)...)
+	return table.Render(options)
+}
@@ -2,8 +2,15 @@ package resourcepool
 
 import (
 	"testing"
+	"time"
 
+	k8sCore "k8s.io/api/core/v1"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
 	"github.com/Netflix/titus-resource-pool/machine"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
 	. "github.com/Netflix/titus-resource-pool/util"
 	"github.com/stretchr/testify/require"
 )
@@ -30,3 +37,71 @@ func TestFormatResourcePoolEssentials(t *testing.T) {
 		text,
 	)
 }
+
+func TestFormatFragmentationEssentials(t *testing.T) {
+	shape := machine.R5Metal().Spec.ComputeResource.Divide(4)
+	remaining := shape.Multiply(2)
+	remaining.MemoryMB = remaining.MemoryMB + shape.MemoryMB
+	text := FormatFragmentation(
+		ComputeFragmentation(map[string]poolV1.ComputeResource{"node1": remaining}, shape),
+		FormatterOptions{Level: FormatEssentials},
+	)
+	require.EqualValues(t,
+		"{\"Slots\":2,\"FragmentationIndex\":0.3333333333333333,"+
+			"\"Remaining\":{\"cpu\":48,\"gpu\":0,\"memoryMB\":589824,\"diskMB\":768000,\"networkMBPS\":12500},"+
+			"\"Stranded\":{\"cpu\":0,\"gpu\":0,\"memoryMB\":196608,\"diskMB\":0,\"networkMBPS\":0}}",
+		text,
+	)
+}
+
+func TestFormatResourceSnapshotTable(t *testing.T) {
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 1, 2)
+	node := poolNode.NewNode("node1", testPool, machine.R5Metal())
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{node}, []*k8sCore.Pod{}, 0, 0, true)
+	text := snapshot.FormatResourceSnapshot(FormatterOptions{Output: OutputTable, Columns: []string{"STATE", "COUNT", "CPU"}})
+	require.Equal(t, ""+
+		"STATE           COUNT  CPU\n"+
+		"active          1      96 cores\n"+
+		"notProvisioned  1      96 cores\n"+
+		"onWayOut        0      0 cores\n"+
+		"unhealthy       0      0 cores\n"+
+		"terminated      0\n"+
+		"excluded        0\n",
+		text,
+	)
+}
+
+func TestFormatNodesAndPodsGroupsEssentials(t *testing.T) {
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 1, 2)
+	otherPoolNode := poolNode.NewNode("otherPoolNode", "otherPool", machine.R5Metal())
+	pod := poolPod.ButPodAssignedToNode(poolPod.NewNotScheduledPodWithName("pod1", testPool, poolV1.ComputeResource{},
+		time.Now()), otherPoolNode)
+	groups := GroupNodesAndPodsWithReport(&pool.Spec, []*k8sCore.Pod{pod}, []*k8sCore.Node{otherPoolNode}, nil)
+	text := FormatNodesAndPodsGroups(groups, FormatterOptions{Level: FormatEssentials})
+	require.Equal(t, "{\"QueuedPodCount\":0,\"OtherPodCount\":0,"+
+		"\"OnOtherPoolNode\":[{\"PodName\":\"pod1\",\"NodeName\":\"otherPoolNode\",\"ResourcePools\":[\"testPool\"]}],"+
+		"\"OnMissingNode\":[],\"UnknownPrimaryPool\":[]}",
+		text,
+	)
+}
+
+func TestFormatAllocatableCapacity(t *testing.T) {
+	byNode := map[string]poolV1.ComputeResource{
+		"node2": {CPU: 2, MemoryMB: 1024},
+		"node1": {CPU: 1, MemoryMB: 512},
+	}
+	total := poolV1.ComputeResource{CPU: 3, MemoryMB: 1536}
+
+	require.Equal(t, "{\"Total\":{\"cpu\":3,\"gpu\":0,\"memoryMB\":1536,\"diskMB\":0,\"networkMBPS\":0},"+
+		"\"ByNode\":{\"node1\":{\"cpu\":1,\"gpu\":0,\"memoryMB\":512,\"diskMB\":0,\"networkMBPS\":0},"+
+		"\"node2\":{\"cpu\":2,\"gpu\":0,\"memoryMB\":1024,\"diskMB\":0,\"networkMBPS\":0}}}",
+		FormatAllocatableCapacity(total, byNode, FormatterOptions{}))
+
+	text := FormatAllocatableCapacity(total, byNode, FormatterOptions{Output: OutputTable})
+	require.Equal(t, ""+
+		"NODE     CPU      GPU  MEMORY  DISK  NETWORK\n"+
+		"node1    1 core   0    512MiB  0MiB  0Mbps\n"+
+		"node2    2 cores  0    1GiB    0MiB  0Mbps\n"+
+		"<total>  3 cores  0    1.5GiB  0MiB  0Mbps\n", text)
+}
@@ -0,0 +1,98 @@
+package resourcepool
+
+import (
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+)
+
+// NodeFragmentation describes how well the remaining capacity of a node can be used by pods of the pool shape size.
+type NodeFragmentation struct {
+	// Node capacity not used by any pod.
+	Remaining poolV1.ComputeResource
+	// Number of pool shapes that can still be placed on the node.
+	Slots int64
+	// Remaining capacity that is left after placing all slots, and which cannot be used by another pool shape.
+	Stranded poolV1.ComputeResource
+	// The highest fraction of remaining capacity that is stranded across all resource dimensions. 0 means that the
+	// remaining capacity is fully usable, 1 means that none of it is.
+	FragmentationIndex float64
+}
+
+// PoolFragmentation aggregates fragmentation data of all nodes in a resource pool.
+type PoolFragmentation struct {
+	ResourceShape      poolV1.ComputeResource
+	Remaining          poolV1.ComputeResource
+	Slots              int64
+	Stranded           poolV1.ComputeResource
+	FragmentationIndex float64
+	Nodes              map[string]NodeFragmentation
+}
+
+// ComputeFragmentationFromSnapshot computes fragmentation of active nodes in the snapshot relative to the resource
+// pool shape.
+func ComputeFragmentationFromSnapshot(snapshot *ResourceSnapshot, excludePreemptiblePods bool) *PoolFragmentation {
+	_, _, nodeRemaining := ComputeAllocatableCapacityFromSnapshot(snapshot, poolV1.Zero, false, excludePreemptiblePods)
+	return ComputeFragmentation(nodeRemaining, snapshot.ResourcePool.Spec.ResourceShape.ComputeResource)
+}
+
+// ComputeFragmentation takes remaining capacity per node (as returned by ComputeAllocatableCapacity) and computes
+// how many shapes fit into each node, and how much of the remaining capacity is stranded.
+func ComputeFragmentation(nodeRemaining map[string]poolV1.ComputeResource, shape poolV1.ComputeResource) *PoolFragmentation {
+	result := &PoolFragmentation{
+		ResourceShape: shape,
+		Nodes:         map[string]NodeFragmentation{},
+	}
+	for nodeName, remaining := range nodeRemaining {
+		nodeFragmentation := computeNodeFragmentation(remaining, shape)
+		result.Nodes[nodeName] = nodeFragmentation
+		result.Remaining = result.Remaining.Add(nodeFragmentation.Remaining)
+		result.Slots += nodeFragmentation.Slots
+		result.Stranded = result.Stranded.Add(nodeFragmentation.Stranded)
+	}
+	result.FragmentationIndex = fragmentationIndex(result.Stranded, result.Remaining)
+	return result
+}
+
+func computeNodeFragmentation(remaining poolV1.ComputeResource, shape poolV1.ComputeResource) NodeFragmentation {
+	slots := CountShapeSlots(remaining, shape)
+	stranded := remaining.SubWithLimit(shape.Multiply(slots), 0)
+	return NodeFragmentation{
+		Remaining:          remaining,
+		Slots:              slots,
+		Stranded:           stranded,
+		FragmentationIndex: fragmentationIndex(stranded, remaining),
+	}
+}
+
+// CountShapeSlots returns how many times the shape fits into the given resources. Dimensions not set in the shape
+// are ignored. If the shape is empty, returns 0.
+func CountShapeSlots(resources poolV1.ComputeResource, shape poolV1.ComputeResource) int64 {
+	slots := int64(-1)
+	countDimension := func(available int64, required int64) {
+		if required <= 0 {
+			return
+		}
+		count := available / required
+		if count < 0 {
+			count = 0
+		}
+		if slots < 0 || count < slots {
+			slots = count
+		}
+	}
+	countDimension(resources.CPU, shape.CPU)
+	countDimension(resources.GPU, shape.GPU)
+	countDimension(resources.MemoryMB, shape.MemoryMB)
+	countDimension(resources.DiskMB, shape.DiskMB)
+	countDimension(resources.NetworkMBPS, shape.NetworkMBPS)
+	if slots < 0 {
+		return 0
+	}
+	return slots
+}
+
+func fragmentationIndex(stranded poolV1.ComputeResource, remaining poolV1.ComputeResource) float64 {
+	if !remaining.IsAnyAboveZero() {
+		return 0
+	}
+	return stranded.MaxRatio(remaining)
+}
@@ -0,0 +1,55 @@
+package resourcepool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+)
+
+func TestCountShapeSlots(t *testing.T) {
+	shape := machine.R5Metal().Spec.ComputeResource.Divide(4)
+	require.EqualValues(t, 4, CountShapeSlots(machine.R5Metal().Spec.ComputeResource, shape))
+	require.EqualValues(t, 0, CountShapeSlots(poolV1.Zero, shape))
+	require.EqualValues(t, 0, CountShapeSlots(machine.R5Metal().Spec.ComputeResource, poolV1.Zero))
+}
+
+func TestComputeFragmentationFromSnapshot(t *testing.T) {
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	shape := pool.Spec.ResourceShape.ComputeResource
+	node1 := poolNode.NewNode("node1", testPool, machine.R5Metal())
+	node2 := poolNode.NewNode("node2", testPool, machine.R5Metal())
+
+	// Pod on node1 uses 2.5 shapes of CPU and 1 shape of other resources, so only 1 slot is left there.
+	podResources := shape
+	podResources.CPU = shape.CPU*2 + shape.CPU/2
+	pod := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, podResources, time.Now()), node1)
+
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{node1, node2}, []*k8sCore.Pod{pod}, 0, 0, true)
+	fragmentation := ComputeFragmentationFromSnapshot(snapshot, false)
+
+	require.Len(t, fragmentation.Nodes, 2)
+	node1Fragmentation := fragmentation.Nodes["node1"]
+	require.EqualValues(t, 1, node1Fragmentation.Slots)
+	require.Equal(t, shape.CPU/2, node1Fragmentation.Stranded.CPU)
+	require.Equal(t, shape.MemoryMB*2, node1Fragmentation.Stranded.MemoryMB)
+	require.InDelta(t, 2.0/3, node1Fragmentation.FragmentationIndex, 0.001)
+
+	node2Fragmentation := fragmentation.Nodes["node2"]
+	require.EqualValues(t, 4, node2Fragmentation.Slots)
+	require.Equal(t, poolV1.Zero, node2Fragmentation.Stranded)
+	require.Equal(t, 0.0, node2Fragmentation.FragmentationIndex)
+
+	require.EqualValues(t, 5, fragmentation.Slots)
+	require.Equal(t, node1Fragmentation.Stranded, fragmentation.Stranded)
+	require.Equal(t, node1Fragmentation.Remaining.Add(node2Fragmentation.Remaining), fragmentation.Remaining)
+}
@@ -0,0 +1,133 @@
+package resourcepool
+
+import (
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+)
+
+type IdleCapacityReason string
+
+const (
+	// Idle capacity is between MinIdle and MaxIdle, and the resource count is between MinSize and MaxSize.
+	IdleCapacityReasonWithinLimits IdleCapacityReason = "withinLimits"
+	IdleCapacityReasonBelowMinIdle IdleCapacityReason = "belowMinIdle"
+	IdleCapacityReasonAboveMaxIdle IdleCapacityReason = "aboveMaxIdle"
+	// The resource count is outside of the MinSize/MaxSize range, and is changed to get back into it.
+	IdleCapacityReasonBelowMinSize IdleCapacityReason = "belowMinSize"
+	IdleCapacityReasonAboveMaxSize IdleCapacityReason = "aboveMaxSize"
+	// The change required by the idle capacity limits is reduced, as the resource count reached MinSize or MaxSize.
+	IdleCapacityReasonLimitedByMinSize    IdleCapacityReason = "limitedByMinSize"
+	IdleCapacityReasonLimitedByMaxSize    IdleCapacityReason = "limitedByMaxSize"
+	IdleCapacityReasonAutoScalingDisabled IdleCapacityReason = "autoScalingDisabled"
+	// The resource shape is empty, so the idle capacity cannot be expressed in shapes.
+	IdleCapacityReasonNoResourceShape IdleCapacityReason = "noResourceShape"
+)
+
+type IdleCapacityOptions struct {
+	// Consider capacity occupied by preemptible pods as idle.
+	ExcludePreemptiblePods bool
+	// Count resource shapes requested, but not backed by active nodes yet, as idle. Without it, the resource count
+	// keeps growing until the new nodes become active.
+	IncludeNotProvisioned bool
+}
+
+// IdleCapacityEvaluation is the result of the idle capacity evaluation against the resource pool scaling rules.
+type IdleCapacityEvaluation struct {
+	ResourceShape poolV1.ComputeResource
+	ScalingRules  poolV1.ResourcePoolScalingRules
+	// Idle capacity of active nodes with at least one resource shape left, with the DRF adjustment applied.
+	IdleCapacity poolV1.ComputeResource
+	// Idle capacity of active nodes with at least one resource shape left, without the DRF adjustment. It is an upper
+	// bound of the idle capacity.
+	IdleCapacityActual poolV1.ComputeResource
+	// Sums of the resource shapes that fit into each node.
+	IdleShapes       int64
+	IdleShapesActual int64
+	// Set if IdleCapacityOptions.IncludeNotProvisioned is set. Included in IdleShapes and IdleShapesActual.
+	NotProvisionedShapes int64
+	ResourceCount        int64
+	DesiredResourceCount int64
+	// DesiredResourceCount - ResourceCount
+	Change int64
+	Reason IdleCapacityReason
+}
+
+// EvaluateIdleCapacity computes the idle capacity of the resource pool in resource shape units, and the resource count
+// change needed to keep it between the MinIdle and MaxIdle scaling rules. The desired resource count is kept between
+// MinSize and MaxSize, where MaxSize of zero means no upper limit. If auto scaling is disabled, the idle capacity is
+// computed, but no change is requested.
+func EvaluateIdleCapacity(snapshot *ResourceSnapshot, options IdleCapacityOptions) *IdleCapacityEvaluation {
+	spec := snapshot.ResourcePool.Spec
+	shape := spec.ResourceShape.ComputeResource
+	evaluation := IdleCapacityEvaluation{
+		ResourceShape:        shape,
+		ScalingRules:         spec.ScalingRules,
+		ResourceCount:        spec.ResourceCount,
+		DesiredResourceCount: spec.ResourceCount,
+	}
+	if !shape.IsAnyAboveZero() {
+		evaluation.Reason = IdleCapacityReasonNoResourceShape
+		return &evaluation
+	}
+
+	// Shapes are counted on each node separately, as a shape cannot span multiple nodes.
+	nodeRemainingAdjusted, nodeRemainingActual := computeNodeRemaining(snapshot.PodSnapshot.ScheduledByName,
+		snapshot.NodeSnapshot.ActiveByName, snapshot.allocationOptions(AllocationOptions{
+			Adjust:                 true,
+			ExcludePreemptiblePods: options.ExcludePreemptiblePods,
+		}))
+	for nodeName, remainingActual := range nodeRemainingActual {
+		if !remainingActual.GreaterThanOrEqual(shape) {
+			continue
+		}
+		remainingAdjusted := nodeRemainingAdjusted[nodeName]
+		evaluation.IdleCapacity = evaluation.IdleCapacity.Add(remainingAdjusted)
+		evaluation.IdleCapacityActual = evaluation.IdleCapacityActual.Add(remainingActual)
+		evaluation.IdleShapes += CountShapeSlots(remainingAdjusted, shape)
+		evaluation.IdleShapesActual += CountShapeSlots(remainingActual, shape)
+	}
+	if options.IncludeNotProvisioned {
+		evaluation.NotProvisionedShapes = snapshot.NotProvisionedCount()
+		evaluation.IdleShapes += evaluation.NotProvisionedShapes
+		evaluation.IdleShapesActual += evaluation.NotProvisionedShapes
+	}
+
+	if !spec.ScalingRules.AutoScalingEnabled {
+		evaluation.Reason = IdleCapacityReasonAutoScalingDisabled
+		return &evaluation
+	}
+	evaluation.DesiredResourceCount, evaluation.Reason = computeDesiredResourceCount(spec.ResourceCount,
+		evaluation.IdleShapes, spec.ScalingRules)
+	evaluation.Change = evaluation.DesiredResourceCount - spec.ResourceCount
+	return &evaluation
+}
+
+func computeDesiredResourceCount(resourceCount int64, idleShapes int64,
+	rules poolV1.ResourcePoolScalingRules) (int64, IdleCapacityReason) {
+	desired := resourceCount
+	reason := IdleCapacityReasonWithinLimits
+	if idleShapes < rules.MinIdle {
+		desired = resourceCount + rules.MinIdle - idleShapes
+		reason = IdleCapacityReasonBelowMinIdle
+	} else if idleShapes > rules.MaxIdle {
+		desired = resourceCount - (idleShapes - rules.MaxIdle)
+		reason = IdleCapacityReasonAboveMaxIdle
+	}
+
+	if rules.MaxSize > 0 && desired > rules.MaxSize {
+		desired = rules.MaxSize
+		if reason == IdleCapacityReasonBelowMinIdle && resourceCount <= rules.MaxSize {
+			reason = IdleCapacityReasonLimitedByMaxSize
+		} else {
+			reason = IdleCapacityReasonAboveMaxSize
+		}
+	}
+	if desired < rules.MinSize {
+		desired = rules.MinSize
+		if reason == IdleCapacityReasonAboveMaxIdle && resourceCount >= rules.MinSize {
+			reason = IdleCapacityReasonLimitedByMinSize
+		} else {
+			reason = IdleCapacityReasonBelowMinSize
+		}
+	}
+	return desired, reason
+}
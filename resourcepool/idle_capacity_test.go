@@ -0,0 +1,109 @@
+package resourcepool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+)
+
+func TestEvaluateIdleCapacity(t *testing.T) {
+	now := time.Now()
+	// Four shapes per node, two nodes, and the resource count set to 10 shapes.
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 10)
+	pool.Spec.ScalingRules = poolV1.ResourcePoolScalingRules{
+		MinIdle:            2,
+		MaxIdle:            4,
+		MinSize:            0,
+		MaxSize:            12,
+		AutoScalingEnabled: true,
+	}
+	shape := pool.Spec.ResourceShape.ComputeResource
+	node1 := poolNode.NewNode("node1", testPool, machine.R5Metal())
+	node2 := poolNode.NewNode("node2", testPool, machine.R5Metal())
+	pods := []*k8sCore.Pod{
+		poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, shape.Multiply(4), now), node1),
+		poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, shape.Multiply(3), now), node2),
+	}
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{node1, node2}, pods, 10*time.Minute, 0, true)
+
+	evaluation := EvaluateIdleCapacity(snapshot, IdleCapacityOptions{})
+	require.Equal(t, shape, evaluation.IdleCapacityActual)
+	require.EqualValues(t, 1, evaluation.IdleShapes)
+	require.EqualValues(t, 1, evaluation.IdleShapesActual)
+	require.EqualValues(t, 11, evaluation.DesiredResourceCount)
+	require.EqualValues(t, 1, evaluation.Change)
+	require.Equal(t, IdleCapacityReasonBelowMinIdle, evaluation.Reason)
+
+	// Two shapes requested, but not provisioned yet.
+	evaluation = EvaluateIdleCapacity(snapshot, IdleCapacityOptions{IncludeNotProvisioned: true})
+	require.EqualValues(t, 2, evaluation.NotProvisionedShapes)
+	require.EqualValues(t, 3, evaluation.IdleShapes)
+	require.EqualValues(t, 0, evaluation.Change)
+	require.Equal(t, IdleCapacityReasonWithinLimits, evaluation.Reason)
+
+	pool.Spec.ScalingRules.AutoScalingEnabled = false
+	evaluation = EvaluateIdleCapacity(snapshot, IdleCapacityOptions{})
+	require.EqualValues(t, 1, evaluation.IdleShapes)
+	require.EqualValues(t, 0, evaluation.Change)
+	require.Equal(t, IdleCapacityReasonAutoScalingDisabled, evaluation.Reason)
+}
+
+func TestEvaluateIdleCapacityCountsShapesPerNode(t *testing.T) {
+	now := time.Now()
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 8)
+	shape := pool.Spec.ResourceShape.ComputeResource
+	node1 := poolNode.NewNode("node1", testPool, machine.R5Metal())
+	node2 := poolNode.NewNode("node2", testPool, machine.R5Metal())
+	// One and a half shape left on each node.
+	podResources := shape.Multiply(5).Divide(2)
+	pods := []*k8sCore.Pod{
+		poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, podResources, now), node1),
+		poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, podResources, now), node2),
+	}
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{node1, node2}, pods, 10*time.Minute, 0, true)
+
+	evaluation := EvaluateIdleCapacity(snapshot, IdleCapacityOptions{})
+	require.EqualValues(t, 3, CountShapeSlots(evaluation.IdleCapacityActual, shape))
+	require.EqualValues(t, 2, evaluation.IdleShapes)
+	require.EqualValues(t, 2, evaluation.IdleShapesActual)
+}
+
+func TestComputeDesiredResourceCount(t *testing.T) {
+	rules := poolV1.ResourcePoolScalingRules{MinIdle: 2, MaxIdle: 4, MinSize: 5, MaxSize: 10}
+	testCases := []struct {
+		resourceCount int64
+		idleShapes    int64
+		desired       int64
+		reason        IdleCapacityReason
+	}{
+		{resourceCount: 8, idleShapes: 3, desired: 8, reason: IdleCapacityReasonWithinLimits},
+		{resourceCount: 6, idleShapes: 0, desired: 8, reason: IdleCapacityReasonBelowMinIdle},
+		{resourceCount: 9, idleShapes: 6, desired: 7, reason: IdleCapacityReasonAboveMaxIdle},
+		{resourceCount: 9, idleShapes: 0, desired: 10, reason: IdleCapacityReasonLimitedByMaxSize},
+		{resourceCount: 6, idleShapes: 8, desired: 5, reason: IdleCapacityReasonLimitedByMinSize},
+		{resourceCount: 3, idleShapes: 3, desired: 5, reason: IdleCapacityReasonBelowMinSize},
+		{resourceCount: 12, idleShapes: 3, desired: 10, reason: IdleCapacityReasonAboveMaxSize},
+	}
+	for _, testCase := range testCases {
+		desired, reason := computeDesiredResourceCount(testCase.resourceCount, testCase.idleShapes, rules)
+		require.Equal(t, testCase.desired, desired, "%+v", testCase)
+		require.Equal(t, testCase.reason, reason, "%+v", testCase)
+	}
+
+	// Zero MaxSize means no upper limit.
+	unbounded := poolV1.ResourcePoolScalingRules{MinIdle: 2, MaxIdle: 4}
+	desired, reason := computeDesiredResourceCount(100, 0, unbounded)
+	require.EqualValues(t, 102, desired)
+	require.Equal(t, IdleCapacityReasonBelowMinIdle, reason)
+}
@@ -0,0 +1,218 @@
+package resourcepool
+
+import (
+	"sort"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+)
+
+// MachineTypeCapacity holds desired, active and not provisioned capacity of a single machine type in a resource pool.
+// Capacity is counted in resource pool shapes.
+type MachineTypeCapacity struct {
+	MachineType string
+	// Set if the machine type is listed in the resource pool machine types label.
+	InResourcePool bool
+	// Number of pool shapes that fit into a single machine of this type. Zero if the machine type is unknown.
+	ShapesPerMachine int64
+	ActiveNodeCount  int64
+	ActiveShapeCount int64
+	ActiveCapacity   poolV1.ComputeResource
+	// Queued pods for which this is the first machine type in the pool that they accept and fit into.
+	QueuedPodCount     int64
+	QueuedPodResources poolV1.ComputeResource
+	DesiredShapeCount  int64
+	DesiredNodeCount   int64
+	// Shapes and machines of this type that should be provisioned to reach the desired resource pool size.
+	NotProvisionedShapeCount int64
+	NotProvisionedNodeCount  int64
+}
+
+// MachineTypeAccounting breaks down resource pool capacity by machine type.
+type MachineTypeAccounting struct {
+	ResourceShape            poolV1.ComputeResource
+	DesiredShapeCount        int64
+	ActiveShapeCount         int64
+	NotProvisionedShapeCount int64
+	// Machine types in the resource pool label order, followed by other machine types of active nodes sorted by name.
+	MachineTypes []MachineTypeCapacity
+	// Names of queued pods that do not accept, or do not fit into any machine type of the resource pool.
+	UnplaceablePods []string
+}
+
+// Find returns capacity data of the given machine type.
+func (a *MachineTypeAccounting) Find(machineType string) (*MachineTypeCapacity, bool) {
+	for i := range a.MachineTypes {
+		if a.MachineTypes[i].MachineType == machineType {
+			return &a.MachineTypes[i], true
+		}
+	}
+	return nil, false
+}
+
+// ComputeMachineTypeAccounting computes capacity per machine type. Each active node contributes as many shapes as
+// fit into its machine type (or into its allocatable resources if the machine type is not known). Not provisioned
+// shapes are assigned to machine types needed by the queued pods first, and the remaining ones go to the first
+// machine type listed in the resource pool. Machine types are matched by both the object and the spec names.
+func ComputeMachineTypeAccounting(snapshot *ResourceSnapshot) *MachineTypeAccounting {
+	shape := snapshot.ResourcePool.Spec.ResourceShape.ComputeResource
+	accounting := &MachineTypeAccounting{
+		ResourceShape:     shape,
+		DesiredShapeCount: snapshot.ResourcePool.Spec.ResourceCount,
+		MachineTypes:      []MachineTypeCapacity{},
+		UnplaceablePods:   []string{},
+	}
+
+	poolMachineTypes := GetResourcePoolMachineTypes(snapshot.ResourcePool)
+	for _, name := range poolMachineTypes {
+		if snapshot.findMachineTypeCapacity(accounting.MachineTypes, name) == nil {
+			accounting.MachineTypes = append(accounting.MachineTypes, MachineTypeCapacity{
+				MachineType:      name,
+				InResourcePool:   true,
+				ShapesPerMachine: snapshot.countMachineTypeShapes(name, shape),
+			})
+		}
+	}
+
+	other := []MachineTypeCapacity{}
+	for _, node := range snapshot.NodeSnapshot.ActiveByName {
+		name, shapes, resources := snapshot.describeActiveNode(node, shape)
+		capacity := snapshot.findMachineTypeCapacity(accounting.MachineTypes, name)
+		if capacity == nil {
+			capacity = snapshot.findMachineTypeCapacity(other, name)
+			if capacity == nil {
+				other = append(other, MachineTypeCapacity{
+					MachineType:      name,
+					ShapesPerMachine: snapshot.countMachineTypeShapes(name, shape),
+				})
+				capacity = &other[len(other)-1]
+			}
+		}
+		capacity.ActiveNodeCount++
+		capacity.ActiveShapeCount += shapes
+		capacity.ActiveCapacity = capacity.ActiveCapacity.Add(resources)
+		accounting.ActiveShapeCount += shapes
+	}
+	sort.Slice(other, func(i, j int) bool {
+		return other[i].MachineType < other[j].MachineType
+	})
+
+	for _, pod := range sortedQueuedPods(snapshot.PodSnapshot) {
+		resources := snapshot.PodSnapshot.Metadata[pod.Name].PodResources
+		placed := false
+		for i := range accounting.MachineTypes {
+			capacity := &accounting.MachineTypes[i]
+			machineType, ok := snapshot.findMachineType(capacity.MachineType)
+			if !ok || !poolPod.IsMachineTypeAcceptedByPod(pod, capacity.MachineType) ||
+				!machineType.Spec.ComputeResource.GreaterThanOrEqual(resources) {
+				continue
+			}
+			capacity.QueuedPodCount++
+			capacity.QueuedPodResources = capacity.QueuedPodResources.Add(resources)
+			placed = true
+			break
+		}
+		if !placed {
+			accounting.UnplaceablePods = append(accounting.UnplaceablePods, pod.Name)
+		}
+	}
+
+	if accounting.DesiredShapeCount > accounting.ActiveShapeCount {
+		accounting.NotProvisionedShapeCount = accounting.DesiredShapeCount - accounting.ActiveShapeCount
+	}
+	remaining := accounting.NotProvisionedShapeCount
+	for i := range accounting.MachineTypes {
+		capacity := &accounting.MachineTypes[i]
+		if capacity.ShapesPerMachine == 0 {
+			continue
+		}
+		assigned := minInt64(capacity.QueuedPodResources.SplitByWithCeil(shape), remaining)
+		capacity.NotProvisionedShapeCount += assigned
+		remaining -= assigned
+	}
+	for i := range accounting.MachineTypes {
+		capacity := &accounting.MachineTypes[i]
+		if remaining > 0 && capacity.ShapesPerMachine > 0 {
+			capacity.NotProvisionedShapeCount += remaining
+			remaining = 0
+		}
+	}
+
+	accounting.MachineTypes = append(accounting.MachineTypes, other...)
+	for i := range accounting.MachineTypes {
+		capacity := &accounting.MachineTypes[i]
+		if capacity.ShapesPerMachine > 0 {
+			capacity.NotProvisionedNodeCount = (capacity.NotProvisionedShapeCount + capacity.ShapesPerMachine - 1) /
+				capacity.ShapesPerMachine
+		}
+		capacity.DesiredShapeCount = capacity.ActiveShapeCount + capacity.NotProvisionedShapeCount
+		capacity.DesiredNodeCount = capacity.ActiveNodeCount + capacity.NotProvisionedNodeCount
+	}
+	return accounting
+}
+
+func (snapshot *ResourceSnapshot) findMachineType(name string) (*machineTypeV1.MachineTypeConfig, bool) {
+	if snapshot.MachineCatalog == nil {
+		return nil, false
+	}
+	return snapshot.MachineCatalog.Find(name)
+}
+
+// Returns the machine type object name, or the given name if the machine type is not known.
+func (snapshot *ResourceSnapshot) canonicalMachineTypeName(name string) string {
+	if machineType, ok := snapshot.findMachineType(name); ok {
+		return machineType.Name
+	}
+	return name
+}
+
+func (snapshot *ResourceSnapshot) findMachineTypeCapacity(capacities []MachineTypeCapacity,
+	name string) *MachineTypeCapacity {
+	canonicalName := snapshot.canonicalMachineTypeName(name)
+	for i := range capacities {
+		if snapshot.canonicalMachineTypeName(capacities[i].MachineType) == canonicalName {
+			return &capacities[i]
+		}
+	}
+	return nil
+}
+
+func (snapshot *ResourceSnapshot) countMachineTypeShapes(name string, shape poolV1.ComputeResource) int64 {
+	machineType, ok := snapshot.findMachineType(name)
+	if !ok {
+		return 0
+	}
+	return CountShapeSlots(machineType.Spec.ComputeResource, shape)
+}
+
+// Returns node machine type name, number of shapes it provides, and its allocatable resources.
+func (snapshot *ResourceSnapshot) describeActiveNode(node *k8sCore.Node,
+	shape poolV1.ComputeResource) (string, int64, poolV1.ComputeResource) {
+	resources := poolNode.FromNodeToComputeResource(node)
+	if metadata, ok := snapshot.NodeSnapshot.MetadataByteName[node.Name]; ok {
+		resources = metadata.NodeResources
+		if metadata.MachineType != nil {
+			return metadata.MachineType.Name, CountShapeSlots(metadata.MachineType.Spec.ComputeResource, shape), resources
+		}
+	}
+	name, _ := poolNode.FindNodeInstanceType(node)
+	return name, CountShapeSlots(resources, shape), resources
+}
+
+func sortedQueuedPods(snapshot *poolPod.Snapshot) []*k8sCore.Pod {
+	pods := []*k8sCore.Pod{}
+	for _, pod := range snapshot.QueuedYoungByName {
+		pods = append(pods, pod)
+	}
+	for _, pod := range snapshot.QueuedOldByName {
+		pods = append(pods, pod)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	return pods
+}
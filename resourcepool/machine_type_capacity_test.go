@@ -0,0 +1,97 @@
+package resourcepool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+)
+
+func TestMachineTypeAccounting(t *testing.T) {
+	// Shape fits 4 times into both r5.metal and m5.metal.
+	pool := ButResourcePoolMachineTypes(NewResourcePoolCrdOfMachine(testPool, machine.M5Metal(), 4, 16),
+		[]string{"r5.metal", "m5.metal"})
+	shape := pool.Spec.ResourceShape.ComputeResource
+	r5Node := poolNode.NewNode("r5Node", testPool, machine.R5Metal())
+	m5Node := poolNode.NewNode("m5Node", testPool, machine.M5Metal())
+	m5Pod := poolPod.ButPodMachineRequiredAffinity(
+		poolPod.NewNotScheduledPodWithName("m5Pod", testPool, shape.Multiply(2), time.Now()), []string{"m5.metal"})
+	tooBigPod := poolPod.NewNotScheduledPodWithName("tooBigPod", testPool, machine.R5Metal().Spec.ComputeResource.Multiply(2),
+		time.Now())
+
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal(), machine.M5Metal()},
+		[]*k8sCore.Node{r5Node, m5Node}, []*k8sCore.Pod{m5Pod, tooBigPod}, 0, 0, true)
+	accounting := snapshot.MachineTypeAccounting()
+
+	require.EqualValues(t, 16, accounting.DesiredShapeCount)
+	require.EqualValues(t, 8, accounting.ActiveShapeCount)
+	require.EqualValues(t, 8, accounting.NotProvisionedShapeCount)
+	require.Equal(t, []string{"tooBigPod"}, accounting.UnplaceablePods)
+	require.Len(t, accounting.MachineTypes, 2)
+
+	r5, ok := accounting.Find("r5.metal")
+	require.True(t, ok)
+	require.EqualValues(t, 4, r5.ShapesPerMachine)
+	require.EqualValues(t, 1, r5.ActiveNodeCount)
+	require.EqualValues(t, 6, r5.NotProvisionedShapeCount)
+	require.EqualValues(t, 2, r5.NotProvisionedNodeCount)
+	require.EqualValues(t, 3, r5.DesiredNodeCount)
+
+	m5, ok := accounting.Find("m5.metal")
+	require.True(t, ok)
+	require.EqualValues(t, 1, m5.QueuedPodCount)
+	require.EqualValues(t, 2, m5.NotProvisionedShapeCount)
+	require.EqualValues(t, 1, m5.NotProvisionedNodeCount)
+	require.EqualValues(t, 2, m5.DesiredNodeCount)
+
+	require.True(t, snapshot.HasManyMachineTypes())
+	require.EqualValues(t, 8, snapshot.NotProvisionedCount())
+	require.Equal(t, shape.Multiply(8), snapshot.NotProvisionedCapacity())
+}
+
+func TestMachineTypeAccountingWithMachineTypeOutsideOfPool(t *testing.T) {
+	pool := ButResourcePoolMachineTypes(NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 1, 2),
+		[]string{"r5.metal"})
+	node := poolNode.NewNode("m5Node", testPool, machine.M5Metal())
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal(), machine.M5Metal()},
+		[]*k8sCore.Node{node}, []*k8sCore.Pod{}, 0, 0, true)
+	accounting := snapshot.MachineTypeAccounting()
+
+	require.Len(t, accounting.MachineTypes, 2)
+	require.Equal(t, "r5.metal", accounting.MachineTypes[0].MachineType)
+	require.EqualValues(t, 2, accounting.MachineTypes[0].NotProvisionedNodeCount)
+	require.Equal(t, "m5.metal", accounting.MachineTypes[1].MachineType)
+	require.False(t, accounting.MachineTypes[1].InResourcePool)
+	require.EqualValues(t, 1, accounting.MachineTypes[1].ActiveNodeCount)
+	require.EqualValues(t, 0, accounting.MachineTypes[1].ActiveShapeCount)
+}
+
+func TestMachineTypeAccountingMatchesSpecNames(t *testing.T) {
+	r5 := machine.R5Metal()
+	r5.Name = "r5-metal-v2"
+	pool := ButResourcePoolMachineTypes(NewResourcePoolCrdOfMachine(testPool, r5, 4, 8), []string{"r5.metal"})
+	shape := pool.Spec.ResourceShape.ComputeResource
+	// Node instance type label set to the spec name.
+	node := poolNode.NewNode("r5Node", testPool, machine.R5Metal())
+	pod := poolPod.NewNotScheduledPodWithName("pod", testPool, shape, time.Now())
+
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{r5},
+		[]*k8sCore.Node{node}, []*k8sCore.Pod{pod}, 0, 0, true)
+	accounting := ComputeMachineTypeAccounting(snapshot)
+	require.Len(t, accounting.MachineTypes, 1)
+	require.Equal(t, "r5.metal", accounting.MachineTypes[0].MachineType)
+	require.EqualValues(t, 1, accounting.MachineTypes[0].ActiveNodeCount)
+	require.EqualValues(t, 1, accounting.MachineTypes[0].QueuedPodCount)
+
+	// Without the machine type catalog, queued pods cannot be placed.
+	snapshot.MachineCatalog = nil
+	accounting = ComputeMachineTypeAccounting(snapshot)
+	require.Equal(t, []string{"pod"}, accounting.UnplaceablePods)
+}
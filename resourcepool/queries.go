@@ -6,7 +6,9 @@ import (
 	coreV1 "k8s.io/api/core/v1"
 
 	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolMachine "github.com/Netflix/titus-resource-pool/machine"
 	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
 	poolUtil "github.com/Netflix/titus-resource-pool/util"
 	"github.com/Netflix/titus-resource-pool/util/xstring"
 )
@@ -20,6 +22,23 @@ func GetResourcePoolMachineTypes(resourcePool *poolV1.ResourcePoolConfig) []stri
 	return xstring.SplitByCommaAndTrim(value)
 }
 
+// Machine types referenced by the resource pool shape label which are not in the catalog.
+func FindUnknownResourcePoolMachineTypes(resourcePool *poolV1.ResourcePoolConfig, catalog *poolMachine.Catalog) []string {
+	return catalog.FindUnknown(GetResourcePoolMachineTypes(resourcePool))
+}
+
+// Returns resource pools referencing unknown machine types, with the list of the unknown machine types.
+func ValidateResourcePoolMachineTypes(resourcePools []*poolV1.ResourcePoolConfig,
+	catalog *poolMachine.Catalog) map[string][]string {
+	result := map[string][]string{}
+	for _, resourcePool := range resourcePools {
+		if unknown := FindUnknownResourcePoolMachineTypes(resourcePool, catalog); len(unknown) > 0 {
+			result[resourcePool.Name] = unknown
+		}
+	}
+	return result
+}
+
 // For a given resource pool:
 // 1. find its all nodes and pods
 // 2. map pods to their nodes
@@ -50,6 +69,91 @@ func GroupNodesAndPods(resourcePool *poolV1.ResourcePoolSpec, allPods []*coreV1.
 	return nodesAndPodsMap, podsWithoutNode
 }
 
+// NodesAndPodsGroups is GroupNodesAndPods result with pods not running on the resource pool nodes split by the reason.
+// Finished pods are not included.
+type NodesAndPodsGroups struct {
+	NodesAndPods map[string]poolUtil.NodeAndPods
+	// Pods assigned to the resource pool and not scheduled yet.
+	QueuedPods []*coreV1.Pod
+	// Pods assigned to the resource pool, but running on a node of another resource pool.
+	OnOtherPoolNodePods []*coreV1.Pod
+	// Pods assigned to the resource pool with Spec.NodeName pointing at a node that does not exist (for example
+	// a deleted node).
+	OnMissingNodePods []*coreV1.Pod
+	// Pods running on the resource pool nodes or assigned to it, with a primary resource pool that does not exist.
+	// Those pods are also included in one of the collections above.
+	UnknownPrimaryPoolPods []*coreV1.Pod
+	// Pods not assigned to the resource pool, and not running on its nodes.
+	OtherPods []*coreV1.Pod
+}
+
+// Pods not running on the resource pool nodes. Those are the pods returned as the second GroupNodesAndPods result
+// value, but ordered by the group.
+func (groups *NodesAndPodsGroups) PodsWithoutNode() []*coreV1.Pod {
+	var result []*coreV1.Pod
+	result = append(result, groups.QueuedPods...)
+	result = append(result, groups.OnOtherPoolNodePods...)
+	result = append(result, groups.OnMissingNodePods...)
+	result = append(result, groups.OtherPods...)
+	return result
+}
+
+// Misrouted pods, running on another resource pool nodes, or with an unknown node or primary resource pool.
+func (groups *NodesAndPodsGroups) HasMisroutedPods() bool {
+	return len(groups.OnOtherPoolNodePods) > 0 || len(groups.OnMissingNodePods) > 0 ||
+		len(groups.UnknownPrimaryPoolPods) > 0
+}
+
+// GroupNodesAndPodsWithReport works like GroupNodesAndPods, but pods not running on the resource pool nodes are split
+// into queued, misrouted and unrelated pods. If existingResourcePools is nil, primary resource pools are not checked.
+func GroupNodesAndPodsWithReport(resourcePool *poolV1.ResourcePoolSpec, allPods []*coreV1.Pod, allNodes []*coreV1.Node,
+	existingResourcePools map[string]bool) *NodesAndPodsGroups {
+	groups := NodesAndPodsGroups{NodesAndPods: map[string]poolUtil.NodeAndPods{}}
+	allNodesByName := map[string]*coreV1.Node{}
+	for _, node := range allNodes {
+		allNodesByName[node.Name] = node
+		if poolNode.NodeBelongsToResourcePool(node, resourcePool.Name) {
+			groups.NodesAndPods[node.Name] = poolUtil.NodeAndPods{Node: node}
+		}
+	}
+	for _, pod := range allPods {
+		if poolPod.IsPodFinished(pod) {
+			continue
+		}
+		nodeName := pod.Spec.NodeName
+		assigned := isPodAssignedToResourcePool(pod, resourcePool.Name)
+		if nodeAndPods, ok := groups.NodesAndPods[nodeName]; ok {
+			nodeAndPods.Pods = append(nodeAndPods.Pods, pod)
+			groups.NodesAndPods[nodeName] = nodeAndPods
+		} else if !assigned {
+			groups.OtherPods = append(groups.OtherPods, pod)
+			continue
+		} else if nodeName == "" {
+			groups.QueuedPods = append(groups.QueuedPods, pod)
+		} else if _, ok := allNodesByName[nodeName]; ok {
+			groups.OnOtherPoolNodePods = append(groups.OnOtherPoolNodePods, pod)
+		} else {
+			groups.OnMissingNodePods = append(groups.OnMissingNodePods, pod)
+		}
+		if existingResourcePools != nil {
+			if primary, ok := poolPod.FindPodPrimaryResourcePool(pod); ok && !existingResourcePools[primary] {
+				groups.UnknownPrimaryPoolPods = append(groups.UnknownPrimaryPoolPods, pod)
+			}
+		}
+	}
+	return &groups
+}
+
+func isPodAssignedToResourcePool(pod *coreV1.Pod, resourcePool string) bool {
+	assignedPools, _ := poolPod.FindPodAssignedResourcePools(pod)
+	for _, pool := range assignedPools {
+		if pool == resourcePool {
+			return true
+		}
+	}
+	return false
+}
+
 func GroupNodesByLifecycleState(nodes []*coreV1.Node, now time.Time,
 	nodeBootstrapThreshold time.Duration) ([]*coreV1.Node, []*coreV1.Node, []*coreV1.Node) {
 	comingUp := []*coreV1.Node{}
@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 	k8sCore "k8s.io/api/core/v1"
 
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
 	. "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
 )
 
@@ -21,6 +22,18 @@ func TestGetResourcePoolMachineTypes(t *testing.T) {
 	require.Equal(t, []string{"r5.metal", "m5.metal"}, GetResourcePoolMachineTypes(pool))
 }
 
+func TestValidateResourcePoolMachineTypes(t *testing.T) {
+	catalog := machine.NewCatalog([]*machineTypeV1.MachineTypeConfig{machine.R5Metal()})
+	valid := ButResourcePoolMachineTypes(EmptyResourcePool(), []string{"r5.metal"})
+	invalid := ButResourcePoolMachineTypes(EmptyResourcePool(), []string{"r5.metal", "m5.metal"})
+	invalid.Name = "invalid"
+
+	require.Empty(t, FindUnknownResourcePoolMachineTypes(valid, catalog))
+	require.Equal(t, []string{"m5.metal"}, FindUnknownResourcePoolMachineTypes(invalid, catalog))
+	require.Equal(t, map[string][]string{"invalid": {"m5.metal"}},
+		ValidateResourcePoolMachineTypes([]*ResourcePoolConfig{valid, invalid}, catalog))
+}
+
 func TestGroupNodesAndPods(t *testing.T) {
 	resourcePool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 1, 1).Spec
 	allNodes := []*k8sCore.Node{
@@ -68,3 +81,42 @@ func TestFindPodsWithPrimaryResourcePool(t *testing.T) {
 	require.True(t, len(found) == 1, "expected one pod")
 	require.EqualValues(t, found[0].Name, pod1.Name)
 }
+
+func TestGroupNodesAndPodsWithReport(t *testing.T) {
+	resourcePool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 1, 1).Spec
+	poolNode := node.NewNode("poolNode", resourcePool.Name, machine.R5Metal())
+	otherPoolNode := node.NewNode("otherPoolNode", "otherPool", machine.R5Metal())
+	deletedNode := node.NewNode("deletedNode", resourcePool.Name, machine.R5Metal())
+
+	onPoolNode := ButPodAssignedToNode(NewNotScheduledPod(testPool, ComputeResource{}, time.Now()), poolNode)
+	otherPoolOnPoolNode := ButPodAssignedToNode(NewNotScheduledPod("otherPool", ComputeResource{}, time.Now()), poolNode)
+	queued := NewNotScheduledPod(testPool, ComputeResource{}, time.Now())
+	onOtherPoolNode := ButPodAssignedToNode(NewNotScheduledPod(testPool, ComputeResource{}, time.Now()), otherPoolNode)
+	onDeletedNode := ButPodAssignedToNode(NewNotScheduledPod(testPool, ComputeResource{}, time.Now()), deletedNode)
+	unknownPrimary := ButPodResourcePools(NewNotScheduledPod(testPool, ComputeResource{}, time.Now()),
+		"removedPool,"+testPool)
+	other := ButPodAssignedToNode(NewNotScheduledPod("otherPool", ComputeResource{}, time.Now()), otherPoolNode)
+	finished := ButPodAssignedToNode(NewNotScheduledPod(testPool, ComputeResource{}, time.Now()), deletedNode)
+	finished.Status.Phase = k8sCore.PodSucceeded
+
+	allPods := []*k8sCore.Pod{onPoolNode, otherPoolOnPoolNode, queued, onOtherPoolNode, onDeletedNode, unknownPrimary,
+		other, finished}
+	allNodes := []*k8sCore.Node{poolNode, otherPoolNode}
+	groups := GroupNodesAndPodsWithReport(&resourcePool, allPods, allNodes,
+		map[string]bool{testPool: true, "otherPool": true})
+
+	require.Len(t, groups.NodesAndPods, 1)
+	require.Equal(t, []*k8sCore.Pod{onPoolNode, otherPoolOnPoolNode}, groups.NodesAndPods["poolNode"].Pods)
+	require.Equal(t, []*k8sCore.Pod{queued, unknownPrimary}, groups.QueuedPods)
+	require.Equal(t, []*k8sCore.Pod{onOtherPoolNode}, groups.OnOtherPoolNodePods)
+	require.Equal(t, []*k8sCore.Pod{onDeletedNode}, groups.OnMissingNodePods)
+	require.Equal(t, []*k8sCore.Pod{unknownPrimary}, groups.UnknownPrimaryPoolPods)
+	require.Equal(t, []*k8sCore.Pod{other}, groups.OtherPods)
+	require.True(t, groups.HasMisroutedPods())
+
+	_, podsWithoutNode := GroupNodesAndPods(&resourcePool, allPods, allNodes)
+	require.ElementsMatch(t, podsWithoutNode, groups.PodsWithoutNode())
+
+	noPrimaryCheck := GroupNodesAndPodsWithReport(&resourcePool, allPods, allNodes, nil)
+	require.Empty(t, noPrimaryCheck.UnknownPrimaryPoolPods)
+}
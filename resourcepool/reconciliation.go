@@ -0,0 +1,302 @@
+package resourcepool
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+)
+
+const (
+	ResourceDimensionCPU     = "cpu"
+	ResourceDimensionGPU     = "gpu"
+	ResourceDimensionMemory  = "memoryMB"
+	ResourceDimensionDisk    = "diskMB"
+	ResourceDimensionNetwork = "networkMBPS"
+
+	// Default maximum difference between a node allocatable to physical ratio, and the median ratio of its machine type.
+	DefaultMaxAllocatableRatioDeviation = 0.05
+)
+
+// AllocatableRatioRange is an expected range of the allocatable to physical ratio (inclusive).
+type AllocatableRatioRange struct {
+	Min float64
+	Max float64
+}
+
+type ReconciliationOptions struct {
+	// Maximum difference between a node allocatable to physical ratio, and the median ratio of all nodes of the same
+	// machine type. It is checked for each resource dimension separately.
+	MaxAllocatableRatioDeviation float64
+	// Expected allocatable to physical ratio ranges keyed by the resource dimension (ResourceDimension* values). A
+	// machine type with the median ratio outside of the range likely has a stale machine type CRD, which the median
+	// deviation check cannot detect, as all nodes of the machine type are affected the same way. Dimensions with no
+	// range are not checked.
+	ExpectedAllocatableRatios map[string]AllocatableRatioRange
+	// Expected ranges of specific machine types keyed by the machine type name. They override
+	// ExpectedAllocatableRatios for the listed dimensions.
+	MachineTypeExpectedAllocatableRatios map[string]map[string]AllocatableRatioRange
+}
+
+func NewDefaultReconciliationOptions() ReconciliationOptions {
+	return ReconciliationOptions{
+		MaxAllocatableRatioDeviation: DefaultMaxAllocatableRatioDeviation,
+		ExpectedAllocatableRatios: map[string]AllocatableRatioRange{
+			ResourceDimensionCPU:    {Min: 0.8, Max: 1},
+			ResourceDimensionMemory: {Min: 0.8, Max: 1},
+		},
+	}
+}
+
+func (o ReconciliationOptions) expectedAllocatableRatio(machineType string,
+	dimension string) (AllocatableRatioRange, bool) {
+	if ranges, ok := o.MachineTypeExpectedAllocatableRatios[machineType]; ok {
+		if ratioRange, ok := ranges[dimension]; ok {
+			return ratioRange, true
+		}
+	}
+	ratioRange, ok := o.ExpectedAllocatableRatios[dimension]
+	return ratioRange, ok
+}
+
+// NodeReconciliation compares node allocatable resources with the physical resources of its machine type.
+type NodeReconciliation struct {
+	NodeName    string
+	MachineType string
+	Allocatable poolV1.ComputeResource
+	Physical    poolV1.ComputeResource
+	// Physical minus allocatable resources (kubelet system and kube reserved). Negative values mean that
+	// the node advertises more resources than the machine type defines.
+	Reserved poolV1.ComputeResource
+	// Allocatable to physical ratio per resource dimension. Dimensions with no physical resources are not included.
+	AllocatableRatios map[string]float64
+	// Human readable descriptions of detected problems. Empty if the node is consistent with its machine type.
+	Deviations []string
+}
+
+// MachineTypeReconciliation aggregates reconciliation data of all nodes of a machine type.
+type MachineTypeReconciliation struct {
+	MachineType string
+	NodeCount   int64
+	Physical    poolV1.ComputeResource
+	// Median allocatable to physical ratio per resource dimension.
+	MedianAllocatableRatios map[string]float64
+	MinReserved             poolV1.ComputeResource
+	MaxReserved             poolV1.ComputeResource
+	DeviatingNodeCount      int64
+	// Human readable descriptions of median ratios outside of the expected ranges (see
+	// ReconciliationOptions.ExpectedAllocatableRatios). Empty if the machine type is consistent with its nodes.
+	Deviations []string
+}
+
+// ReconciliationReport compares node allocatable resources with physical resources defined by the machine types.
+type ReconciliationReport struct {
+	Nodes        []NodeReconciliation
+	MachineTypes []MachineTypeReconciliation
+	// Nodes with no instance type label.
+	MissingInstanceType []string
+	// Nodes with an instance type for which there is no machine type CRD, keyed by the node name.
+	UnknownInstanceType map[string]string
+}
+
+// DeviatingMachineTypes returns machine types with at least one detected problem.
+func (r *ReconciliationReport) DeviatingMachineTypes() []MachineTypeReconciliation {
+	result := []MachineTypeReconciliation{}
+	for _, machineType := range r.MachineTypes {
+		if len(machineType.Deviations) > 0 {
+			result = append(result, machineType)
+		}
+	}
+	return result
+}
+
+// DeviatingNodes returns nodes with at least one detected problem.
+func (r *ReconciliationReport) DeviatingNodes() []NodeReconciliation {
+	result := []NodeReconciliation{}
+	for _, node := range r.Nodes {
+		if len(node.Deviations) > 0 {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+func ReconcileNodeCapacityFromSnapshot(snapshot *ResourceSnapshot, options ReconciliationOptions) *ReconciliationReport {
+	nodes := []*k8sCore.Node{}
+	for _, node := range snapshot.NodeSnapshot.AllByName {
+		nodes = append(nodes, node)
+	}
+	return ReconcileNodeCapacity(nodes, snapshot.MachinesByName, options)
+}
+
+// ReconcileNodeCapacity builds the reconciliation report for the given nodes. A node is reported as deviating if
+// its allocatable resources exceed the physical ones, or its allocatable to physical ratio differs from the median
+// ratio of its machine type by more than the configured limit. A machine type is reported as deviating if its median
+// ratio is outside of the expected range.
+func ReconcileNodeCapacity(nodes []*k8sCore.Node, machinesByName map[string]*machineTypeV1.MachineTypeConfig,
+	options ReconciliationOptions) *ReconciliationReport {
+	report := &ReconciliationReport{
+		Nodes:               []NodeReconciliation{},
+		MachineTypes:        []MachineTypeReconciliation{},
+		MissingInstanceType: []string{},
+		UnknownInstanceType: map[string]string{},
+	}
+
+	byMachineType := map[string][]*NodeReconciliation{}
+	for _, node := range nodes {
+		machineType, ok := poolNode.FindNodeInstanceType(node)
+		if !ok || machineType == "" {
+			report.MissingInstanceType = append(report.MissingInstanceType, node.Name)
+			continue
+		}
+		physical, ok := poolNode.FromNodeToPhysicalComputeResource(node, machinesByName)
+		if !ok {
+			report.UnknownInstanceType[node.Name] = machineType
+			continue
+		}
+		allocatable := poolNode.FromNodeToComputeResource(node)
+		nodeReconciliation := &NodeReconciliation{
+			NodeName:          node.Name,
+			MachineType:       machineType,
+			Allocatable:       allocatable,
+			Physical:          *physical,
+			Reserved:          physical.Sub(allocatable),
+			AllocatableRatios: computeAllocatableRatios(allocatable, *physical),
+			Deviations:        []string{},
+		}
+		byMachineType[machineType] = append(byMachineType[machineType], nodeReconciliation)
+	}
+
+	for machineType, machineNodes := range byMachineType {
+		medians := computeMedianRatios(machineNodes)
+		summary := MachineTypeReconciliation{
+			MachineType:             machineType,
+			NodeCount:               int64(len(machineNodes)),
+			Physical:                machineNodes[0].Physical,
+			MedianAllocatableRatios: medians,
+			MinReserved:             machineNodes[0].Reserved,
+			MaxReserved:             machineNodes[0].Reserved,
+			Deviations:              []string{},
+		}
+		for _, dimension := range sortedKeys(medians) {
+			expected, ok := options.expectedAllocatableRatio(machineType, dimension)
+			if ok && (medians[dimension] < expected.Min || medians[dimension] > expected.Max) {
+				summary.Deviations = append(summary.Deviations,
+					fmt.Sprintf("%s median allocatable ratio %.3f is outside of the expected range [%.3f, %.3f]",
+						dimension, medians[dimension], expected.Min, expected.Max))
+			}
+		}
+		for _, nodeReconciliation := range machineNodes {
+			for _, dimension := range sortedKeys(nodeReconciliation.AllocatableRatios) {
+				ratio := nodeReconciliation.AllocatableRatios[dimension]
+				if ratio > 1 {
+					nodeReconciliation.Deviations = append(nodeReconciliation.Deviations,
+						fmt.Sprintf("%s allocatable exceeds physical capacity (ratio %.3f)", dimension, ratio))
+				} else if math.Abs(ratio-medians[dimension]) > options.MaxAllocatableRatioDeviation {
+					nodeReconciliation.Deviations = append(nodeReconciliation.Deviations,
+						fmt.Sprintf("%s allocatable ratio %.3f deviates from machine type median %.3f",
+							dimension, ratio, medians[dimension]))
+				}
+			}
+			if len(nodeReconciliation.Deviations) > 0 {
+				summary.DeviatingNodeCount++
+			}
+			summary.MinReserved = minComputeResource(summary.MinReserved, nodeReconciliation.Reserved)
+			summary.MaxReserved = maxComputeResource(summary.MaxReserved, nodeReconciliation.Reserved)
+			report.Nodes = append(report.Nodes, *nodeReconciliation)
+		}
+		report.MachineTypes = append(report.MachineTypes, summary)
+	}
+
+	sort.Slice(report.Nodes, func(i, j int) bool {
+		return report.Nodes[i].NodeName < report.Nodes[j].NodeName
+	})
+	sort.Slice(report.MachineTypes, func(i, j int) bool {
+		return report.MachineTypes[i].MachineType < report.MachineTypes[j].MachineType
+	})
+	sort.Strings(report.MissingInstanceType)
+	return report
+}
+
+func computeAllocatableRatios(allocatable poolV1.ComputeResource, physical poolV1.ComputeResource) map[string]float64 {
+	ratios := map[string]float64{}
+	addRatio := func(dimension string, allocatableValue int64, physicalValue int64) {
+		if physicalValue > 0 {
+			ratios[dimension] = float64(allocatableValue) / float64(physicalValue)
+		}
+	}
+	addRatio(ResourceDimensionCPU, allocatable.CPU, physical.CPU)
+	addRatio(ResourceDimensionGPU, allocatable.GPU, physical.GPU)
+	addRatio(ResourceDimensionMemory, allocatable.MemoryMB, physical.MemoryMB)
+	addRatio(ResourceDimensionDisk, allocatable.DiskMB, physical.DiskMB)
+	addRatio(ResourceDimensionNetwork, allocatable.NetworkMBPS, physical.NetworkMBPS)
+	return ratios
+}
+
+func computeMedianRatios(nodes []*NodeReconciliation) map[string]float64 {
+	values := map[string][]float64{}
+	for _, node := range nodes {
+		for dimension, ratio := range node.AllocatableRatios {
+			values[dimension] = append(values[dimension], ratio)
+		}
+	}
+	medians := map[string]float64{}
+	for dimension, ratios := range values {
+		sort.Float64s(ratios)
+		middle := len(ratios) / 2
+		if len(ratios)%2 == 0 {
+			medians[dimension] = (ratios[middle-1] + ratios[middle]) / 2
+		} else {
+			medians[dimension] = ratios[middle]
+		}
+	}
+	return medians
+}
+
+func minComputeResource(a poolV1.ComputeResource, b poolV1.ComputeResource) poolV1.ComputeResource {
+	return poolV1.ComputeResource{
+		CPU:         minInt64(a.CPU, b.CPU),
+		GPU:         minInt64(a.GPU, b.GPU),
+		MemoryMB:    minInt64(a.MemoryMB, b.MemoryMB),
+		DiskMB:      minInt64(a.DiskMB, b.DiskMB),
+		NetworkMBPS: minInt64(a.NetworkMBPS, b.NetworkMBPS),
+	}
+}
+
+func maxComputeResource(a poolV1.ComputeResource, b poolV1.ComputeResource) poolV1.ComputeResource {
+	return poolV1.ComputeResource{
+		CPU:         maxInt64(a.CPU, b.CPU),
+		GPU:         maxInt64(a.GPU, b.GPU),
+		MemoryMB:    maxInt64(a.MemoryMB, b.MemoryMB),
+		DiskMB:      maxInt64(a.DiskMB, b.DiskMB),
+		NetworkMBPS: maxInt64(a.NetworkMBPS, b.NetworkMBPS),
+	}
+}
+
+func minInt64(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt64(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
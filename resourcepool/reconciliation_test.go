@@ -0,0 +1,93 @@
+package resourcepool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
+	commonNode "stash.corp.netflix.com/tn/titus-kube-common/node"
+)
+
+func TestReconcileNodeCapacity(t *testing.T) {
+	physical := machine.R5Metal().Spec.ComputeResource
+	withReservedMemory := func(node *k8sCore.Node, reservedMB int64) *k8sCore.Node {
+		node.Status.Allocatable[k8sCore.ResourceMemory] = *resource.NewQuantity(
+			(physical.MemoryMB-reservedMB)*poolUtil.OneMegaByte, resource.BinarySI)
+		return node
+	}
+	node1 := withReservedMemory(poolNode.NewNode("node1", testPool, machine.R5Metal()), 4096)
+	node2 := withReservedMemory(poolNode.NewNode("node2", testPool, machine.R5Metal()), 4096)
+	misconfigured := withReservedMemory(poolNode.NewNode("misconfigured", testPool, machine.R5Metal()), 65536)
+	oversized := poolNode.NewNode("oversized", testPool, machine.R5Metal())
+	oversized.Status.Allocatable[k8sCore.ResourceCPU] = *resource.NewQuantity(physical.CPU*2, resource.DecimalSI)
+	unknown := poolNode.NewNode("unknown", testPool, machine.M5Metal())
+	noLabel := poolNode.NewNode("noLabel", testPool, machine.R5Metal())
+	delete(noLabel.Labels, commonNode.LabelKeyInstanceType)
+
+	report := ReconcileNodeCapacity(
+		[]*k8sCore.Node{node1, node2, misconfigured, oversized, unknown, noLabel},
+		machine.AsMachineTypeMap([]*machineTypeV1.MachineTypeConfig{machine.R5Metal()}),
+		NewDefaultReconciliationOptions(),
+	)
+
+	require.Len(t, report.Nodes, 4)
+	require.Equal(t, []string{"noLabel"}, report.MissingInstanceType)
+	require.Equal(t, map[string]string{"unknown": "m5.metal"}, report.UnknownInstanceType)
+
+	deviating := report.DeviatingNodes()
+	require.Len(t, deviating, 2)
+	require.Equal(t, "misconfigured", deviating[0].NodeName)
+	require.Len(t, deviating[0].Deviations, 1)
+	require.Contains(t, deviating[0].Deviations[0], ResourceDimensionMemory)
+	require.Equal(t, "oversized", deviating[1].NodeName)
+	require.Contains(t, deviating[1].Deviations[0], "exceeds physical capacity")
+	require.Equal(t, -physical.CPU, deviating[1].Reserved.CPU)
+
+	require.Len(t, report.MachineTypes, 1)
+	summary := report.MachineTypes[0]
+	require.EqualValues(t, 4, summary.NodeCount)
+	require.EqualValues(t, 2, summary.DeviatingNodeCount)
+	require.EqualValues(t, 0, summary.MinReserved.MemoryMB)
+	require.EqualValues(t, 65536, summary.MaxReserved.MemoryMB)
+
+	require.Equal(t,
+		`{"NodeCount":4,"DeviatingNodeCount":2,"DeviatingMachineTypeCount":0,"MissingInstanceTypeCount":1,`+
+			`"UnknownInstanceTypeCount":1}`,
+		FormatReconciliationReport(report, poolUtil.FormatterOptions{Level: poolUtil.FormatCompact}),
+	)
+}
+
+func TestReconcileNodeCapacityWithStaleMachineType(t *testing.T) {
+	// The machine type CRD defines twice as much memory as the nodes have, so all nodes deviate the same way.
+	physical := machine.R5Metal().Spec.ComputeResource
+	nodes := []*k8sCore.Node{}
+	for _, name := range []string{"node1", "node2", "node3"} {
+		node := poolNode.NewNode(name, testPool, machine.R5Metal())
+		node.Status.Allocatable[k8sCore.ResourceMemory] = *resource.NewQuantity(
+			physical.MemoryMB/2*poolUtil.OneMegaByte, resource.BinarySI)
+		nodes = append(nodes, node)
+	}
+	machines := machine.AsMachineTypeMap([]*machineTypeV1.MachineTypeConfig{machine.R5Metal()})
+
+	report := ReconcileNodeCapacity(nodes, machines, NewDefaultReconciliationOptions())
+	require.Empty(t, report.DeviatingNodes())
+	deviating := report.DeviatingMachineTypes()
+	require.Len(t, deviating, 1)
+	require.Equal(t, "r5.metal", deviating[0].MachineType)
+	require.Len(t, deviating[0].Deviations, 1)
+	require.Contains(t, deviating[0].Deviations[0], ResourceDimensionMemory)
+
+	// Machine type specific ranges override the global ones.
+	options := NewDefaultReconciliationOptions()
+	options.MachineTypeExpectedAllocatableRatios = map[string]map[string]AllocatableRatioRange{
+		"r5.metal": {ResourceDimensionMemory: {Min: 0.4, Max: 0.6}},
+	}
+	require.Empty(t, ReconcileNodeCapacity(nodes, machines, options).DeviatingMachineTypes())
+}
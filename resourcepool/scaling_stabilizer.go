@@ -0,0 +1,230 @@
+package resourcepool
+
+import (
+	"sync"
+	"time"
+
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolMachine "github.com/Netflix/titus-resource-pool/machine"
+)
+
+type StabilizationReason string
+
+const (
+	StabilizationReasonNoChange  StabilizationReason = "noChange"
+	StabilizationReasonScaleUp   StabilizationReason = "scaleUp"
+	StabilizationReasonScaleDown StabilizationReason = "scaleDown"
+	// Recommendations within the stabilization window do not allow the change.
+	StabilizationReasonStabilizationWindow StabilizationReason = "stabilizationWindow"
+	// The change is smaller than the configured minimum delta.
+	StabilizationReasonBelowMinDelta StabilizationReason = "belowMinDelta"
+	// The change is not allowed yet, as the previous one happened too recently.
+	StabilizationReasonScaleUpCooldown   StabilizationReason = "scaleUpCooldown"
+	StabilizationReasonScaleDownCooldown StabilizationReason = "scaleDownCooldown"
+)
+
+type ScalingStabilizerOptions struct {
+	// Scale up to the lowest, and scale down to the highest recommendation seen within the window. Zero means that
+	// only the latest recommendation is used.
+	ScaleUpWindow   time.Duration
+	ScaleDownWindow time.Duration
+	// Minimum time between two consecutive scale ups.
+	ScaleUpCooldown time.Duration
+	// Minimum time between any resource count change and a scale down.
+	ScaleDownCooldown time.Duration
+	// Changes smaller than this number of resource shapes are ignored.
+	MinScaleUpDelta   int64
+	MinScaleDownDelta int64
+}
+
+// NewDefaultScalingStabilizerOptions returns options with the minimum scale down delta set to the number of resource
+// shapes in machine.TheBiggestMachineThatCouldBe, so scale downs smaller than a machine, which often come from rounding
+// errors, are ignored.
+func NewDefaultScalingStabilizerOptions(shape poolV1.ComputeResource) ScalingStabilizerOptions {
+	return ScalingStabilizerOptions{
+		ScaleUpWindow:     0,
+		ScaleDownWindow:   5 * time.Minute,
+		ScaleUpCooldown:   time.Minute,
+		ScaleDownCooldown: 5 * time.Minute,
+		MinScaleUpDelta:   1,
+		MinScaleDownDelta: ShapesCoveringMachine(poolMachine.TheBiggestMachineThatCouldBeResources, shape),
+	}
+}
+
+// ShapesCoveringMachine returns the number of resource shapes needed to cover the machine resources in every
+// dimension, or 0 if the shape is empty.
+func ShapesCoveringMachine(machineResources poolV1.ComputeResource, shape poolV1.ComputeResource) int64 {
+	return machineResources.SplitByWithCeil(shape)
+}
+
+// StabilizedScaling is a scaling recommendation after stabilization.
+type StabilizedScaling struct {
+	CurrentResourceCount int64
+	// The latest recommendation.
+	RecommendedResourceCount int64
+	// The resource count to set (see ResourceSnapshot.AdjustResourcePoolSize).
+	ResourceCount int64
+	Reason        StabilizationReason
+}
+
+func (scaling StabilizedScaling) HasChange() bool {
+	return scaling.ResourceCount != scaling.CurrentResourceCount
+}
+
+type scalingRecommendation struct {
+	resourceCount int64
+	at            time.Time
+}
+
+// ScalingStabilizer damps scale up and scale down oscillations of a single resource pool, by applying stabilization
+// windows, cooldowns and minimum change thresholds to resource count recommendations. Changes returned by Stabilize
+// are assumed to be applied, while StabilizeAndApply records them only if applied successfully. It is safe to use it
+// concurrently.
+type ScalingStabilizer struct {
+	lock            sync.Mutex
+	options         ScalingStabilizerOptions
+	recommendations []scalingRecommendation
+	lastScaleUp     time.Time
+	lastChange      time.Time
+}
+
+func NewScalingStabilizer(options ScalingStabilizerOptions) *ScalingStabilizer {
+	return &ScalingStabilizer{options: options}
+}
+
+// Stabilize records the recommended resource count, and returns the resource count to set.
+func (stabilizer *ScalingStabilizer) Stabilize(currentResourceCount int64, recommendedResourceCount int64,
+	now time.Time) StabilizedScaling {
+	stabilizer.lock.Lock()
+	defer stabilizer.lock.Unlock()
+
+	result := stabilizer.stabilize(currentResourceCount, recommendedResourceCount, now)
+	stabilizer.recordChange(result, now)
+	return result
+}
+
+// StabilizeAndApply stabilizes the recommendation for the snapshot resource pool, and if the resulting resource
+// count is different from the current one, updates the resource pool. If the update fails, the change is not recorded,
+// so it does not start a cooldown.
+func (stabilizer *ScalingStabilizer) StabilizeAndApply(snapshot *ResourceSnapshot, recommendedResourceCount int64,
+	now time.Time) (StabilizedScaling, error) {
+	stabilizer.lock.Lock()
+	defer stabilizer.lock.Unlock()
+
+	result := stabilizer.stabilize(snapshot.ResourcePool.Spec.ResourceCount, recommendedResourceCount, now)
+	if !result.HasChange() {
+		return result, nil
+	}
+	if err := snapshot.AdjustResourcePoolSize(result.ResourceCount); err != nil {
+		return result, err
+	}
+	stabilizer.recordChange(result, now)
+	return result, nil
+}
+
+func (stabilizer *ScalingStabilizer) stabilize(currentResourceCount int64, recommendedResourceCount int64,
+	now time.Time) StabilizedScaling {
+	stabilizer.recordRecommendation(recommendedResourceCount, now)
+	result := StabilizedScaling{
+		CurrentResourceCount:     currentResourceCount,
+		RecommendedResourceCount: recommendedResourceCount,
+		ResourceCount:            currentResourceCount,
+		Reason:                   StabilizationReasonNoChange,
+	}
+	if recommendedResourceCount == currentResourceCount {
+		return result
+	}
+
+	scaleUpTarget := stabilizer.lowestWithin(stabilizer.options.ScaleUpWindow, now)
+	scaleDownTarget := stabilizer.highestWithin(stabilizer.options.ScaleDownWindow, now)
+	if recommendedResourceCount > currentResourceCount {
+		delta := scaleUpTarget - currentResourceCount
+		if delta <= 0 {
+			result.Reason = StabilizationReasonStabilizationWindow
+		} else if delta < stabilizer.options.MinScaleUpDelta {
+			result.Reason = StabilizationReasonBelowMinDelta
+		} else if isInCooldown(stabilizer.lastScaleUp, stabilizer.options.ScaleUpCooldown, now) {
+			result.Reason = StabilizationReasonScaleUpCooldown
+		} else {
+			result.ResourceCount = scaleUpTarget
+			result.Reason = StabilizationReasonScaleUp
+		}
+		return result
+	}
+
+	delta := currentResourceCount - scaleDownTarget
+	if delta <= 0 {
+		result.Reason = StabilizationReasonStabilizationWindow
+	} else if delta < stabilizer.options.MinScaleDownDelta {
+		result.Reason = StabilizationReasonBelowMinDelta
+	} else if isInCooldown(stabilizer.lastChange, stabilizer.options.ScaleDownCooldown, now) {
+		result.Reason = StabilizationReasonScaleDownCooldown
+	} else {
+		result.ResourceCount = scaleDownTarget
+		result.Reason = StabilizationReasonScaleDown
+	}
+	return result
+}
+
+func (stabilizer *ScalingStabilizer) recordChange(result StabilizedScaling, now time.Time) {
+	switch result.Reason {
+	case StabilizationReasonScaleUp:
+		stabilizer.lastScaleUp = now
+		stabilizer.lastChange = now
+	case StabilizationReasonScaleDown:
+		stabilizer.lastChange = now
+	}
+}
+
+// Keeps recommendations within the longer of the two windows, as only those can affect the future decisions.
+func (stabilizer *ScalingStabilizer) recordRecommendation(resourceCount int64, now time.Time) {
+	stabilizer.recommendations = append(stabilizer.recommendations, scalingRecommendation{
+		resourceCount: resourceCount,
+		at:            now,
+	})
+	window := stabilizer.options.ScaleUpWindow
+	if stabilizer.options.ScaleDownWindow > window {
+		window = stabilizer.options.ScaleDownWindow
+	}
+	deadline := now.Add(-window)
+	kept := stabilizer.recommendations[:0]
+	for _, recommendation := range stabilizer.recommendations {
+		if !recommendation.at.Before(deadline) {
+			kept = append(kept, recommendation)
+		}
+	}
+	stabilizer.recommendations = kept
+}
+
+// The latest recommendation is always included. If the window is zero, it is the only one considered.
+func (stabilizer *ScalingStabilizer) lowestWithin(window time.Duration, now time.Time) int64 {
+	latest := stabilizer.recommendations[len(stabilizer.recommendations)-1]
+	result := latest.resourceCount
+	if window <= 0 {
+		return result
+	}
+	for _, recommendation := range stabilizer.recommendations {
+		if now.Sub(recommendation.at) <= window && recommendation.resourceCount < result {
+			result = recommendation.resourceCount
+		}
+	}
+	return result
+}
+
+func (stabilizer *ScalingStabilizer) highestWithin(window time.Duration, now time.Time) int64 {
+	latest := stabilizer.recommendations[len(stabilizer.recommendations)-1]
+	result := latest.resourceCount
+	if window <= 0 {
+		return result
+	}
+	for _, recommendation := range stabilizer.recommendations {
+		if now.Sub(recommendation.at) <= window && recommendation.resourceCount > result {
+			result = recommendation.resourceCount
+		}
+	}
+	return result
+}
+
+func isInCooldown(lastChange time.Time, cooldown time.Duration, now time.Time) bool {
+	return !lastChange.IsZero() && now.Sub(lastChange) < cooldown
+}
@@ -0,0 +1,121 @@
+package resourcepool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+	ctrlClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+)
+
+func TestScalingStabilizerScaleUp(t *testing.T) {
+	now := time.Now()
+	stabilizer := NewScalingStabilizer(ScalingStabilizerOptions{
+		ScaleUpCooldown: time.Minute,
+		MinScaleUpDelta: 2,
+	})
+
+	result := stabilizer.Stabilize(10, 11, now)
+	require.Equal(t, StabilizationReasonBelowMinDelta, result.Reason)
+	require.False(t, result.HasChange())
+
+	result = stabilizer.Stabilize(10, 14, now)
+	require.Equal(t, StabilizationReasonScaleUp, result.Reason)
+	require.EqualValues(t, 14, result.ResourceCount)
+
+	result = stabilizer.Stabilize(14, 20, now.Add(30*time.Second))
+	require.Equal(t, StabilizationReasonScaleUpCooldown, result.Reason)
+	require.EqualValues(t, 14, result.ResourceCount)
+
+	result = stabilizer.Stabilize(14, 20, now.Add(time.Minute))
+	require.Equal(t, StabilizationReasonScaleUp, result.Reason)
+	require.EqualValues(t, 20, result.ResourceCount)
+}
+
+func TestScalingStabilizerScaleDownWindow(t *testing.T) {
+	now := time.Now()
+	stabilizer := NewScalingStabilizer(ScalingStabilizerOptions{
+		ScaleDownWindow:   time.Minute,
+		ScaleDownCooldown: 3 * time.Minute,
+		MinScaleDownDelta: 1,
+	})
+
+	require.Equal(t, StabilizationReasonNoChange, stabilizer.Stabilize(10, 10, now).Reason)
+
+	// The recommendation of 10 shapes is still in the window.
+	result := stabilizer.Stabilize(10, 6, now.Add(30*time.Second))
+	require.Equal(t, StabilizationReasonStabilizationWindow, result.Reason)
+	require.EqualValues(t, 10, result.ResourceCount)
+
+	result = stabilizer.Stabilize(10, 8, now.Add(2*time.Minute))
+	require.Equal(t, StabilizationReasonScaleDown, result.Reason)
+	require.EqualValues(t, 8, result.ResourceCount)
+
+	result = stabilizer.Stabilize(8, 6, now.Add(3*time.Minute+30*time.Second))
+	require.Equal(t, StabilizationReasonScaleDownCooldown, result.Reason)
+	require.EqualValues(t, 8, result.ResourceCount)
+
+	result = stabilizer.Stabilize(8, 6, now.Add(5*time.Minute+30*time.Second))
+	require.Equal(t, StabilizationReasonScaleDown, result.Reason)
+	require.EqualValues(t, 6, result.ResourceCount)
+}
+
+func TestScalingStabilizerDefaultOptions(t *testing.T) {
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 10)
+	options := NewDefaultScalingStabilizerOptions(pool.Spec.ResourceShape.ComputeResource)
+	require.Equal(t, ShapesCoveringMachine(machine.TheBiggestMachineThatCouldBeResources,
+		pool.Spec.ResourceShape.ComputeResource), options.MinScaleDownDelta)
+	require.GreaterOrEqual(t, options.MinScaleDownDelta, int64(4))
+
+	now := time.Now()
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{}, []*k8sCore.Pod{}, 10*time.Minute, 0, true)
+	stabilizer := NewScalingStabilizer(options)
+
+	// Scale down by less than a machine is ignored.
+	result, err := stabilizer.StabilizeAndApply(snapshot, 9, now)
+	require.NoError(t, err)
+	require.Equal(t, StabilizationReasonBelowMinDelta, result.Reason)
+	require.EqualValues(t, 10, snapshot.ResourcePool.Spec.ResourceCount)
+
+	result, err = stabilizer.StabilizeAndApply(snapshot, 12, now)
+	require.NoError(t, err)
+	require.Equal(t, StabilizationReasonScaleUp, result.Reason)
+	require.EqualValues(t, 12, snapshot.ResourcePool.Spec.ResourceCount)
+}
+
+// Client failing all patch requests. Other methods are not implemented.
+type failingPatchClient struct {
+	ctrlClient.Client
+}
+
+func (failingPatchClient) Patch(context.Context, ctrlClient.Object, ctrlClient.Patch, ...ctrlClient.PatchOption) error {
+	return errors.New("patch failed")
+}
+
+func TestScalingStabilizerFailedApplyIsNotRecorded(t *testing.T) {
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 10)
+	now := time.Now()
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{}, []*k8sCore.Pod{}, 10*time.Minute, 0, true)
+	stabilizer := NewScalingStabilizer(ScalingStabilizerOptions{ScaleUpCooldown: time.Minute, MinScaleUpDelta: 1})
+
+	snapshot.client = failingPatchClient{}
+	_, err := stabilizer.StabilizeAndApply(snapshot, 12, now)
+	require.Error(t, err)
+	require.EqualValues(t, 10, snapshot.ResourcePool.Spec.ResourceCount)
+
+	// No cooldown after the failed update.
+	snapshot.client = nil
+	result, err := stabilizer.StabilizeAndApply(snapshot, 12, now.Add(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, StabilizationReasonScaleUp, result.Reason)
+	require.EqualValues(t, 12, snapshot.ResourcePool.Spec.ResourceCount)
+}
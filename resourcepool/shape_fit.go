@@ -0,0 +1,187 @@
+package resourcepool
+
+import (
+	"math"
+	"sort"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolMachine "github.com/Netflix/titus-resource-pool/machine"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+)
+
+// PodMixEntry describes a pod size and its relative frequency in the expected workload.
+type PodMixEntry struct {
+	Resources poolV1.ComputeResource
+	Weight    float64
+}
+
+// MachineShapeFit describes how well the resource pool shape, and the expected pod mix fit into a machine type.
+type MachineShapeFit struct {
+	MachineType string
+	Resources   poolV1.ComputeResource
+	// Number of shapes that fit into the machine.
+	Slots int64
+	// Machine resources left after placing all slots.
+	Leftover poolV1.ComputeResource
+	// The highest fraction of machine resources left unused across all dimensions when the machine is filled
+	// with shapes. 0 means a perfect fit, 1 means that not even a single shape fits.
+	Waste float64
+	// Expected number of pods of the pod mix that fit into the machine. Zero if no pod mix is provided.
+	PodMixPodsPerMachine float64
+	// Same as Waste, but computed for the pod mix. Zero if no pod mix is provided.
+	PodMixWaste float64
+}
+
+type ShapeFitAnalysis struct {
+	ResourceShape poolV1.ComputeResource
+	// Fit per machine type, sorted by the machine type name.
+	Machines []MachineShapeFit
+	// Machine type with the lowest waste (pod mix waste if the pod mix is provided). Empty if the shape does
+	// not fit into any machine.
+	Recommended string
+}
+
+// AnalyzeResourcePoolShapeFit analyzes the shape fit for machine types listed in the resource pool shape label.
+// Machine types not found in the catalog are returned as the second value.
+func AnalyzeResourcePoolShapeFit(resourcePool *poolV1.ResourcePoolConfig, catalog *poolMachine.Catalog,
+	podMix []PodMixEntry) (*ShapeFitAnalysis, []string) {
+	machineTypes := []*machineTypeV1.MachineTypeConfig{}
+	unknown := []string{}
+	for _, name := range GetResourcePoolMachineTypes(resourcePool) {
+		if machineType, ok := catalog.Find(name); ok {
+			machineTypes = append(machineTypes, machineType)
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
+	return AnalyzeShapeFit(resourcePool.Spec.ResourceShape.ComputeResource, machineTypes, podMix), unknown
+}
+
+// AnalyzeShapeFit computes, for each machine type, how many shapes fit into it and how much of its resources is
+// wasted. If the pod mix is provided, it also computes how much is wasted when the machine is filled with pods
+// in the pod mix proportions, and uses it to select the recommended machine type.
+func AnalyzeShapeFit(shape poolV1.ComputeResource, machineTypes []*machineTypeV1.MachineTypeConfig,
+	podMix []PodMixEntry) *ShapeFitAnalysis {
+	analysis := &ShapeFitAnalysis{
+		ResourceShape: shape,
+		Machines:      []MachineShapeFit{},
+	}
+	mixDemand, hasMix := computePodMixDemand(podMix)
+	for _, machineType := range machineTypes {
+		resources := machineType.Spec.ComputeResource
+		slots := CountShapeSlots(resources, shape)
+		leftover := resources.SubWithLimit(shape.Multiply(slots), 0)
+		fit := MachineShapeFit{
+			MachineType: machineType.Name,
+			Resources:   resources,
+			Slots:       slots,
+			Leftover:    leftover,
+			Waste:       1,
+		}
+		if slots > 0 {
+			fit.Waste = fragmentationIndex(leftover, resources)
+		}
+		if hasMix {
+			fit.PodMixPodsPerMachine, fit.PodMixWaste = computePodMixFit(resources, mixDemand)
+		}
+		analysis.Machines = append(analysis.Machines, fit)
+	}
+	sort.Slice(analysis.Machines, func(i, j int) bool {
+		return analysis.Machines[i].MachineType < analysis.Machines[j].MachineType
+	})
+
+	var best *MachineShapeFit
+	for i := range analysis.Machines {
+		candidate := &analysis.Machines[i]
+		if candidate.Slots == 0 || (hasMix && candidate.PodMixPodsPerMachine == 0) {
+			continue
+		}
+		if best == nil || isBetterShapeFit(candidate, best, hasMix) {
+			best = candidate
+		}
+	}
+	if best != nil {
+		analysis.Recommended = best.MachineType
+	}
+	return analysis
+}
+
+// PodMixFromPods builds the pod mix from the given pods, with pods of the same size grouped into one entry.
+func PodMixFromPods(pods []*k8sCore.Pod) []PodMixEntry {
+	weights := map[poolV1.ComputeResource]float64{}
+	for _, pod := range pods {
+		weights[poolPod.FromPodToComputeResource(pod)]++
+	}
+	podMix := []PodMixEntry{}
+	for resources, weight := range weights {
+		podMix = append(podMix, PodMixEntry{Resources: resources, Weight: weight})
+	}
+	sort.Slice(podMix, func(i, j int) bool {
+		if podMix[i].Weight != podMix[j].Weight {
+			return podMix[i].Weight > podMix[j].Weight
+		}
+		return podMix[i].Resources.LessThan(podMix[j].Resources)
+	})
+	return podMix
+}
+
+// Returns the average pod resources in the pod mix, in the order of dimensions used by resourceDimensions.
+func computePodMixDemand(podMix []PodMixEntry) ([]float64, bool) {
+	totalWeight := 0.0
+	demand := make([]float64, 5)
+	for _, entry := range podMix {
+		if entry.Weight <= 0 {
+			continue
+		}
+		totalWeight += entry.Weight
+		for i, value := range resourceDimensions(entry.Resources) {
+			demand[i] += float64(value) * entry.Weight
+		}
+	}
+	if totalWeight == 0 {
+		return nil, false
+	}
+	for i := range demand {
+		demand[i] /= totalWeight
+	}
+	return demand, true
+}
+
+// Fills the machine with the average pod until one of the resource dimensions is exhausted. Returns the number of
+// pods placed, and the highest fraction of unused resources across all dimensions.
+func computePodMixFit(resources poolV1.ComputeResource, demand []float64) (float64, float64) {
+	available := resourceDimensions(resources)
+	pods := math.Inf(1)
+	for i, required := range demand {
+		if required > 0 {
+			pods = math.Min(pods, float64(available[i])/required)
+		}
+	}
+	if math.IsInf(pods, 1) {
+		return 0, 0
+	}
+	waste := 0.0
+	for i, value := range available {
+		if value > 0 {
+			waste = math.Max(waste, (float64(value)-pods*demand[i])/float64(value))
+		}
+	}
+	return pods, waste
+}
+
+func resourceDimensions(resources poolV1.ComputeResource) []int64 {
+	return []int64{resources.CPU, resources.GPU, resources.MemoryMB, resources.DiskMB, resources.NetworkMBPS}
+}
+
+func isBetterShapeFit(candidate *MachineShapeFit, best *MachineShapeFit, usePodMix bool) bool {
+	if usePodMix && candidate.PodMixWaste != best.PodMixWaste {
+		return candidate.PodMixWaste < best.PodMixWaste
+	}
+	if candidate.Waste != best.Waste {
+		return candidate.Waste < best.Waste
+	}
+	return candidate.Slots > best.Slots
+}
@@ -0,0 +1,84 @@
+package resourcepool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+)
+
+func TestAnalyzeShapeFit(t *testing.T) {
+	shape := machine.R5Metal().Spec.ComputeResource.Divide(4)
+	analysis := AnalyzeShapeFit(shape, []*machineTypeV1.MachineTypeConfig{machine.R5Metal(), machine.M5Metal()}, nil)
+
+	require.Len(t, analysis.Machines, 2)
+	m5 := analysis.Machines[0]
+	require.Equal(t, "m5.metal", m5.MachineType)
+	require.EqualValues(t, 2, m5.Slots)
+	require.EqualValues(t, 48, m5.Leftover.CPU)
+	require.InDelta(t, 0.5, m5.Waste, 0.001)
+
+	r5 := analysis.Machines[1]
+	require.Equal(t, "r5.metal", r5.MachineType)
+	require.EqualValues(t, 4, r5.Slots)
+	require.Equal(t, poolV1.Zero, r5.Leftover)
+	require.EqualValues(t, 0, r5.Waste)
+	require.Equal(t, "r5.metal", analysis.Recommended)
+}
+
+func TestAnalyzeShapeFitWithPodMix(t *testing.T) {
+	shape := machine.R5Metal().Spec.ComputeResource.Divide(4)
+	// Memory light pods fill m5.metal in all dimensions, but leave half of r5.metal memory unused.
+	podMix := []PodMixEntry{{
+		Resources: poolV1.ComputeResource{CPU: 4, MemoryMB: 16384, DiskMB: 43690, NetworkMBPS: 1000},
+		Weight:    1,
+	}}
+	analysis := AnalyzeShapeFit(shape, []*machineTypeV1.MachineTypeConfig{machine.R5Metal(), machine.M5Metal()}, podMix)
+
+	m5 := analysis.Machines[0]
+	require.InDelta(t, 24, m5.PodMixPodsPerMachine, 0.001)
+	require.InDelta(t, 0.04, m5.PodMixWaste, 0.001)
+	r5 := analysis.Machines[1]
+	require.InDelta(t, 24, r5.PodMixPodsPerMachine, 0.001)
+	require.InDelta(t, 0.5, r5.PodMixWaste, 0.001)
+	require.Equal(t, "m5.metal", analysis.Recommended)
+}
+
+func TestAnalyzeShapeFitNoFit(t *testing.T) {
+	shape := machine.R5Metal().Spec.ComputeResource.Multiply(2)
+	analysis := AnalyzeShapeFit(shape, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()}, nil)
+	require.EqualValues(t, 0, analysis.Machines[0].Slots)
+	require.EqualValues(t, 1, analysis.Machines[0].Waste)
+	require.Empty(t, analysis.Recommended)
+}
+
+func TestAnalyzeResourcePoolShapeFit(t *testing.T) {
+	catalog := machine.NewCatalog([]*machineTypeV1.MachineTypeConfig{machine.R5Metal()})
+	pool := ButResourcePoolMachineTypes(NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 2, 1),
+		[]string{"r5.metal", "m5.metal"})
+
+	analysis, unknown := AnalyzeResourcePoolShapeFit(pool, catalog, nil)
+	require.Equal(t, []string{"m5.metal"}, unknown)
+	require.Len(t, analysis.Machines, 1)
+	require.EqualValues(t, 2, analysis.Machines[0].Slots)
+	require.Equal(t, "r5.metal", analysis.Recommended)
+}
+
+func TestPodMixFromPods(t *testing.T) {
+	small := poolV1.ComputeResource{CPU: 1, MemoryMB: 1024, DiskMB: 1024, NetworkMBPS: 128}
+	big := small.Multiply(4)
+	now := time.Now()
+	pods := []*k8sCore.Pod{
+		poolPod.NewNotScheduledPod(testPool, small, now),
+		poolPod.NewNotScheduledPod(testPool, big, now),
+		poolPod.NewNotScheduledPod(testPool, small, now),
+	}
+	require.Equal(t, []PodMixEntry{{Resources: small, Weight: 2}, {Resources: big, Weight: 1}}, PodMixFromPods(pods))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -26,12 +27,28 @@ type ResourceSnapshot struct {
 	NodeBootstrapThreshold time.Duration
 	PodYoungThreshold      time.Duration
 	IncludeKubeletBackend  bool
+	// Optional node state classifier used when nodes are loaded. If not set, the default node state rules are used.
+	NodeStateClassifier *poolNode.StateClassifier
+	// Optional registry of extended resources tracked in node and pod metadata.
+	ResourceRegistry *poolUtil.ResourceRegistry
+	// Controls how pod resources are computed in the pod metadata and in the allocatable capacity.
+	PodResourceOptions poolPod.ResourceOptions
+	// Compute node and pod resources in exact units, and round them once (see AllocationOptions.ExactResources).
+	ExactResources bool
 	// State
-	ResourcePool   *poolV1.ResourcePoolConfig
-	Machines       []*machineTypeV1.MachineTypeConfig
+	ResourcePool *poolV1.ResourcePoolConfig
+	Machines     []*machineTypeV1.MachineTypeConfig
+	// Machine types keyed by both the object and the spec names.
 	MachinesByName map[string]*machineTypeV1.MachineTypeConfig
+	MachineCatalog *poolMachine.Catalog
 	NodeSnapshot   *poolNode.Snapshot
 	PodSnapshot    *poolPod.Snapshot
+	// Nodes not owned by the resource pool.
+	OtherNodesByName map[string]*k8sCore.Node
+	// Pods of the resource pool assigned to nodes not owned by it. They are not included in PodSnapshot.
+	FilteredOutPods []*k8sCore.Pod
+	// Metadata of the filtered out pods keyed by the pod name.
+	FilteredOutPodMetadata map[string]*poolPod.Metadata
 }
 
 func NewResourceSnapshot(client ctrlClient.Client, resourcePoolName string,
@@ -74,8 +91,9 @@ func NewStaticResourceSnapshot(resourcePool *poolV1.ResourcePoolConfig, machines
 		PodYoungThreshold:      podYoungThreshold,
 		IncludeKubeletBackend:  includeKubeletBackend,
 		Machines:               machines,
-		MachinesByName:         poolMachine.AsMachineTypeMap(machines),
+		MachineCatalog:         poolMachine.NewCatalog(machines),
 	}
+	snapshot.MachinesByName = snapshot.MachineCatalog.AsMap()
 	snapshot.updateNodeData(nodes)
 	snapshot.updatePodData(pods)
 	return &snapshot
@@ -92,10 +110,11 @@ func NewStaticResourceSnapshot2(resourcePool *poolV1.ResourcePoolConfig, machine
 		PodYoungThreshold:      podYoungThreshold,
 		IncludeKubeletBackend:  includeKubeletBackend,
 		Machines:               machines,
-		MachinesByName:         poolMachine.AsMachineTypeMap(machines),
+		MachineCatalog:         poolMachine.NewCatalog(machines),
 		NodeSnapshot:           nodeSnapshot,
 		PodSnapshot:            podSnapshot,
 	}
+	snapshot.MachinesByName = snapshot.MachineCatalog.AsMap()
 	return &snapshot
 }
 
@@ -103,6 +122,26 @@ func (snapshot *ResourceSnapshot) ActiveCapacity() poolV1.ComputeResource {
 	return poolNode.SumNodeResourcesInMap(snapshot.NodeSnapshot.ActiveByName)
 }
 
+// Sum of registered extended resources of all active nodes.
+func (snapshot *ResourceSnapshot) ActiveExtendedCapacity() poolUtil.ExtendedResources {
+	sum := poolUtil.ExtendedResources{}
+	for _, node := range snapshot.NodeSnapshot.ActiveByName {
+		sum = sum.Add(poolNode.FromNodeToExtendedResources(node, snapshot.ResourceRegistry))
+	}
+	return sum
+}
+
+// PodResources returns the pod resources from the pod snapshot metadata. For pods without metadata, the resources are
+// computed with PodResourceOptions.
+func (snapshot *ResourceSnapshot) PodResources(pod *k8sCore.Pod) poolV1.ComputeResource {
+	if snapshot.PodSnapshot != nil {
+		if metadata, ok := snapshot.PodSnapshot.Metadata[pod.Name]; ok {
+			return metadata.PodResources
+		}
+	}
+	return poolPod.FromPodToComputeResourceWithOptions(pod, snapshot.PodResourceOptions)
+}
+
 func (snapshot *ResourceSnapshot) ActiveNodeCount() int64 {
 	return int64(len(snapshot.NodeSnapshot.ActiveByName))
 }
@@ -116,16 +155,50 @@ func (snapshot *ResourceSnapshot) OnWayOutNodeCount() int64 {
 	return int64(len(snapshot.NodeSnapshot.OnWayOutByName))
 }
 
+// Sum of resources of nodes which are not on their way out, but which cannot be used due to their bad condition.
+func (snapshot *ResourceSnapshot) UnhealthyCapacity() poolV1.ComputeResource {
+	return poolNode.SumNodeResourcesInMap(snapshot.NodeSnapshot.UnhealthyByName)
+}
+
+func (snapshot *ResourceSnapshot) UnhealthyNodeCount() int64 {
+	return int64(len(snapshot.NodeSnapshot.UnhealthyByName))
+}
+
+func (snapshot *ResourceSnapshot) TerminatedNodeCount() int64 {
+	return int64(len(snapshot.NodeSnapshot.TerminatedByName))
+}
+
+// Resources of the resource pool shapes not backed by active nodes. For pools with many machine types, it is
+// computed from the per machine type shape counts (see MachineTypeAccounting).
 func (snapshot *ResourceSnapshot) NotProvisionedCapacity() poolV1.ComputeResource {
+	if snapshot.HasManyMachineTypes() {
+		return snapshot.ResourcePool.Spec.ResourceShape.Multiply(snapshot.MachineTypeAccounting().NotProvisionedShapeCount)
+	}
 	return snapshot.ResourcePool.Spec.ResourceShape.Multiply(snapshot.ResourcePool.Spec.ResourceCount).
 		SubWithLimit(snapshot.ActiveCapacity(), 0)
 }
 
 func (snapshot *ResourceSnapshot) NotProvisionedCount() int64 {
+	if snapshot.HasManyMachineTypes() {
+		return snapshot.MachineTypeAccounting().NotProvisionedShapeCount
+	}
 	return snapshot.NotProvisionedCapacity().SplitByWithCeil(snapshot.ResourcePool.Spec.ResourceShape.ComputeResource)
 }
 
+// HasManyMachineTypes returns true if the resource pool machine types label lists more than one machine type.
+func (snapshot *ResourceSnapshot) HasManyMachineTypes() bool {
+	return len(GetResourcePoolMachineTypes(snapshot.ResourcePool)) > 1
+}
+
+// Desired, active and not provisioned capacity per machine type.
+func (snapshot *ResourceSnapshot) MachineTypeAccounting() *MachineTypeAccounting {
+	return ComputeMachineTypeAccounting(snapshot)
+}
+
 func (snapshot *ResourceSnapshot) FormatResourceSnapshot(options poolUtil.FormatterOptions) string {
+	if options.IsTableOutput() {
+		return formatResourceSnapshotTable(snapshot, options)
+	}
 	if options.Level == poolUtil.FormatCompact {
 		return formatResourceSnapshotCompact(snapshot)
 	} else if options.Level == poolUtil.FormatEssentials {
@@ -136,19 +209,34 @@ func (snapshot *ResourceSnapshot) FormatResourceSnapshot(options poolUtil.Format
 	return formatResourceSnapshotCompact(snapshot)
 }
 
+// DumpSnapshotToLog writes the snapshot aggregates, the resource pool, and optionally nodes and pods to the log.
+// With the table output, aggregates, nodes and pods are written as text tables. Otherwise, aggregates, nodes and pods
+// are written as structured log entries as by DumpSnapshotToLogWithOptions, and options.Level applies to the
+// resource pool line only (structured entries have fixed keys, and do not include options.ResourceRegistry resources).
 func (snapshot *ResourceSnapshot) DumpSnapshotToLog(log logr.Logger, options poolUtil.FormatterOptions,
 	withNodes bool, withPods bool) {
-	log.Info(fmt.Sprintf("Resource pool aggregates: %s", snapshot.FormatResourceSnapshot(options)))
-	log.Info(fmt.Sprintf("Resource pool: %s", FormatResourcePool(snapshot.ResourcePool, options)))
+	if !options.IsTableOutput() {
+		logOptions := SnapshotLogOptions{WithNodes: withNodes, WithPods: withPods}
+		snapshot.logAggregates(log, logOptions)
+		log.Info(fmt.Sprintf("Resource pool: %s", FormatResourcePool(snapshot.ResourcePool, options)))
+		snapshot.logNodesAndPods(log, logOptions)
+		return
+	}
+	log.Info(fmt.Sprintf("Resource pool aggregates:\n%s", snapshot.FormatResourceSnapshot(options)))
+	log.Info(fmt.Sprintf("Resource pool:\n%s", FormatResourcePool(snapshot.ResourcePool, options)))
 	if withNodes {
+		nodes := []*k8sCore.Node{}
 		for _, node := range snapshot.NodeSnapshot.AllByName {
-			log.Info(fmt.Sprintf("Node: %s", poolNode.FormatNode(node, snapshot.NodeBootstrapThreshold, options)))
+			nodes = append(nodes, node)
 		}
+		log.Info(fmt.Sprintf("Nodes:\n%s", poolNode.FormatNodesTable(nodes, snapshot.NodeBootstrapThreshold, options)))
 	}
 	if withPods {
+		pods := []*k8sCore.Pod{}
 		for _, pod := range snapshot.PodSnapshot.AllByName {
-			log.Info(fmt.Sprintf("Pod: %s", poolPod.FormatPod(pod, options)))
+			pods = append(pods, pod)
 		}
+		log.Info(fmt.Sprintf("Pods:\n%s", poolPod.FormatPodsTable(pods, options)))
 	}
 }
 
@@ -219,7 +307,8 @@ func (snapshot *ResourceSnapshot) ReloadMachines() error {
 		machines = append(machines, &tmp)
 	}
 	snapshot.Machines = machines
-	snapshot.MachinesByName = poolMachine.AsMachineTypeMap(machines)
+	snapshot.MachineCatalog = poolMachine.NewCatalog(machines)
+	snapshot.MachinesByName = snapshot.MachineCatalog.AsMap()
 	return nil
 }
 
@@ -237,7 +326,8 @@ func (snapshot *ResourceSnapshot) ReloadNodes() error {
 }
 
 func (snapshot *ResourceSnapshot) updateNodeData(current []*k8sCore.Node) {
-	snapshot.NodeSnapshot, _ = poolNode.NewSnapshotOfResourcePool(current, snapshot.ResourcePoolName, snapshot.MachinesByName,
+	var other []*k8sCore.Node
+	snapshot.NodeSnapshot, other = poolNode.NewSnapshotOfResourcePool(current, snapshot.ResourcePoolName, snapshot.MachinesByName,
 		poolNode.Options{
 			PastBootstrapDeadline: func(node *k8sCore.Node, now time.Time) bool {
 				return poolNode.Age(node, now) > snapshot.NodeBootstrapThreshold
@@ -245,7 +335,14 @@ func (snapshot *ResourceSnapshot) updateNodeData(current []*k8sCore.Node) {
 			Exclude: func(node *k8sCore.Node) bool {
 				return !snapshot.IncludeKubeletBackend && poolNode.IsKubeletNode(node)
 			},
+			Classifier:       snapshot.NodeStateClassifier,
+			ExactResources:   snapshot.ExactResources,
+			ResourceRegistry: snapshot.ResourceRegistry,
 		})
+	snapshot.OtherNodesByName = map[string]*k8sCore.Node{}
+	for _, node := range other {
+		snapshot.OtherNodesByName[node.Name] = node
+	}
 }
 
 func (snapshot *ResourceSnapshot) ReloadPods() error {
@@ -267,8 +364,16 @@ func (snapshot *ResourceSnapshot) updatePodData(current []*k8sCore.Pod) {
 		PastYoungThreshold: func(pod *k8sCore.Pod, now time.Time) bool {
 			return poolPod.Age(pod, now) > snapshot.PodYoungThreshold
 		},
+		ExactResources:   snapshot.ExactResources,
+		Resources:        snapshot.PodResourceOptions,
+		ResourceRegistry: snapshot.ResourceRegistry,
 	})
-	snapshot.PodSnapshot, _ = poolPod.NewFilteredByNodeAllocation(unfiltered, snapshot.ResourcePoolName, snapshot.NodeSnapshot)
+	snapshot.PodSnapshot, snapshot.FilteredOutPods = poolPod.NewFilteredByNodeAllocation(unfiltered,
+		snapshot.ResourcePoolName, snapshot.NodeSnapshot)
+	snapshot.FilteredOutPodMetadata = map[string]*poolPod.Metadata{}
+	for _, pod := range snapshot.FilteredOutPods {
+		snapshot.FilteredOutPodMetadata[pod.Name] = unfiltered.Metadata[pod.Name]
+	}
 }
 
 func formatResourceSnapshotCompact(snapshot *ResourceSnapshot) string {
@@ -277,6 +382,8 @@ func formatResourceSnapshotCompact(snapshot *ResourceSnapshot) string {
 		ActiveNodeCount         int64
 		NotProvisionedNodeCount int64
 		OnWayOutNodeCount       int64
+		UnhealthyNodeCount      int64
+		TerminatedNodeCount     int64
 		ExcludedNodeCount       int64
 	}
 	value := Compact{
@@ -284,6 +391,8 @@ func formatResourceSnapshotCompact(snapshot *ResourceSnapshot) string {
 		ActiveNodeCount:         snapshot.ActiveNodeCount(),
 		NotProvisionedNodeCount: snapshot.NotProvisionedCount(),
 		OnWayOutNodeCount:       snapshot.OnWayOutNodeCount(),
+		UnhealthyNodeCount:      snapshot.UnhealthyNodeCount(),
+		TerminatedNodeCount:     snapshot.TerminatedNodeCount(),
 		ExcludedNodeCount:       int64(len(snapshot.NodeSnapshot.ExcludedByName)),
 	}
 	return poolUtil.ToJSONString(value)
@@ -295,20 +404,48 @@ func formatResourceSnapshotEssentials(snapshot *ResourceSnapshot) string {
 		ActiveNodeCount         int64
 		NotProvisionedNodeCount int64
 		OnWayOutNodeCount       int64
+		UnhealthyNodeCount      int64
+		TerminatedNodeCount     int64
 		ExcludedNodeCount       int64
 		ActiveResources         poolV1.ComputeResource
 		NotProvisionedResources poolV1.ComputeResource
 		OnWayOutResources       poolV1.ComputeResource
+		UnhealthyResources      poolV1.ComputeResource
+		ActiveExtendedResources poolUtil.ExtendedResources `json:",omitempty"`
+		MachineTypes            []MachineTypeCapacity      `json:",omitempty"`
 	}
 	value := Compact{
 		Name:                    snapshot.ResourcePool.Name,
 		ActiveNodeCount:         snapshot.ActiveNodeCount(),
 		NotProvisionedNodeCount: snapshot.NotProvisionedCount(),
 		OnWayOutNodeCount:       snapshot.OnWayOutNodeCount(),
+		UnhealthyNodeCount:      snapshot.UnhealthyNodeCount(),
+		TerminatedNodeCount:     snapshot.TerminatedNodeCount(),
 		ExcludedNodeCount:       int64(len(snapshot.NodeSnapshot.ExcludedByName)),
 		ActiveResources:         snapshot.ActiveCapacity(),
 		NotProvisionedResources: snapshot.NotProvisionedCapacity(),
 		OnWayOutResources:       snapshot.OnWayOutCapacity(),
+		UnhealthyResources:      snapshot.UnhealthyCapacity(),
+		ActiveExtendedResources: snapshot.ActiveExtendedCapacity(),
+	}
+	if snapshot.HasManyMachineTypes() {
+		value.MachineTypes = snapshot.MachineTypeAccounting().MachineTypes
 	}
 	return poolUtil.ToJSONString(value)
 }
+
+// The notProvisioned count is the number of resource shapes, and the other counts are node counts.
+func formatResourceSnapshotTable(snapshot *ResourceSnapshot, options poolUtil.FormatterOptions) string {
+	table := poolUtil.NewTable(append([]string{"STATE", "COUNT"}, poolUtil.ComputeResourceColumns...)...)
+	addRow := func(state string, count int64, resources poolV1.ComputeResource) {
+		table.AddCells(append(poolUtil.Cells(state, strconv.FormatInt(count, 10)),
+			poolUtil.ComputeResourceCells(resources)...)...)
+	}
+	addRow("active", snapshot.ActiveNodeCount(), snapshot.ActiveCapacity())
+	addRow("notProvisioned", snapshot.NotProvisionedCount(), snapshot.NotProvisionedCapacity())
+	addRow("onWayOut", snapshot.OnWayOutNodeCount(), snapshot.OnWayOutCapacity())
+	addRow("unhealthy", snapshot.UnhealthyNodeCount(), snapshot.UnhealthyCapacity())
+	table.AddRow("terminated", strconv.FormatInt(snapshot.TerminatedNodeCount(), 10))
+	table.AddRow("excluded", strconv.Itoa(len(snapshot.NodeSnapshot.ExcludedByName)))
+	return table.Render(options)
+}
@@ -0,0 +1,186 @@
+package resourcepool
+
+import (
+	"sort"
+
+	"github.com/go-logr/logr"
+	k8sCore "k8s.io/api/core/v1"
+
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+)
+
+const (
+	PodStateQueued    = "queued"
+	PodStateScheduled = "scheduled"
+	PodStateFinished  = "finished"
+)
+
+// SnapshotLogOptions controls what DumpSnapshotToLogWithOptions writes.
+type SnapshotLogOptions struct {
+	WithNodes bool
+	WithPods  bool
+	// logr verbosity levels of the snapshot aggregates, nodes and pods sections.
+	SnapshotVerbosity int
+	NodeVerbosity     int
+	PodVerbosity      int
+	// Maximum number of nodes and pods to log. If there are more, an evenly spaced sample (in the name order) is
+	// logged, followed by an entry with the total and logged counts. Zero means no limit.
+	MaxNodes int
+	MaxPods  int
+	// If set, only nodes and pods that are new, removed, or whose state or resources changed since the previous
+	// snapshot are logged.
+	Previous *ResourceSnapshot
+}
+
+// A node or pod as written to the log. The detail is the node machine type, or the pod node name.
+type snapshotLogEntry struct {
+	state     string
+	detail    string
+	resources poolV1.ComputeResource
+}
+
+// Log message and keys of a snapshot section (nodes or pods).
+type snapshotLogSection struct {
+	kind      string
+	message   string
+	nameKey   string
+	detailKey string
+}
+
+var (
+	nodeLogSection = snapshotLogSection{kind: "nodes", message: "Node", nameKey: "node", detailKey: "machineType"}
+	podLogSection  = snapshotLogSection{kind: "pods", message: "Pod", nameKey: "pod", detailKey: "node"}
+)
+
+// DumpSnapshotToLogWithOptions writes the snapshot aggregates, and optionally nodes and pods as structured log entries.
+func (snapshot *ResourceSnapshot) DumpSnapshotToLogWithOptions(log logr.Logger, options SnapshotLogOptions) {
+	snapshot.logAggregates(log, options)
+	snapshot.logNodesAndPods(log, options)
+}
+
+func (snapshot *ResourceSnapshot) logAggregates(log logr.Logger, options SnapshotLogOptions) {
+	keysAndValues := []interface{}{
+		"resourceCount", snapshot.ResourcePool.Spec.ResourceCount,
+		"activeNodes", snapshot.ActiveNodeCount(),
+		"notProvisionedNodes", snapshot.NotProvisionedCount(),
+		"onWayOutNodes", snapshot.OnWayOutNodeCount(),
+		"unhealthyNodes", snapshot.UnhealthyNodeCount(),
+		"terminatedNodes", snapshot.TerminatedNodeCount(),
+		"excludedNodes", len(snapshot.NodeSnapshot.ExcludedByName),
+	}
+	keysAndValues = append(keysAndValues, resourceKeysAndValues("active", snapshot.ActiveCapacity())...)
+	keysAndValues = append(keysAndValues, resourceKeysAndValues("notProvisioned", snapshot.NotProvisionedCapacity())...)
+	log.V(options.SnapshotVerbosity).WithValues("pool", snapshot.ResourcePoolName).
+		Info("Resource pool snapshot", keysAndValues...)
+}
+
+func (snapshot *ResourceSnapshot) logNodesAndPods(log logr.Logger, options SnapshotLogOptions) {
+	pool := snapshot.ResourcePoolName
+	if options.WithNodes {
+		previous := map[string]snapshotLogEntry{}
+		if options.Previous != nil {
+			previous = options.Previous.nodeLogEntries()
+		}
+		logSection(log.V(options.NodeVerbosity).WithValues("pool", pool), nodeLogSection, snapshot.nodeLogEntries(),
+			previous, options.Previous != nil, options.MaxNodes)
+	}
+	if options.WithPods {
+		previous := map[string]snapshotLogEntry{}
+		if options.Previous != nil {
+			previous = options.Previous.podLogEntries()
+		}
+		logSection(log.V(options.PodVerbosity).WithValues("pool", pool), podLogSection, snapshot.podLogEntries(),
+			previous, options.Previous != nil, options.MaxPods)
+	}
+}
+
+// Logs the current entries (only new or changed ones if there is a previous snapshot), followed by the removed ones.
+func logSection(log logr.Logger, section snapshotLogSection, current map[string]snapshotLogEntry,
+	previous map[string]snapshotLogEntry, hasPrevious bool, limit int) {
+	changed := []string{}
+	for name, entry := range current {
+		if previousEntry, ok := previous[name]; !hasPrevious || !ok || previousEntry != entry {
+			changed = append(changed, name)
+		}
+	}
+	removed := []string{}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+
+	logSample(log, section.kind, changed, limit, func(name string) {
+		entry := current[name]
+		log.Info(section.message, append([]interface{}{section.nameKey, name, "state", entry.state,
+			section.detailKey, entry.detail}, resourceKeysAndValues("", entry.resources)...)...)
+	})
+	for _, name := range removed {
+		log.Info(section.message+" removed", section.nameKey, name, "state", previous[name].state)
+	}
+}
+
+func (snapshot *ResourceSnapshot) nodeLogEntries() map[string]snapshotLogEntry {
+	entries := map[string]snapshotLogEntry{}
+	for name, node := range snapshot.NodeSnapshot.AllByName {
+		state, _ := snapshot.NodeSnapshot.NodeState(name)
+		machineType, _ := poolNode.FindNodeInstanceType(node)
+		entry := snapshotLogEntry{state: state, detail: machineType}
+		if metadata, ok := snapshot.NodeSnapshot.MetadataByteName[name]; ok {
+			entry.resources = metadata.NodeResources
+		}
+		entries[name] = entry
+	}
+	return entries
+}
+
+func (snapshot *ResourceSnapshot) podLogEntries() map[string]snapshotLogEntry {
+	entries := map[string]snapshotLogEntry{}
+	add := func(state string, pods map[string]*k8sCore.Pod) {
+		for name, pod := range pods {
+			entry := snapshotLogEntry{state: state, detail: pod.Spec.NodeName}
+			if metadata, ok := snapshot.PodSnapshot.Metadata[name]; ok {
+				entry.resources = metadata.PodResources
+			}
+			entries[name] = entry
+		}
+	}
+	add(PodStateQueued, snapshot.PodSnapshot.QueuedYoungByName)
+	add(PodStateQueued, snapshot.PodSnapshot.QueuedOldByName)
+	add(PodStateScheduled, snapshot.PodSnapshot.ScheduledByName)
+	add(PodStateFinished, snapshot.PodSnapshot.FinishedByName)
+	return entries
+}
+
+func logSample(log logr.Logger, kind string, names []string, limit int, logOne func(name string)) {
+	if limit <= 0 || len(names) <= limit {
+		for _, name := range names {
+			logOne(name)
+		}
+		return
+	}
+	step := float64(len(names)) / float64(limit)
+	for i := 0; i < limit; i++ {
+		logOne(names[int(float64(i)*step)])
+	}
+	log.Info("Log entries sampled", "kind", kind, "total", len(names), "logged", limit)
+}
+
+func resourceKeysAndValues(prefix string, resources poolV1.ComputeResource) []interface{} {
+	key := func(name string, capitalized string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + capitalized
+	}
+	return []interface{}{
+		key("cpu", "CPU"), resources.CPU,
+		key("gpu", "GPU"), resources.GPU,
+		key("memoryMB", "MemoryMB"), resources.MemoryMB,
+		key("diskMB", "DiskMB"), resources.DiskMB,
+		key("networkMBPS", "NetworkMBPS"), resources.NetworkMBPS,
+	}
+}
@@ -0,0 +1,100 @@
+package resourcepool
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/require"
+	k8sCore "k8s.io/api/core/v1"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+	poolUtil "github.com/Netflix/titus-resource-pool/util"
+)
+
+func newLogTestSnapshot(nodes []*k8sCore.Node, pods []*k8sCore.Pod) *ResourceSnapshot {
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 8)
+	return NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()}, nodes, pods, 0, 0, true)
+}
+
+func dumpToLines(snapshot *ResourceSnapshot, options SnapshotLogOptions, verbosity int) []string {
+	lines := []string{}
+	log := funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{Verbosity: verbosity})
+	snapshot.DumpSnapshotToLogWithOptions(log, options)
+	return lines
+}
+
+func TestDumpSnapshotToLogWithOptions(t *testing.T) {
+	node := poolNode.NewNode("node1", testPool, machine.R5Metal())
+	pod := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPodWithName("pod1", testPool,
+		machine.R5Metal().Spec.ComputeResource.Divide(4), time.Now()), node)
+	snapshot := newLogTestSnapshot([]*k8sCore.Node{node}, []*k8sCore.Pod{pod})
+
+	lines := dumpToLines(snapshot, SnapshotLogOptions{WithNodes: true, WithPods: true}, 0)
+	require.Len(t, lines, 3)
+	require.Contains(t, lines[0], `"msg"="Resource pool snapshot" "pool"="testPool" "resourceCount"=8 "activeNodes"=1`)
+	require.Contains(t, lines[0], `"activeCPU"=96`)
+	require.Contains(t, lines[1], `"msg"="Node" "pool"="testPool" "node"="node1" "state"="active" "machineType"="r5.metal" "cpu"=96`)
+	require.Contains(t, lines[2], `"msg"="Pod" "pool"="testPool" "pod"="pod1" "state"="scheduled" "node"="node1" "cpu"=24`)
+
+	// Nodes and pods are logged only at the requested verbosity.
+	lines = dumpToLines(snapshot, SnapshotLogOptions{WithNodes: true, WithPods: true, NodeVerbosity: 1, PodVerbosity: 1}, 0)
+	require.Len(t, lines, 1)
+}
+
+func TestDumpSnapshotToLogSampling(t *testing.T) {
+	nodes := []*k8sCore.Node{}
+	for i := 0; i < 10; i++ {
+		nodes = append(nodes, poolNode.NewNode(fmt.Sprintf("node%d", i), testPool, machine.R5Metal()))
+	}
+	lines := dumpToLines(newLogTestSnapshot(nodes, []*k8sCore.Pod{}), SnapshotLogOptions{WithNodes: true, MaxNodes: 2}, 0)
+	require.Len(t, lines, 4)
+	require.Contains(t, lines[1], `"node"="node0"`)
+	require.Contains(t, lines[2], `"node"="node5"`)
+	require.Contains(t, lines[3], `"msg"="Log entries sampled" "pool"="testPool" "kind"="nodes" "total"=10 "logged"=2`)
+}
+
+func TestDumpSnapshotToLogChangesOnly(t *testing.T) {
+	node1 := poolNode.NewNode("node1", testPool, machine.R5Metal())
+	node2 := poolNode.NewNode("node2", testPool, machine.R5Metal())
+	node3 := poolNode.NewNode("node3", testPool, machine.R5Metal())
+	previous := newLogTestSnapshot([]*k8sCore.Node{node1, node2}, []*k8sCore.Pod{})
+	current := newLogTestSnapshot([]*k8sCore.Node{node1, node3}, []*k8sCore.Pod{})
+
+	lines := dumpToLines(current, SnapshotLogOptions{WithNodes: true, Previous: previous}, 0)
+	require.Len(t, lines, 3)
+	require.Contains(t, lines[1], `"msg"="Node" "pool"="testPool" "node"="node3"`)
+	require.Contains(t, lines[2], `"msg"="Node removed" "pool"="testPool" "node"="node2" "state"="active"`)
+}
+
+func TestDumpSnapshotToLogStructured(t *testing.T) {
+	node := poolNode.NewNode("node1", testPool, machine.R5Metal())
+	snapshot := newLogTestSnapshot([]*k8sCore.Node{node}, []*k8sCore.Pod{})
+	lines := []string{}
+	log := funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{})
+
+	snapshot.DumpSnapshotToLog(log, poolUtil.FormatterOptions{Level: poolUtil.FormatCompact}, true, false)
+	require.Len(t, lines, 3)
+	require.Contains(t, lines[0], `"msg"="Resource pool snapshot"`)
+	require.Contains(t, lines[1], `Resource pool: {\"Name\":\"testPool\",\"ResourceCount\":8`)
+	require.Contains(t, lines[2], `"msg"="Node" "pool"="testPool" "node"="node1"`)
+}
+
+func TestDumpSnapshotToLogUsesNodeMetadataResources(t *testing.T) {
+	node := poolNode.NewNode("node1", testPool, machine.R5Metal())
+	snapshot := newLogTestSnapshot([]*k8sCore.Node{node}, []*k8sCore.Pod{})
+	// Resources as computed by the snapshot (for example in exact units), rather than from the node object.
+	snapshot.NodeSnapshot.MetadataByteName[node.Name].NodeResources.CPU = 90
+
+	lines := dumpToLines(snapshot, SnapshotLogOptions{WithNodes: true}, 0)
+	require.Len(t, lines, 2)
+	require.Contains(t, lines[1], `"machineType"="r5.metal" "cpu"=90`)
+}
@@ -2,14 +2,18 @@ package resourcepool
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	k8sCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
 	"github.com/Netflix/titus-resource-pool/machine"
 	"github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
 )
 
 func TestKubeletNodesAreExcluded(t *testing.T) {
@@ -32,3 +36,42 @@ func TestKubeletNodesAreExcluded(t *testing.T) {
 	require.Equal(t, 1, len(snapshot.NodeSnapshot.AllByName))
 	require.Equal(t, 1, len(snapshot.NodeSnapshot.ExcludedByName))
 }
+
+func TestTerminatedNodesAreNotActive(t *testing.T) {
+	pool := EmptyResourcePool()
+	terminated := node.ButNodeWithTaint(node.NewNode("node2", pool.Name, machine.R5Metal()),
+		&k8sCore.Taint{Key: node.TaintKeyNodeShutdown, Effect: k8sCore.TaintEffectNoSchedule})
+	nodes := []*k8sCore.Node{node.NewNode("node1", pool.Name, machine.R5Metal()), terminated}
+
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{}, nodes, []*k8sCore.Pod{},
+		0, 0, true)
+	require.EqualValues(t, 1, snapshot.ActiveNodeCount())
+	require.EqualValues(t, 1, snapshot.TerminatedNodeCount())
+	require.Equal(t, machine.R5Metal().Spec.ComputeResource, snapshot.ActiveCapacity())
+}
+
+func TestSnapshotWithExactResources(t *testing.T) {
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 10)
+	active := node.NewNode("node1", testPool, machine.R5Metal())
+	pod := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, poolV1.ComputeResource{}, time.Now()),
+		active)
+	// Two containers requesting half a CPU each
+	pod.Spec.Containers[0].Resources.Requests = k8sCore.ResourceList{k8sCore.ResourceCPU: resource.MustParse("500m")}
+	pod.Spec.Containers = append(pod.Spec.Containers, pod.Spec.Containers[0])
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{active}, []*k8sCore.Pod{pod}, 0, 0, true)
+	nodeCPU := machine.R5Metal().Spec.ComputeResource.CPU
+
+	require.EqualValues(t, 2, snapshot.PodResources(pod).CPU)
+	_, remaining, _ := ComputeAllocatableCapacityFromSnapshot(snapshot, poolV1.Zero, false, false)
+	require.Equal(t, nodeCPU-2, remaining.CPU)
+
+	snapshot.ExactResources = true
+	snapshot.updateNodeData([]*k8sCore.Node{active})
+	snapshot.updatePodData([]*k8sCore.Pod{pod})
+	require.EqualValues(t, 1, snapshot.PodResources(pod).CPU)
+	_, remaining, _ = ComputeAllocatableCapacityFromSnapshot(snapshot, poolV1.Zero, false, false)
+	require.Equal(t, nodeCPU-1, remaining.CPU)
+	_, remaining, _ = ComputeAllocatableCapacityFromSnapshotWithOptions(snapshot, poolV1.Zero, AllocationOptions{})
+	require.Equal(t, nodeCPU-1, remaining.CPU)
+}
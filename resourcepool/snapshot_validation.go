@@ -0,0 +1,205 @@
+package resourcepool
+
+import (
+	"fmt"
+	"sort"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	poolV1 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+)
+
+type SnapshotIssueCategory string
+
+const (
+	// Pod is scheduled to a node which is not in the snapshot.
+	SnapshotIssuePodOnMissingNode SnapshotIssueCategory = "podOnMissingNode"
+	// Pod is scheduled to a node of a resource pool it is not assigned to.
+	SnapshotIssuePodOnOtherPoolNode SnapshotIssueCategory = "podOnOtherPoolNode"
+	// Node has no allocatable CPU or memory.
+	SnapshotIssueNodeZeroAllocatable SnapshotIssueCategory = "nodeZeroAllocatable"
+	// Requests of pods running on a node exceed the node allocatable resources.
+	SnapshotIssueNodeOvercommitted SnapshotIssueCategory = "nodeOvercommitted"
+)
+
+// All issue categories in the reporting order. Metric exporters can use it to reset gauges of categories with
+// no issues.
+var SnapshotIssueCategories = []SnapshotIssueCategory{
+	SnapshotIssuePodOnMissingNode,
+	SnapshotIssuePodOnOtherPoolNode,
+	SnapshotIssueNodeZeroAllocatable,
+	SnapshotIssueNodeOvercommitted,
+}
+
+// SnapshotIssue describes a single inconsistency found in a resource snapshot.
+type SnapshotIssue struct {
+	Category SnapshotIssueCategory
+	NodeName string
+	// Set for pod issues only.
+	PodName string
+	Message string
+	// Amount by which the pod requests exceed the node allocatable resources (overcommitted nodes only).
+	Overcommitted poolV1.ComputeResource
+}
+
+// ValidateResourceSnapshot returns inconsistencies in the snapshot data, typically caused by the API server data
+// being read at different times (for example nodes reloaded without reloading pods). Issues are sorted by category
+// (in the SnapshotIssueCategories order), node name and pod name.
+func ValidateResourceSnapshot(snapshot *ResourceSnapshot) []SnapshotIssue {
+	issues := []SnapshotIssue{}
+	issues = append(issues, validatePodPlacement(snapshot)...)
+	issues = append(issues, validateNodeAllocatable(snapshot)...)
+	issues = append(issues, validateNodeOvercommit(snapshot)...)
+
+	order := map[SnapshotIssueCategory]int{}
+	for i, category := range SnapshotIssueCategories {
+		order[category] = i
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Category != issues[j].Category {
+			return order[issues[i].Category] < order[issues[j].Category]
+		}
+		if issues[i].NodeName != issues[j].NodeName {
+			return issues[i].NodeName < issues[j].NodeName
+		}
+		return issues[i].PodName < issues[j].PodName
+	})
+	return issues
+}
+
+// CountSnapshotIssuesByCategory returns the number of issues in each category. All categories are included, with
+// zero counts for categories with no issues.
+func CountSnapshotIssuesByCategory(issues []SnapshotIssue) map[SnapshotIssueCategory]int {
+	counts := map[SnapshotIssueCategory]int{}
+	for _, category := range SnapshotIssueCategories {
+		counts[category] = 0
+	}
+	for _, issue := range issues {
+		counts[issue.Category]++
+	}
+	return counts
+}
+
+// Pods running on a node of another resource pool they are assigned to are expected (see
+// poolPod.NewSnapshotOfResourcePool), and are not reported. Finished pods are skipped, as their nodes may be long gone.
+func validatePodPlacement(snapshot *ResourceSnapshot) []SnapshotIssue {
+	issues := []SnapshotIssue{}
+	pool := snapshot.ResourcePoolName
+	nodeSnapshot := snapshot.NodeSnapshot
+	for _, pod := range snapshot.FilteredOutPods {
+		nodeName := pod.Spec.NodeName
+		if _, excluded := nodeSnapshot.ExcludedByName[nodeName]; excluded || poolPod.IsPodFinished(pod) {
+			continue
+		}
+		if node, ok := snapshot.OtherNodesByName[nodeName]; ok {
+			nodePool, _ := poolNode.FindNodeResourcePool(node)
+			if hasAssignedResourcePool(snapshot.FilteredOutPodMetadata[pod.Name], nodePool) {
+				continue
+			}
+			issues = append(issues, SnapshotIssue{
+				Category: SnapshotIssuePodOnOtherPoolNode,
+				NodeName: nodeName,
+				PodName:  pod.Name,
+				Message:  fmt.Sprintf("pod is scheduled to a node of resource pool %s", nodePool),
+			})
+			continue
+		}
+		issues = append(issues, SnapshotIssue{
+			Category: SnapshotIssuePodOnMissingNode,
+			NodeName: nodeName,
+			PodName:  pod.Name,
+			Message:  "pod is scheduled to a node which does not exist",
+		})
+	}
+
+	// Pods in the pod snapshot are checked again, as nodes may have been reloaded after the pods.
+	for name, pod := range snapshot.PodSnapshot.ScheduledByName {
+		nodeName := pod.Spec.NodeName
+		if nodeName == "" {
+			continue
+		}
+		if _, ok := nodeSnapshot.AllByName[nodeName]; !ok {
+			if _, excluded := nodeSnapshot.ExcludedByName[nodeName]; !excluded {
+				issues = append(issues, SnapshotIssue{
+					Category: SnapshotIssuePodOnMissingNode,
+					NodeName: nodeName,
+					PodName:  name,
+					Message:  "pod is scheduled to a node which does not exist",
+				})
+			}
+			continue
+		}
+		nodeMetadata, ok := nodeSnapshot.MetadataByteName[nodeName]
+		if ok && nodeMetadata.ResourcePool != pool &&
+			!hasAssignedResourcePool(snapshot.PodSnapshot.Metadata[name], nodeMetadata.ResourcePool) {
+			issues = append(issues, SnapshotIssue{
+				Category: SnapshotIssuePodOnOtherPoolNode,
+				NodeName: nodeName,
+				PodName:  name,
+				Message:  fmt.Sprintf("pod is scheduled to a node of resource pool %s", nodeMetadata.ResourcePool),
+			})
+		}
+	}
+	return issues
+}
+
+func hasAssignedResourcePool(metadata *poolPod.Metadata, resourcePool string) bool {
+	if metadata == nil {
+		return false
+	}
+	for _, assigned := range metadata.AssignedResourcePools {
+		if assigned == resourcePool {
+			return true
+		}
+	}
+	return false
+}
+
+// Terminated nodes are skipped, as they may legitimately report no allocatable resources.
+func validateNodeAllocatable(snapshot *ResourceSnapshot) []SnapshotIssue {
+	issues := []SnapshotIssue{}
+	for name, node := range snapshot.NodeSnapshot.AllByName {
+		if _, terminated := snapshot.NodeSnapshot.TerminatedByName[name]; terminated {
+			continue
+		}
+		allocatable := poolNode.FromNodeToComputeResource(node)
+		if allocatable.CPU <= 0 || allocatable.MemoryMB <= 0 {
+			issues = append(issues, SnapshotIssue{
+				Category: SnapshotIssueNodeZeroAllocatable,
+				NodeName: name,
+				Message: fmt.Sprintf("node allocatable resources: cpu=%d, memoryMB=%d", allocatable.CPU,
+					allocatable.MemoryMB),
+			})
+		}
+	}
+	return issues
+}
+
+// A node is overcommitted if the sum of the pod requests exceeds its allocatable resources in any dimension. All
+// nodes except terminated ones are checked, as inconsistent data is most common for nodes changing their state.
+func validateNodeOvercommit(snapshot *ResourceSnapshot) []SnapshotIssue {
+	issues := []SnapshotIssue{}
+	nodes := map[string]*k8sCore.Node{}
+	for name, node := range snapshot.NodeSnapshot.AllByName {
+		if _, terminated := snapshot.NodeSnapshot.TerminatedByName[name]; !terminated {
+			nodes[name] = node
+		}
+	}
+	nodeToAvailable, nodeToUsed := computeNodeUsage(snapshot.PodSnapshot.ScheduledByName, nodes,
+		AllocationOptions{PodResources: snapshot.PodResourceOptions})
+	for name, used := range nodeToUsed {
+		overcommitted := used.SubWithLimit(nodeToAvailable[name], 0)
+		if !overcommitted.IsAnyAboveZero() {
+			continue
+		}
+		issues = append(issues, SnapshotIssue{
+			Category:      SnapshotIssueNodeOvercommitted,
+			NodeName:      name,
+			Message:       "pod requests exceed node allocatable resources",
+			Overcommitted: overcommitted,
+		})
+	}
+	return issues
+}
@@ -0,0 +1,127 @@
+package resourcepool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+)
+
+func TestValidateResourceSnapshot(t *testing.T) {
+	now := time.Now()
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	machineResources := machine.R5Metal().Spec.ComputeResource
+
+	healthy := poolNode.NewNode("healthy", testPool, machine.R5Metal())
+	overcommitted := poolNode.NewNode("overcommitted", testPool, machine.R5Metal())
+	zeroAllocatable := poolNode.NewNode("zeroAllocatable", testPool, machine.R5Metal())
+	zeroAllocatable.Status.Allocatable[k8sCore.ResourceCPU] = resource.MustParse("0")
+	removed := poolNode.NewNode("removed", testPool, machine.R5Metal())
+	otherPoolNode := poolNode.NewNode("otherPoolNode", "otherPool", machine.R5Metal())
+
+	onHealthy := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, machineResources.Divide(2), now), healthy)
+	onOvercommitted1 := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, machineResources, now), overcommitted)
+	onOvercommitted2 := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, machineResources.Divide(2), now),
+		overcommitted)
+	onRemoved := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, machineResources.Divide(2), now), removed)
+	onOtherPoolNode := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, machineResources.Divide(2), now),
+		otherPoolNode)
+	pods := []*k8sCore.Pod{onHealthy, onOvercommitted1, onOvercommitted2, onRemoved, onOtherPoolNode}
+
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{healthy, overcommitted, zeroAllocatable, removed, otherPoolNode}, pods, 10*time.Minute, 0, true)
+	issues := ValidateResourceSnapshot(snapshot)
+	require.Len(t, issues, 3)
+	require.Equal(t, SnapshotIssuePodOnOtherPoolNode, issues[0].Category)
+	require.Equal(t, "otherPoolNode", issues[0].NodeName)
+	require.Equal(t, onOtherPoolNode.Name, issues[0].PodName)
+
+	// The removed node is not in the API server data, but its pod is.
+	snapshot = NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{healthy, overcommitted, zeroAllocatable, otherPoolNode}, pods, 10*time.Minute, 0, true)
+	issues = ValidateResourceSnapshot(snapshot)
+	require.Len(t, issues, 4)
+
+	require.Equal(t, SnapshotIssuePodOnMissingNode, issues[0].Category)
+	require.Equal(t, "removed", issues[0].NodeName)
+	require.Equal(t, onRemoved.Name, issues[0].PodName)
+
+	require.Equal(t, SnapshotIssuePodOnOtherPoolNode, issues[1].Category)
+	require.Equal(t, "otherPoolNode", issues[1].NodeName)
+
+	require.Equal(t, SnapshotIssueNodeZeroAllocatable, issues[2].Category)
+	require.Equal(t, "zeroAllocatable", issues[2].NodeName)
+
+	require.Equal(t, SnapshotIssueNodeOvercommitted, issues[3].Category)
+	require.Equal(t, "overcommitted", issues[3].NodeName)
+	require.Equal(t, machineResources.Divide(2).CPU, issues[3].Overcommitted.CPU)
+	require.Equal(t, machineResources.Divide(2).MemoryMB, issues[3].Overcommitted.MemoryMB)
+
+	counts := CountSnapshotIssuesByCategory(issues)
+	require.Len(t, counts, len(SnapshotIssueCategories))
+	require.Equal(t, 1, counts[SnapshotIssuePodOnMissingNode])
+	require.Equal(t, 1, counts[SnapshotIssuePodOnOtherPoolNode])
+	require.Equal(t, 1, counts[SnapshotIssueNodeZeroAllocatable])
+	require.Equal(t, 1, counts[SnapshotIssueNodeOvercommitted])
+}
+
+func TestValidateResourceSnapshotSkipsPodsOnExcludedNodes(t *testing.T) {
+	now := time.Now()
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	kubeletNode := poolNode.ButNodeLabel(poolNode.NewNode("kubeletNode", testPool, machine.R5Metal()),
+		poolNode.NodeLabelBackend, poolNode.NodeBackendKubelet)
+	pod := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool,
+		machine.R5Metal().Spec.ComputeResource.Divide(2), now), kubeletNode)
+
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{kubeletNode}, []*k8sCore.Pod{pod}, 10*time.Minute, 0, false)
+	require.Len(t, snapshot.FilteredOutPods, 1)
+	require.Empty(t, ValidateResourceSnapshot(snapshot))
+}
+
+func TestValidateResourceSnapshotSkipsExpectedPlacements(t *testing.T) {
+	now := time.Now()
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	podResources := machine.R5Metal().Spec.ComputeResource.Divide(2)
+	otherPoolNode := poolNode.NewNode("otherPoolNode", "otherPool", machine.R5Metal())
+	deleted := poolNode.NewNode("deleted", testPool, machine.R5Metal())
+
+	// A pod assigned to both resource pools may run on a node of either of them.
+	multiPool := poolPod.ButPodRunningOnNode(poolPod.ButPodResourcePools(
+		poolPod.NewNotScheduledPod(testPool, podResources, now), testPool, "otherPool"), otherPoolNode)
+	finished := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, podResources, now), deleted)
+	finished.Status.Phase = k8sCore.PodSucceeded
+
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{otherPoolNode}, []*k8sCore.Pod{multiPool, finished}, 10*time.Minute, 0, true)
+	require.Len(t, snapshot.FilteredOutPods, 2)
+	require.Empty(t, ValidateResourceSnapshot(snapshot))
+}
+
+func TestValidateResourceSnapshotOvercommitOfNotActiveNodes(t *testing.T) {
+	now := time.Now()
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	machineResources := machine.R5Metal().Spec.ComputeResource
+	decommissioned := poolNode.ButNodeDecommissioned("test", poolNode.NewNode("decommissioned", testPool,
+		machine.R5Metal()))
+	pods := []*k8sCore.Pod{
+		poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, machineResources, now), decommissioned),
+		poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, machineResources, now), decommissioned),
+	}
+
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{decommissioned}, pods, 10*time.Minute, 0, true)
+	require.Empty(t, snapshot.NodeSnapshot.ActiveByName)
+	issues := ValidateResourceSnapshot(snapshot)
+	require.Len(t, issues, 1)
+	require.Equal(t, SnapshotIssueNodeOvercommitted, issues[0].Category)
+	require.Equal(t, "decommissioned", issues[0].NodeName)
+}
@@ -0,0 +1,146 @@
+package resourcepool
+
+import (
+	"sort"
+	"time"
+
+	k8sCore "k8s.io/api/core/v1"
+
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+)
+
+type StuckNodeReason string
+
+const (
+	// Node is bootstrapping for longer than the configured limit.
+	StuckNodeReasonBootstrapping StuckNodeReason = "bootstrapping"
+	// Node has a drain taint (scaling down or decommissioning in the default node state rules) for longer than the
+	// configured limit.
+	StuckNodeReasonDraining StuckNodeReason = "draining"
+	// Node is marked as removable, but it still runs non-preemptible pods.
+	StuckNodeReasonRemovableWithPods StuckNodeReason = "removableWithPods"
+)
+
+const (
+	DefaultStuckBootstrapMultiplier = 3
+	DefaultStuckDrainLimit          = 6 * time.Hour
+)
+
+// StuckNodeFinding describes a single node that does not make progress in its lifecycle.
+type StuckNodeFinding struct {
+	NodeName string
+	Reason   StuckNodeReason
+	// Time when the node entered its current state, if known.
+	Since time.Time
+	// Time spent in the current state, or zero if not known.
+	Duration time.Duration
+	// Taint which triggered the finding (draining nodes only).
+	Taint *k8sCore.Taint
+	// Names of non-preemptible pods still running on the node (removable nodes only).
+	Pods []string
+}
+
+type StuckNodeOptions struct {
+	// A node is stuck bootstrapping if it is older than NodeBootstrapThreshold multiplied by this value.
+	// If the result is zero, bootstrapping nodes are not checked.
+	BootstrapMultiplier float64
+	// A node is stuck draining if its drain taint (see StateClassifier.DrainTaints) was added earlier than this.
+	// Zero disables the check. Taints with no TimeAdded value are ignored.
+	DrainLimit time.Duration
+}
+
+func NewDefaultStuckNodeOptions() StuckNodeOptions {
+	return StuckNodeOptions{
+		BootstrapMultiplier: DefaultStuckBootstrapMultiplier,
+		DrainLimit:          DefaultStuckDrainLimit,
+	}
+}
+
+// DetectStuckNodes returns nodes stuck in the bootstrapping or draining state, and removable nodes which still run
+// non-preemptible pods. A node may be reported more than once if it is stuck for different reasons. Findings are
+// sorted by node name and reason.
+func DetectStuckNodes(snapshot *ResourceSnapshot, options StuckNodeOptions, now time.Time) []StuckNodeFinding {
+	findings := []StuckNodeFinding{}
+
+	bootstrapLimit := time.Duration(float64(snapshot.NodeBootstrapThreshold) * options.BootstrapMultiplier)
+	if bootstrapLimit > 0 {
+		for _, node := range snapshot.NodeSnapshot.BootstrappingByName {
+			if age := poolNode.Age(node, now); age > bootstrapLimit {
+				findings = append(findings, StuckNodeFinding{
+					NodeName: node.Name,
+					Reason:   StuckNodeReasonBootstrapping,
+					Since:    node.CreationTimestamp.Time,
+					Duration: age,
+				})
+			}
+		}
+	}
+
+	classifier := snapshot.NodeStateClassifier
+	if classifier == nil {
+		classifier = poolNode.NewDefaultStateClassifier()
+	}
+
+	if options.DrainLimit > 0 {
+		for _, node := range snapshot.NodeSnapshot.AllByName {
+			if taint := findDrainTaint(classifier, node); taint != nil {
+				if drainTime := now.Sub(taint.TimeAdded.Time); drainTime > options.DrainLimit {
+					findings = append(findings, StuckNodeFinding{
+						NodeName: node.Name,
+						Reason:   StuckNodeReasonDraining,
+						Since:    taint.TimeAdded.Time,
+						Duration: drainTime,
+						Taint:    taint,
+					})
+				}
+			}
+		}
+	}
+
+	podsByNode := findNonPreemptiblePodNamesByNode(snapshot.PodSnapshot)
+	for _, node := range snapshot.NodeSnapshot.AllByName {
+		if pods := podsByNode[node.Name]; len(pods) > 0 && classifier.IsNodeRemovable(node) {
+			findings = append(findings, StuckNodeFinding{
+				NodeName: node.Name,
+				Reason:   StuckNodeReasonRemovableWithPods,
+				Pods:     pods,
+			})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].NodeName != findings[j].NodeName {
+			return findings[i].NodeName < findings[j].NodeName
+		}
+		return findings[i].Reason < findings[j].Reason
+	})
+	return findings
+}
+
+// Returns the earliest added drain taint (see StateClassifier.DrainTaints) with a known TimeAdded value.
+func findDrainTaint(classifier *poolNode.StateClassifier, node *k8sCore.Node) *k8sCore.Taint {
+	var result *k8sCore.Taint
+	for _, taint := range classifier.DrainTaints(node) {
+		if taint.TimeAdded == nil {
+			continue
+		}
+		if result == nil || taint.TimeAdded.Before(result.TimeAdded) {
+			result = taint
+		}
+	}
+	return result
+}
+
+func findNonPreemptiblePodNamesByNode(podSnapshot *poolPod.Snapshot) map[string][]string {
+	result := map[string][]string{}
+	for _, pod := range podSnapshot.ScheduledByName {
+		if pod.Spec.NodeName != "" && !poolPod.IsPodPreemptible(pod) {
+			result[pod.Spec.NodeName] = append(result[pod.Spec.NodeName], pod.Name)
+		}
+	}
+	for _, podNames := range result {
+		sort.Strings(podNames)
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package resourcepool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	k8sCore "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	machineTypeV1 "github.com/Netflix/titus-controllers-api/api/machinetype/v1"
+	"github.com/Netflix/titus-resource-pool/machine"
+	poolNode "github.com/Netflix/titus-resource-pool/node"
+	poolPod "github.com/Netflix/titus-resource-pool/pod"
+	commonNode "stash.corp.netflix.com/tn/titus-kube-common/node"
+	commonPod "stash.corp.netflix.com/tn/titus-kube-common/pod"
+)
+
+func TestDetectStuckNodes(t *testing.T) {
+	now := time.Now()
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	initTaint := &k8sCore.Taint{Key: commonNode.TaintKeyInit, Effect: k8sCore.TaintEffectNoSchedule}
+
+	active := poolNode.NewNode("active", testPool, machine.R5Metal())
+	bootstrapping := poolNode.ButNodeCreatedTimestamp(poolNode.ButNodeWithTaint(
+		poolNode.NewNode("bootstrapping", testPool, machine.R5Metal()), initTaint), now.Add(-5*time.Minute))
+	stuckBootstrapping := poolNode.ButNodeCreatedTimestamp(poolNode.ButNodeWithTaint(
+		poolNode.NewNode("stuckBootstrapping", testPool, machine.R5Metal()), initTaint), now.Add(-time.Hour))
+	draining := poolNode.ButNodeWithTaint(poolNode.NewNode("draining", testPool, machine.R5Metal()),
+		poolNode.NewScalingDownTaint("test", now.Add(-time.Hour)))
+	stuckDraining := poolNode.ButNodeWithTaint(poolNode.NewNode("stuckDraining", testPool, machine.R5Metal()),
+		poolNode.NewDecommissioningTaint("test", now.Add(-10*time.Hour)))
+	removable := poolNode.ButNodeRemovable(poolNode.NewNode("removable", testPool, machine.R5Metal()))
+	removableWithPods := poolNode.ButNodeRemovable(poolNode.NewNode("removableWithPods", testPool, machine.R5Metal()))
+
+	shape := pool.Spec.ResourceShape.ComputeResource
+	preemptible := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, shape, now), removable)
+	preemptible.Spec.PriorityClassName = commonPod.BestEffortEvictablePriority
+	notPreemptible := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, shape, now), removableWithPods)
+	onActive := poolPod.ButPodRunningOnNode(poolPod.NewNotScheduledPod(testPool, shape, now), active)
+
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{active, bootstrapping, stuckBootstrapping, draining, stuckDraining, removable, removableWithPods},
+		[]*k8sCore.Pod{preemptible, notPreemptible, onActive}, 10*time.Minute, 0, true)
+
+	findings := DetectStuckNodes(snapshot, NewDefaultStuckNodeOptions(), now)
+	require.Len(t, findings, 3)
+
+	require.Equal(t, "removableWithPods", findings[0].NodeName)
+	require.Equal(t, StuckNodeReasonRemovableWithPods, findings[0].Reason)
+	require.Equal(t, []string{notPreemptible.Name}, findings[0].Pods)
+
+	require.Equal(t, "stuckBootstrapping", findings[1].NodeName)
+	require.Equal(t, StuckNodeReasonBootstrapping, findings[1].Reason)
+	require.Equal(t, time.Hour, findings[1].Duration)
+
+	require.Equal(t, "stuckDraining", findings[2].NodeName)
+	require.Equal(t, StuckNodeReasonDraining, findings[2].Reason)
+	require.Equal(t, 10*time.Hour, findings[2].Duration)
+	require.Equal(t, stuckDraining.Spec.Taints[0].Key, findings[2].Taint.Key)
+
+	// Lower the limits
+	findings = DetectStuckNodes(snapshot, StuckNodeOptions{BootstrapMultiplier: 0.1, DrainLimit: 30 * time.Minute}, now)
+	require.Len(t, findings, 5)
+
+	// Disable the time based checks
+	findings = DetectStuckNodes(snapshot, StuckNodeOptions{}, now)
+	require.Len(t, findings, 1)
+	require.Equal(t, StuckNodeReasonRemovableWithPods, findings[0].Reason)
+}
+
+func TestDetectStuckNodesWithCustomDrainRules(t *testing.T) {
+	now := time.Now()
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	customTaint := &k8sCore.Taint{Key: "example.com/retiring", Effect: k8sCore.TaintEffectNoSchedule,
+		TimeAdded: &metaV1.Time{Time: now.Add(-10 * time.Hour)}}
+	retiring := poolNode.ButNodeWithTaint(poolNode.NewNode("retiring", testPool, machine.R5Metal()), customTaint)
+	scalingDown := poolNode.ButNodeWithTaint(poolNode.NewNode("scalingDown", testPool, machine.R5Metal()),
+		poolNode.NewScalingDownTaint("test", now.Add(-10*time.Hour)))
+
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{retiring, scalingDown}, []*k8sCore.Pod{}, 10*time.Minute, 0, true)
+	findings := DetectStuckNodes(snapshot, NewDefaultStuckNodeOptions(), now)
+	require.Len(t, findings, 1)
+	require.Equal(t, "scalingDown", findings[0].NodeName)
+
+	rules := poolNode.DefaultNodeStateRules()
+	rules.ScalingDown = []poolNode.NodeMatcher{{TaintKey: customTaint.Key}}
+	snapshot.NodeStateClassifier = poolNode.NewStateClassifier(rules)
+	findings = DetectStuckNodes(snapshot, NewDefaultStuckNodeOptions(), now)
+	require.Len(t, findings, 1)
+	require.Equal(t, "retiring", findings[0].NodeName)
+	require.Equal(t, customTaint.Key, findings[0].Taint.Key)
+}
+
+func TestDetectStuckNodesIgnoresPhasedOutNodes(t *testing.T) {
+	now := time.Now()
+	pool := NewResourcePoolCrdOfMachine(testPool, machine.R5Metal(), 4, 2)
+	phasedOutTaint := poolNode.NewDecommissioningTaint("test", now.Add(-10*time.Hour))
+	phasedOutTaint.Effect = k8sCore.TaintEffectPreferNoSchedule
+	phasedOut := poolNode.ButNodeWithTaint(poolNode.NewNode("phasedOut", testPool, machine.R5Metal()), phasedOutTaint)
+
+	snapshot := NewStaticResourceSnapshot(pool, []*machineTypeV1.MachineTypeConfig{machine.R5Metal()},
+		[]*k8sCore.Node{phasedOut}, []*k8sCore.Pod{}, 10*time.Minute, 0, true)
+	require.True(t, poolNode.NewDefaultStateClassifier().IsNodePhasedOut(phasedOut))
+	require.Empty(t, DetectStuckNodes(snapshot, NewDefaultStuckNodeOptions(), now))
+}
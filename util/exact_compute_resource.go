@@ -0,0 +1,126 @@
+package util
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	v12 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+)
+
+const OneCPUInMillis = int64(1000)
+
+// ExactComputeResource holds compute resources in the smallest units used by Kube, so fractional CPU requests and
+// memory amounts not aligned to MB are not lost in calculations.
+type ExactComputeResource struct {
+	MilliCPU    int64 `json:"milliCPU"`
+	GPU         int64 `json:"gpu"`
+	MemoryBytes int64 `json:"memoryBytes"`
+	DiskBytes   int64 `json:"diskBytes"`
+	NetworkBPS  int64 `json:"networkBPS"`
+}
+
+func FromResourceListToExactComputeResource(limits v1.ResourceList) ExactComputeResource {
+	result := ExactComputeResource{
+		MilliCPU:    limits.Cpu().MilliValue(),
+		MemoryBytes: limits.Memory().Value(),
+		DiskBytes:   limits.StorageEphemeral().Value(),
+	}
+	if gpu, ok := limits[ResourceGpu]; ok {
+		result.GPU += gpu.Value()
+	}
+	if network, ok := limits[ResourceNetwork]; ok {
+		result.NetworkBPS += network.Value()
+	}
+	return result
+}
+
+func FromComputeResourceToExact(resources v12.ComputeResource) ExactComputeResource {
+	return ExactComputeResource{
+		MilliCPU:    resources.CPU * OneCPUInMillis,
+		GPU:         resources.GPU,
+		MemoryBytes: resources.MemoryMB * OneMegaByte,
+		DiskBytes:   resources.DiskMB * OneMegaByte,
+		NetworkBPS:  resources.NetworkMBPS * OneMBPS,
+	}
+}
+
+// ToComputeResource converts exact values to ComputeResource units, truncating fractional parts. When used for
+// available capacity, the result is never bigger than the exact value.
+func (r ExactComputeResource) ToComputeResource() v12.ComputeResource {
+	return v12.ComputeResource{
+		CPU:         r.MilliCPU / OneCPUInMillis,
+		GPU:         r.GPU,
+		MemoryMB:    r.MemoryBytes / OneMegaByte,
+		DiskMB:      r.DiskBytes / OneMegaByte,
+		NetworkMBPS: r.NetworkBPS / OneMBPS,
+	}
+}
+
+// ToComputeResourceRoundUp converts exact values to ComputeResource units, rounding fractional parts up. When used for
+// requested resources, the result is never smaller than the exact value.
+func (r ExactComputeResource) ToComputeResourceRoundUp() v12.ComputeResource {
+	return v12.ComputeResource{
+		CPU:         ceilDiv(r.MilliCPU, OneCPUInMillis),
+		GPU:         r.GPU,
+		MemoryMB:    ceilDiv(r.MemoryBytes, OneMegaByte),
+		DiskMB:      ceilDiv(r.DiskBytes, OneMegaByte),
+		NetworkMBPS: ceilDiv(r.NetworkBPS, OneMBPS),
+	}
+}
+
+func (r ExactComputeResource) Add(o ExactComputeResource) ExactComputeResource {
+	return ExactComputeResource{
+		MilliCPU:    r.MilliCPU + o.MilliCPU,
+		GPU:         r.GPU + o.GPU,
+		MemoryBytes: r.MemoryBytes + o.MemoryBytes,
+		DiskBytes:   r.DiskBytes + o.DiskBytes,
+		NetworkBPS:  r.NetworkBPS + o.NetworkBPS,
+	}
+}
+
+func (r ExactComputeResource) Sub(o ExactComputeResource) ExactComputeResource {
+	return ExactComputeResource{
+		MilliCPU:    r.MilliCPU - o.MilliCPU,
+		GPU:         r.GPU - o.GPU,
+		MemoryBytes: r.MemoryBytes - o.MemoryBytes,
+		DiskBytes:   r.DiskBytes - o.DiskBytes,
+		NetworkBPS:  r.NetworkBPS - o.NetworkBPS,
+	}
+}
+
+// Max returns the maximum value of each dimension.
+func (r ExactComputeResource) Max(o ExactComputeResource) ExactComputeResource {
+	return ExactComputeResource{
+		MilliCPU:    maxInt64(r.MilliCPU, o.MilliCPU),
+		GPU:         maxInt64(r.GPU, o.GPU),
+		MemoryBytes: maxInt64(r.MemoryBytes, o.MemoryBytes),
+		DiskBytes:   maxInt64(r.DiskBytes, o.DiskBytes),
+		NetworkBPS:  maxInt64(r.NetworkBPS, o.NetworkBPS),
+	}
+}
+
+// SubWithLimit subtracts resources, and sets each dimension below the limit to the limit value.
+func (r ExactComputeResource) SubWithLimit(o ExactComputeResource, limit int64) ExactComputeResource {
+	result := r.Sub(o)
+	return ExactComputeResource{
+		MilliCPU:    maxInt64(result.MilliCPU, limit),
+		GPU:         maxInt64(result.GPU, limit),
+		MemoryBytes: maxInt64(result.MemoryBytes, limit),
+		DiskBytes:   maxInt64(result.DiskBytes, limit),
+		NetworkBPS:  maxInt64(result.NetworkBPS, limit),
+	}
+}
+
+func ceilDiv(value int64, divisor int64) int64 {
+	result := value / divisor
+	if value%divisor > 0 {
+		result++
+	}
+	return result
+}
+
+func maxInt64(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,27 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterCollector registers the collector in the given registerer. If an equal collector of the same type is
+// already registered, the existing one is returned instead.
+func RegisterCollector(registerer prometheus.Registerer, collector prometheus.Collector) (prometheus.Collector, error) {
+	err := registerer.Register(collector)
+	if err == nil {
+		return collector, nil
+	}
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if !errors.As(err, &alreadyRegistered) {
+		return nil, err
+	}
+	if reflect.TypeOf(alreadyRegistered.ExistingCollector) != reflect.TypeOf(collector) {
+		return nil, fmt.Errorf("collector registered with a different type %T: %w",
+			alreadyRegistered.ExistingCollector, err)
+	}
+	return alreadyRegistered.ExistingCollector, nil
+}
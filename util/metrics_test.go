@@ -0,0 +1,27 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	newGaugeVec := func() *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test", Help: "Test metric"}, []string{"label"})
+	}
+	first := newGaugeVec()
+	registered, err := RegisterCollector(registry, first)
+	require.NoError(t, err)
+	require.Same(t, first, registered)
+
+	registered, err = RegisterCollector(registry, newGaugeVec())
+	require.NoError(t, err)
+	require.Same(t, first, registered)
+
+	_, err = RegisterCollector(registry, prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "test", Help: "Test metric"}, []string{"label"}))
+	require.Error(t, err)
+}
@@ -12,10 +12,32 @@ const (
 	FormatDetails    FormatDetailsLevel = 2
 )
 
+const (
+	OutputJSON  OutputFormat = 0
+	OutputTable OutputFormat = 1
+	// Same as OutputTable, but rendered as an HTML table.
+	OutputHTML OutputFormat = 2
+)
+
 type FormatDetailsLevel int
 
+type OutputFormat int
+
 type FormatterOptions struct {
 	Level FormatDetailsLevel
+	// If set, registered extended resources are included in the formatted resources.
+	ResourceRegistry *ResourceRegistry
+	// Output format. JSON is the default. The detail level is ignored by the table and HTML outputs.
+	Output OutputFormat
+	// Table output only. Column to sort the rows by. Rows are rendered in the input order if not set.
+	SortBy string
+	// Table output only. Columns to render, in the given order. All columns are rendered if not set.
+	Columns []string
+}
+
+// IsTableOutput returns true if the output is a table (text or HTML).
+func (o FormatterOptions) IsTableOutput() bool {
+	return o.Output == OutputTable || o.Output == OutputHTML
 }
 
 func ToJSONString(value interface{}) string {
@@ -0,0 +1,178 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	v12 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+)
+
+// Resources with a dedicated field in ComputeResource. They are always tracked.
+var BuiltInResourceNames = []string{
+	string(v1.ResourceCPU),
+	string(v1.ResourceMemory),
+	string(v1.ResourceEphemeralStorage),
+	ResourceGpu,
+	ResourceNetwork,
+}
+
+// ExtendedResources holds amounts of named resources not covered by ComputeResource. Missing entries are regarded
+// as zero.
+type ExtendedResources map[string]int64
+
+func (r ExtendedResources) Add(o ExtendedResources) ExtendedResources {
+	result := r.copy()
+	for name, value := range o {
+		result[name] += value
+	}
+	return result
+}
+
+// SubWithLimit subtracts resources, and sets each value below the limit to the limit value.
+func (r ExtendedResources) SubWithLimit(o ExtendedResources, limit int64) ExtendedResources {
+	result := r.copy()
+	for name, value := range o {
+		result[name] -= value
+	}
+	for name, value := range result {
+		if value < limit {
+			result[name] = limit
+		}
+	}
+	return result
+}
+
+// Max returns the maximum value of each resource.
+func (r ExtendedResources) Max(o ExtendedResources) ExtendedResources {
+	result := r.copy()
+	for name, value := range o {
+		if current, ok := result[name]; !ok || value > current {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+func (r ExtendedResources) GreaterThanOrEqual(o ExtendedResources) bool {
+	for name, value := range o {
+		if r[name] < value {
+			return false
+		}
+	}
+	return true
+}
+
+func (r ExtendedResources) copy() ExtendedResources {
+	result := ExtendedResources{}
+	for name, value := range r {
+		result[name] = value
+	}
+	return result
+}
+
+// ResourceDefinition describes an extended resource to track.
+type ResourceDefinition struct {
+	// Kube resource name, for example `vpc.amazonaws.com/pod-eni`.
+	Name string `json:"name"`
+	// Divisor converting the Kube quantity value to the tracked unit (for example OneMegaByte). If zero, the quantity
+	// value is used as is.
+	Divisor int64 `json:"divisor,omitempty"`
+}
+
+// ResourceRegistry tells which resources from node allocatable and pod requests are tracked. The built-in resources
+// (see BuiltInResourceNames) are mapped to ComputeResource, and all registered extended resources to
+// ExtendedResources.
+type ResourceRegistry struct {
+	extended []ResourceDefinition
+}
+
+// NewDefaultResourceRegistry returns a registry with the built-in resources only.
+func NewDefaultResourceRegistry() *ResourceRegistry {
+	return &ResourceRegistry{}
+}
+
+func NewResourceRegistry(extended ...ResourceDefinition) (*ResourceRegistry, error) {
+	seen := map[string]bool{}
+	for _, name := range BuiltInResourceNames {
+		seen[name] = true
+	}
+	for _, definition := range extended {
+		if definition.Name == "" {
+			return nil, fmt.Errorf("resource name not set")
+		}
+		if definition.Divisor < 0 {
+			return nil, fmt.Errorf("negative divisor of resource %s", definition.Name)
+		}
+		if seen[definition.Name] {
+			return nil, fmt.Errorf("resource %s defined more than once, or is a built-in resource", definition.Name)
+		}
+		seen[definition.Name] = true
+	}
+	sorted := append([]ResourceDefinition{}, extended...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return &ResourceRegistry{extended: sorted}, nil
+}
+
+// Names of all tracked resources, starting with the built-in ones.
+func (r *ResourceRegistry) ResourceNames() []string {
+	return append(append([]string{}, BuiltInResourceNames...), r.ExtendedResourceNames()...)
+}
+
+// Names of the registered extended resources, sorted alphabetically.
+func (r *ResourceRegistry) ExtendedResourceNames() []string {
+	names := []string{}
+	if r == nil {
+		return names
+	}
+	for _, definition := range r.extended {
+		names = append(names, definition.Name)
+	}
+	return names
+}
+
+// FromResourceList converts the resource list to ComputeResource and ExtendedResources. Resources not known to
+// the registry are ignored.
+func (r *ResourceRegistry) FromResourceList(list v1.ResourceList) (v12.ComputeResource, ExtendedResources) {
+	return FromResourceListToComputeResource(list), r.ToExtendedResources(list)
+}
+
+// ToExtendedResources returns the registered extended resources present in the resource list. A nil registry
+// tracks no extended resources.
+func (r *ResourceRegistry) ToExtendedResources(list v1.ResourceList) ExtendedResources {
+	result := ExtendedResources{}
+	if r == nil {
+		return result
+	}
+	for _, definition := range r.extended {
+		if quantity, ok := list[v1.ResourceName(definition.Name)]; ok {
+			value := quantity.Value()
+			if definition.Divisor > 0 {
+				value /= definition.Divisor
+			}
+			result[definition.Name] = value
+		}
+	}
+	return result
+}
+
+// ToResourceList is the reverse of FromResourceList.
+func (r *ResourceRegistry) ToResourceList(resources v12.ComputeResource, extended ExtendedResources) v1.ResourceList {
+	result := FromComputeResourceToResourceList(resources)
+	if r == nil {
+		return result
+	}
+	for _, definition := range r.extended {
+		if value, ok := extended[definition.Name]; ok {
+			if definition.Divisor > 0 {
+				value *= definition.Divisor
+			}
+			result[v1.ResourceName(definition.Name)] = *resource.NewQuantity(value, resource.DecimalSI)
+		}
+	}
+	return result
+}
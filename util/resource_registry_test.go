@@ -0,0 +1,56 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	v12 "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+)
+
+func TestResourceRegistry(t *testing.T) {
+	registry, err := NewResourceRegistry(
+		ResourceDefinition{Name: "example.com/ip"},
+		ResourceDefinition{Name: "example.com/buffer", Divisor: OneMegaByte},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"example.com/buffer", "example.com/ip"}, registry.ExtendedResourceNames())
+	require.Len(t, registry.ResourceNames(), len(BuiltInResourceNames)+2)
+
+	resources := v12.ComputeResource{CPU: 2, MemoryMB: 1024}
+	extended := ExtendedResources{"example.com/ip": 3, "example.com/buffer": 16}
+	list := registry.ToResourceList(resources, extended)
+	buffer := list["example.com/buffer"]
+	require.EqualValues(t, 16*OneMegaByte, buffer.Value())
+
+	list["example.com/unknown"] = resource.MustParse("1")
+	computeResource, extendedResult := registry.FromResourceList(list)
+	require.Equal(t, resources, computeResource)
+	require.Equal(t, extended, extendedResult)
+
+	require.Empty(t, NewDefaultResourceRegistry().ToExtendedResources(list))
+}
+
+func TestInvalidResourceRegistry(t *testing.T) {
+	_, err := NewResourceRegistry(ResourceDefinition{Name: string(v1.ResourceCPU)})
+	require.Error(t, err)
+	_, err = NewResourceRegistry(ResourceDefinition{Name: "example.com/ip"}, ResourceDefinition{Name: "example.com/ip"})
+	require.Error(t, err)
+	_, err = NewResourceRegistry(ResourceDefinition{})
+	require.Error(t, err)
+}
+
+func TestExtendedResources(t *testing.T) {
+	a := ExtendedResources{"x": 5, "y": 1}
+	b := ExtendedResources{"x": 2, "z": 3}
+	require.Equal(t, ExtendedResources{"x": 7, "y": 1, "z": 3}, a.Add(b))
+	require.Equal(t, ExtendedResources{"x": 3, "y": 1, "z": 0}, a.SubWithLimit(b, 0))
+	require.Equal(t, ExtendedResources{"x": 5, "y": 1, "z": 3}, a.Max(b))
+	require.False(t, a.GreaterThanOrEqual(b))
+	require.True(t, a.GreaterThanOrEqual(ExtendedResources{"x": 5}))
+	// Inputs are not modified
+	require.Equal(t, ExtendedResources{"x": 5, "y": 1}, a)
+}
@@ -0,0 +1,227 @@
+package util
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+
+	titusPool "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+)
+
+// Aligned text table renderer for log triage and CLI output.
+
+const (
+	ColumnCPU     = "CPU"
+	ColumnGPU     = "GPU"
+	ColumnMemory  = "MEMORY"
+	ColumnDisk    = "DISK"
+	ColumnNetwork = "NETWORK"
+
+	tableColumnSeparator = "  "
+)
+
+// Column headers of a compute resource, in the order of cells returned by ComputeResourceCells.
+var ComputeResourceColumns = []string{ColumnCPU, ColumnGPU, ColumnMemory, ColumnDisk, ColumnNetwork}
+
+// TableCell is a rendered cell text with an optional numeric value used for sorting.
+type TableCell struct {
+	Text    string
+	Value   float64
+	Numeric bool
+}
+
+// NumberCell returns a cell sorted by the given value, for example a formatted resource amount.
+func NumberCell(text string, value float64) TableCell {
+	return TableCell{Text: text, Value: value, Numeric: true}
+}
+
+// Cells converts texts to table cells. Texts which are plain numbers are sorted by their value.
+func Cells(texts ...string) []TableCell {
+	cells := make([]TableCell, len(texts))
+	for i, text := range texts {
+		if value, err := strconv.ParseFloat(text, 64); err == nil {
+			cells[i] = NumberCell(text, value)
+		} else {
+			cells[i] = TableCell{Text: text}
+		}
+	}
+	return cells
+}
+
+// Table holds rows of cells. Use NewTable to create it, and Render to produce the aligned text output.
+type Table struct {
+	columns []string
+	rows    [][]TableCell
+}
+
+func NewTable(columns ...string) *Table {
+	return &Table{
+		columns: columns,
+		rows:    [][]TableCell{},
+	}
+}
+
+// AddRow appends a row of text cells (see Cells). Missing cells are rendered empty, and extra cells are dropped.
+func (t *Table) AddRow(cells ...string) *Table {
+	return t.AddCells(Cells(cells...)...)
+}
+
+// AddCells is AddRow with cells that may carry numeric values for sorting.
+func (t *Table) AddCells(cells ...TableCell) *Table {
+	row := make([]TableCell, len(t.columns))
+	copy(row, cells)
+	t.rows = append(t.rows, row)
+	return t
+}
+
+func (t *Table) RowCount() int {
+	return len(t.rows)
+}
+
+// Render produces the table text with a header line, and columns aligned to the widest cell (or an HTML table if
+// options.Output is OutputHTML). If options.Columns is
+// set, only the listed columns are rendered in the given order (unknown names are ignored). If options.SortBy is set,
+// rows are sorted by that column, comparing numeric values of cells if both have one, and the cell text otherwise.
+// Column names are matched case insensitive.
+func (t *Table) Render(options FormatterOptions) string {
+	selected := t.selectColumns(options.Columns)
+	rows := make([][]TableCell, len(t.rows))
+	copy(rows, t.rows)
+	if sortIndex := t.columnIndex(options.SortBy); sortIndex >= 0 {
+		sort.SliceStable(rows, func(i, j int) bool {
+			return lessTableCell(rows[i][sortIndex], rows[j][sortIndex])
+		})
+	}
+
+	if options.Output == OutputHTML {
+		return renderHTMLTable(t.columns, selected, rows)
+	}
+
+	widths := make([]int, len(selected))
+	for i, column := range selected {
+		widths[i] = len(t.columns[column])
+		for _, row := range rows {
+			if len(row[column].Text) > widths[i] {
+				widths[i] = len(row[column].Text)
+			}
+		}
+	}
+
+	builder := strings.Builder{}
+	writeLine := func(cells func(column int) string) {
+		line := strings.Builder{}
+		for i, column := range selected {
+			if i > 0 {
+				line.WriteString(tableColumnSeparator)
+			}
+			line.WriteString(fmt.Sprintf("%-*s", widths[i], cells(column)))
+		}
+		builder.WriteString(strings.TrimRight(line.String(), " "))
+		builder.WriteString("\n")
+	}
+	writeLine(func(column int) string { return t.columns[column] })
+	for _, row := range rows {
+		writeLine(func(column int) string { return row[column].Text })
+	}
+	return builder.String()
+}
+
+func renderHTMLTable(columns []string, selected []int, rows [][]TableCell) string {
+	builder := strings.Builder{}
+	builder.WriteString("<table>\n<tr>")
+	for _, column := range selected {
+		builder.WriteString("<th>" + html.EscapeString(columns[column]) + "</th>")
+	}
+	builder.WriteString("</tr>\n")
+	for _, row := range rows {
+		builder.WriteString("<tr>")
+		for _, column := range selected {
+			builder.WriteString("<td>" + html.EscapeString(row[column].Text) + "</td>")
+		}
+		builder.WriteString("</tr>\n")
+	}
+	builder.WriteString("</table>\n")
+	return builder.String()
+}
+
+func (t *Table) selectColumns(names []string) []int {
+	selected := []int{}
+	for _, name := range names {
+		if index := t.columnIndex(name); index >= 0 {
+			selected = append(selected, index)
+		}
+	}
+	if len(selected) > 0 {
+		return selected
+	}
+	for i := range t.columns {
+		selected = append(selected, i)
+	}
+	return selected
+}
+
+func (t *Table) columnIndex(name string) int {
+	if name == "" {
+		return -1
+	}
+	for i, column := range t.columns {
+		if strings.EqualFold(column, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func lessTableCell(a TableCell, b TableCell) bool {
+	if a.Numeric && b.Numeric && a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	return a.Text < b.Text
+}
+
+// FormatCPU formats a CPU count, for example "16 cores".
+func FormatCPU(cpu int64) string {
+	if cpu == 1 {
+		return "1 core"
+	}
+	return fmt.Sprintf("%d cores", cpu)
+}
+
+// FormatMegaBytes formats memory or disk size in the highest binary unit with a value of at least 1, for example
+// "384GiB" or "1.5TiB".
+func FormatMegaBytes(mb int64) string {
+	units := []string{"MiB", "GiB", "TiB", "PiB"}
+	value := float64(mb)
+	unit := 0
+	for unit < len(units)-1 && (value >= 1024 || value <= -1024) {
+		value /= 1024
+		unit++
+	}
+	return formatWithUnit(value, units[unit])
+}
+
+// FormatNetworkMBPS formats network bandwidth, for example "25Gbps" or "512Mbps".
+func FormatNetworkMBPS(mbps int64) string {
+	if mbps >= 1000 || mbps <= -1000 {
+		return formatWithUnit(float64(mbps)/1000, "Gbps")
+	}
+	return fmt.Sprintf("%dMbps", mbps)
+}
+
+// Formats the value with at most one decimal digit.
+func formatWithUnit(value float64, unit string) string {
+	return strings.TrimSuffix(strconv.FormatFloat(value, 'f', 1, 64), ".0") + unit
+}
+
+// ComputeResourceCells returns table cells with human readable compute resource values, sorted by the raw values.
+func ComputeResourceCells(resources titusPool.ComputeResource) []TableCell {
+	return []TableCell{
+		NumberCell(FormatCPU(resources.CPU), float64(resources.CPU)),
+		NumberCell(strconv.FormatInt(resources.GPU, 10), float64(resources.GPU)),
+		NumberCell(FormatMegaBytes(resources.MemoryMB), float64(resources.MemoryMB)),
+		NumberCell(FormatMegaBytes(resources.DiskMB), float64(resources.DiskMB)),
+		NumberCell(FormatNetworkMBPS(resources.NetworkMBPS), float64(resources.NetworkMBPS)),
+	}
+}
@@ -0,0 +1,75 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	titusPool "github.com/Netflix/titus-controllers-api/api/resourcepool/v1"
+)
+
+func TestTableRender(t *testing.T) {
+	table := NewTable("NAME", "CPU", "STATE").
+		AddCells(TableCell{Text: "node-b"}, NumberCell("16 cores", 16), TableCell{Text: "active"}).
+		AddCells(TableCell{Text: "node-a"}, NumberCell("8 cores", 8), TableCell{Text: "unhealthy"}).
+		AddCells(TableCell{Text: "node-c"}, NumberCell("128 cores", 128))
+
+	require.Equal(t, ""+
+		"NAME    CPU        STATE\n"+
+		"node-b  16 cores   active\n"+
+		"node-a  8 cores    unhealthy\n"+
+		"node-c  128 cores\n",
+		table.Render(FormatterOptions{Output: OutputTable}))
+
+	require.Equal(t, ""+
+		"CPU        NAME\n"+
+		"8 cores    node-a\n"+
+		"16 cores   node-b\n"+
+		"128 cores  node-c\n",
+		table.Render(FormatterOptions{Output: OutputTable, SortBy: "cpu", Columns: []string{"cpu", "name", "unknown"}}))
+}
+
+func TestTableSortByRawValues(t *testing.T) {
+	table := NewTable("NAME", "MEMORY", "COUNT")
+	table.AddCells(append(Cells("node-a"), NumberCell(FormatMegaBytes(1572864), 1572864), Cells("10")[0])...)
+	table.AddCells(append(Cells("node-b"), NumberCell(FormatMegaBytes(786432), 786432), Cells("9")[0])...)
+
+	require.Equal(t, ""+
+		"NAME    MEMORY  COUNT\n"+
+		"node-b  768GiB  9\n"+
+		"node-a  1.5TiB  10\n",
+		table.Render(FormatterOptions{Output: OutputTable, SortBy: "memory"}))
+	require.Equal(t, ""+
+		"NAME    MEMORY  COUNT\n"+
+		"node-b  768GiB  9\n"+
+		"node-a  1.5TiB  10\n",
+		table.Render(FormatterOptions{Output: OutputTable, SortBy: "count"}))
+}
+
+func TestHumanUnits(t *testing.T) {
+	require.Equal(t, "1 core", FormatCPU(1))
+	require.Equal(t, "512MiB", FormatMegaBytes(512))
+	require.Equal(t, "1.5GiB", FormatMegaBytes(1536))
+	require.Equal(t, "768GiB", FormatMegaBytes(786432))
+	require.Equal(t, "1.5TiB", FormatMegaBytes(1572864))
+	require.Equal(t, "512Mbps", FormatNetworkMBPS(512))
+	require.Equal(t, "25Gbps", FormatNetworkMBPS(25000))
+	require.Equal(t, []TableCell{
+		NumberCell("96 cores", 96),
+		NumberCell("0", 0),
+		NumberCell("768GiB", 786432),
+		NumberCell("1.5TiB", 1572864),
+		NumberCell("25Gbps", 25000),
+	}, ComputeResourceCells(
+		titusPool.ComputeResource{CPU: 96, MemoryMB: 786432, DiskMB: 1572864, NetworkMBPS: 25000}))
+}
+
+func TestTableRenderHTML(t *testing.T) {
+	table := NewTable("NAME", "STATE").AddRow("<node>", "active")
+	require.Equal(t, ""+
+		"<table>\n"+
+		"<tr><th>NAME</th><th>STATE</th></tr>\n"+
+		"<tr><td>&lt;node&gt;</td><td>active</td></tr>\n"+
+		"</table>\n",
+		table.Render(FormatterOptions{Output: OutputHTML}))
+}